@@ -0,0 +1,354 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BaselineKind describes how a baseline summarizes its reference spectra.
+type BaselineKind int
+
+const (
+	MeanBaseline       BaselineKind = iota // per-bin mean and standard deviation
+	PercentileBaseline                     // per-bin median and percentile envelopes
+)
+
+// ParseBaselineKind returns the baseline kind named after the provided name.
+func ParseBaselineKind(name string) (BaselineKind, error) {
+	switch strings.ToLower(name) {
+	case "mean":
+		return MeanBaseline, nil
+	case "pct", "percentile":
+		return PercentileBaseline, nil
+	}
+	return 0, fmt.Errorf("fouracc: unknown baseline kind %q", name)
+}
+
+func (k BaselineKind) String() string {
+	switch k {
+	case MeanBaseline:
+		return "mean"
+	case PercentileBaseline:
+		return "pct"
+	}
+	return fmt.Sprintf("BaselineKind(%d)", int(k))
+}
+
+// Baseline is a per-bin reference spectrum, built from the chunks of one or
+// more FFT results.
+//
+// For a MeanBaseline, Center and Upper are the mean and Spread is the
+// standard deviation of the reference amplitudes.
+// For a PercentileBaseline, Center is the median, Upper is the Pct-th
+// percentile and Spread is the half-width of the envelope between the
+// (100-Pct)-th and Pct-th percentiles.
+type Baseline struct {
+	Freqs  []float64 // frequencies of the bins
+	Center []float64
+	Spread []float64
+	Upper  []float64
+	Lower  []float64 // (100-Pct)-th percentile (PercentileBaseline only)
+
+	Kind   BaselineKind
+	Pct    float64 // percentile of the upper envelope (PercentileBaseline only)
+	Count  int     // number of reference spectra
+	Chunks int
+	Scale  float64 // Frequency scale
+}
+
+// NewBaseline builds a baseline from the chunks of the provided FFT results.
+// All FFT results must share the same chunk size and frequency scale (within 1%).
+// Chunks with missing (NaN) coefficients are ignored, and at least two
+// complete chunks are needed to estimate the spread of the reference.
+func NewBaseline(ffts []FFT, kind BaselineKind, pct float64) (Baseline, error) {
+	if len(ffts) == 0 {
+		return Baseline{}, fmt.Errorf("fouracc: no reference spectrum")
+	}
+	if kind == PercentileBaseline && (pct <= 50 || pct >= 100) {
+		return Baseline{}, fmt.Errorf("fouracc: invalid baseline percentile %v", pct)
+	}
+
+	ref := ffts[0]
+	for _, fft := range ffts[1:] {
+		if fft.Chunks != ref.Chunks || !sameScale(fft.Scale, ref.Scale) || len(fft.Freqs) != len(ref.Freqs) {
+			return Baseline{}, fmt.Errorf(
+				"fouracc: mismatched reference spectra (chunks=%d, freq=%v) and (chunks=%d, freq=%v)",
+				ref.Chunks, ref.Scale, fft.Chunks, fft.Scale,
+			)
+		}
+	}
+
+	var (
+		n    = len(ref.Freqs) - 1 // coefficients do not include the DC bin.
+		bins = make([][]float64, n)
+		base = Baseline{
+			Freqs:  ref.Freqs[1:],
+			Center: make([]float64, n),
+			Spread: make([]float64, n),
+			Upper:  make([]float64, n),
+			Kind:   kind,
+			Pct:    pct,
+			Chunks: ref.Chunks,
+			Scale:  ref.Scale,
+		}
+	)
+
+	for _, fft := range ffts {
+	chunks:
+		for _, cs := range fft.Coeffs {
+			for _, c := range cs {
+				if math.IsNaN(c) {
+					continue chunks
+				}
+			}
+			for i, c := range cs {
+				bins[i] = append(bins[i], c)
+			}
+			base.Count++
+		}
+	}
+	if base.Count < 2 {
+		// a single chunk has no spread: every sigma score would be ±Inf or NaN.
+		return Baseline{}, fmt.Errorf("fouracc: not enough complete reference chunks (got %d, want >= 2)", base.Count)
+	}
+
+	switch kind {
+	case MeanBaseline:
+		for i, vs := range bins {
+			mean, std := meanStd(vs)
+			base.Center[i] = mean
+			base.Spread[i] = std
+			base.Upper[i] = mean
+		}
+	case PercentileBaseline:
+		base.Lower = make([]float64, n)
+		for i, vs := range bins {
+			sort.Float64s(vs)
+			base.Center[i] = percentile(vs, 50)
+			base.Upper[i] = percentile(vs, pct)
+			base.Lower[i] = percentile(vs, 100-pct)
+			base.Spread[i] = 0.5 * (base.Upper[i] - base.Lower[i])
+		}
+	default:
+		return Baseline{}, fmt.Errorf("fouracc: unknown baseline kind %d", int(kind))
+	}
+
+	return base, nil
+}
+
+// sameScale returns whether the two frequency scales agree within 1%,
+// the accuracy of sampling frequencies inferred from timestamps.
+func sameScale(a, b float64) bool {
+	return math.Abs(a-b) <= 0.01*math.Max(math.Abs(a), math.Abs(b))
+}
+
+func meanStd(vs []float64) (mean, std float64) {
+	for _, v := range vs {
+		mean += v
+	}
+	mean /= float64(len(vs))
+	if len(vs) < 2 {
+		return mean, 0
+	}
+	for _, v := range vs {
+		std += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(std / float64(len(vs)-1))
+}
+
+// percentile returns the p-th percentile of the sorted values, with
+// linear interpolation between the closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	var (
+		x    = p / 100 * float64(len(sorted)-1)
+		i    = int(math.Floor(x))
+		frac = x - float64(i)
+	)
+	if i >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + frac*(sorted[i+1]-sorted[i])
+}
+
+// ScoreUnit is the unit of the excess of a spectrogram over a baseline.
+type ScoreUnit int
+
+const (
+	DBScore    ScoreUnit = iota // decibels above Baseline.Upper
+	SigmaScore                  // number of Baseline.Spread above Baseline.Center
+)
+
+func (u ScoreUnit) String() string {
+	switch u {
+	case DBScore:
+		return "dB"
+	case SigmaScore:
+		return "sigma"
+	}
+	return fmt.Sprintf("ScoreUnit(%d)", int(u))
+}
+
+// ParseThreshold parses an anomaly threshold of the form "6dB" or "3sigma".
+func ParseThreshold(v string) (float64, ScoreUnit, error) {
+	var (
+		s    = strings.ToLower(strings.TrimSpace(v))
+		unit ScoreUnit
+	)
+	switch {
+	case strings.HasSuffix(s, "db"):
+		unit = DBScore
+		s = strings.TrimSuffix(s, "db")
+	case strings.HasSuffix(s, "sigma"):
+		unit = SigmaScore
+		s = strings.TrimSuffix(s, "sigma")
+	default:
+		return 0, 0, fmt.Errorf("fouracc: invalid threshold %q (want e.g. 6dB or 3sigma)", v)
+	}
+	x, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("fouracc: could not parse threshold %q: %w", v, err)
+	}
+	return x, unit, nil
+}
+
+// Event is a connected set of anomalous time/frequency cells.
+type Event struct {
+	T0, T1 float64 // start of the first and last chunks
+	F0, F1 float64 // lowest and highest frequencies
+	Peak   float64 // highest score
+	Cells  int     // number of anomalous cells
+}
+
+// Anomaly holds the scoring of a spectrogram against a baseline.
+type Anomaly struct {
+	FFT    FFT         // scored spectrogram
+	Score  [][]float64 // excess over the baseline, per time/frequency cell
+	Mask   [][]bool    // whether the cell exceeds the threshold
+	Events []Event
+
+	Unit      ScoreUnit
+	Threshold float64
+}
+
+// Score scores the provided spectrogram against the baseline, flagging the
+// cells exceeding it by more than threshold, in the provided unit.
+// Anomalous cells are grouped into events of adjacent cells.
+func (base Baseline) Score(fft FFT, unit ScoreUnit, threshold float64) (Anomaly, error) {
+	if fft.Chunks != base.Chunks || !sameScale(fft.Scale, base.Scale) || len(fft.Freqs)-1 != len(base.Freqs) {
+		return Anomaly{}, fmt.Errorf(
+			"fouracc: spectrogram (chunks=%d, freq=%v) does not match baseline (chunks=%d, freq=%v)",
+			fft.Chunks, fft.Scale, base.Chunks, base.Scale,
+		)
+	}
+
+	an := Anomaly{
+		FFT:       fft,
+		Score:     make([][]float64, len(fft.Coeffs)),
+		Mask:      make([][]bool, len(fft.Coeffs)),
+		Unit:      unit,
+		Threshold: threshold,
+	}
+	for c, cs := range fft.Coeffs {
+		an.Score[c] = make([]float64, len(cs))
+		an.Mask[c] = make([]bool, len(cs))
+		for r, v := range cs {
+			var score float64
+			switch unit {
+			case DBScore:
+				score = 20 * math.Log10(v/base.Upper[r])
+			case SigmaScore:
+				score = (v - base.Center[r]) / base.Spread[r]
+			default:
+				return Anomaly{}, fmt.Errorf("fouracc: unknown score unit %d", int(unit))
+			}
+			an.Score[c][r] = score
+			an.Mask[c][r] = score > threshold // false for NaN scores.
+		}
+	}
+	an.Events = an.events(base.Freqs)
+
+	return an, nil
+}
+
+// events groups the anomalous cells into events of 4-connected cells.
+func (an Anomaly) events(freqs []float64) []Event {
+	var (
+		evts []Event
+		seen = make([][]bool, len(an.Mask))
+	)
+	for c := range seen {
+		seen[c] = make([]bool, len(an.Mask[c]))
+	}
+
+	type cell struct{ c, r int }
+	for c0, row := range an.Mask {
+		for r0, bad := range row {
+			if !bad || seen[c0][r0] {
+				continue
+			}
+			var (
+				evt   = Event{T0: math.Inf(+1), T1: math.Inf(-1), F0: math.Inf(+1), F1: math.Inf(-1), Peak: math.Inf(-1)}
+				stack = []cell{{c0, r0}}
+			)
+			seen[c0][r0] = true
+			for len(stack) > 0 {
+				cur := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+
+				evt.Cells++
+				evt.T0 = math.Min(evt.T0, an.FFT.Ts[cur.c])
+				evt.T1 = math.Max(evt.T1, an.FFT.Ts[cur.c])
+				evt.F0 = math.Min(evt.F0, freqs[cur.r])
+				evt.F1 = math.Max(evt.F1, freqs[cur.r])
+				evt.Peak = math.Max(evt.Peak, an.Score[cur.c][cur.r])
+
+				for _, nb := range []cell{
+					{cur.c - 1, cur.r}, {cur.c + 1, cur.r},
+					{cur.c, cur.r - 1}, {cur.c, cur.r + 1},
+				} {
+					if nb.c < 0 || nb.c >= len(an.Mask) || nb.r < 0 || nb.r >= len(an.Mask[nb.c]) {
+						continue
+					}
+					if !an.Mask[nb.c][nb.r] || seen[nb.c][nb.r] {
+						continue
+					}
+					seen[nb.c][nb.r] = true
+					stack = append(stack, nb)
+				}
+			}
+			evts = append(evts, evt)
+		}
+	}
+
+	sort.Slice(evts, func(i, j int) bool {
+		if evts[i].T0 != evts[j].T0 {
+			return evts[i].T0 < evts[j].T0
+		}
+		return evts[i].F0 < evts[j].F0
+	})
+	return evts
+}
+
+// maskGrid exposes the anomaly mask of a spectrogram as a grid of 0 and 1,
+// aligned with the spectrogram cells.
+type maskGrid struct{ an Anomaly }
+
+func (g maskGrid) Dims() (c, r int) { return g.an.FFT.Dims() }
+func (g maskGrid) Z(c, r int) float64 {
+	if g.an.Mask[c][r] {
+		return 1
+	}
+	return 0
+}
+func (g maskGrid) X(c int) float64 { return g.an.FFT.X(c) }
+func (g maskGrid) Y(r int) float64 { return g.an.FFT.Y(r) }
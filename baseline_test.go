@@ -0,0 +1,74 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	const (
+		chunksz = 64
+		freq    = 100.0
+	)
+	noise := func(seed int64, n int) FFT {
+		var (
+			rnd = rand.New(rand.NewSource(seed))
+			xs  = make([]float64, n)
+			ys  = make([]float64, n)
+		)
+		for i := range xs {
+			xs[i] = float64(i)
+			ys[i] = rnd.NormFloat64()
+		}
+		return ChunkedFFT("noise", chunksz, xs, ys, freq)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		refs   []FFT
+		kind   BaselineKind
+		chunks int // number of reference chunks (0 for an error)
+	}{
+		{"no-reference", nil, MeanBaseline, 0},
+		{"one-chunk", []FFT{noise(1, chunksz)}, MeanBaseline, 0},
+		{"one-chunk-pct", []FFT{noise(1, chunksz)}, PercentileBaseline, 0},
+		{"two-chunks", []FFT{noise(1, 2*chunksz)}, MeanBaseline, 2},
+		{"two-files", []FFT{noise(1, chunksz), noise(2, chunksz)}, MeanBaseline, 2},
+		{"pct", []FFT{noise(1, 8*chunksz), noise(2, 8*chunksz)}, PercentileBaseline, 16},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			base, err := NewBaseline(tc.refs, tc.kind, 95)
+			if tc.chunks == 0 {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("could not build baseline: %+v", err)
+			}
+			if got, want := base.Count, tc.chunks; got != want {
+				t.Fatalf("invalid number of reference chunks: got=%d, want=%d", got, want)
+			}
+
+			// scoring a reference against its own baseline gives finite
+			// sigma scores.
+			an, err := base.Score(tc.refs[0], SigmaScore, 3)
+			if err != nil {
+				t.Fatalf("could not score spectrogram: %+v", err)
+			}
+			for c, row := range an.Score {
+				for r, v := range row {
+					if math.IsNaN(v) || math.IsInf(v, 0) {
+						t.Fatalf("invalid score (c=%d, r=%d): %v (spread=%v)", c, r, v, base.Spread[r])
+					}
+				}
+			}
+		})
+	}
+}
@@ -5,7 +5,6 @@
 package main
 
 import (
-	"bytes"
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/json"
@@ -14,6 +13,8 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"math"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -24,12 +25,9 @@ import (
 
 	uuid "github.com/hashicorp/go-uuid"
 	"github.com/lsst-lpc/fouracc"
+	"github.com/lsst-lpc/fouracc/internal/analysis"
 	"github.com/lsst-lpc/fouracc/msr"
-	"go-hep.org/x/hep/csvutil"
 	"golang.org/x/sync/errgroup"
-	"gonum.org/v1/plot/vg"
-	"gonum.org/v1/plot/vg/draw"
-	"gonum.org/v1/plot/vg/vgimg"
 )
 
 const cookieName = "FOURACC_SRV"
@@ -199,15 +197,16 @@ func (srv *server) runHandle(w http.ResponseWriter, r *http.Request) error {
 	srv.ids[cookie.Value][id] = struct{}{}
 	srv.mu.Unlock()
 
-	f, handler, err := r.FormFile("input-file")
+	f, _, err := r.FormFile("input-file")
 	if err != nil {
 		return fmt.Errorf("could not access input file: %w", err)
 	}
 	defer f.Close()
-	fname := handler.Filename
-	if strings.HasPrefix(fname, `C:\fakepath\`) {
-		fname = string(fname[len(`C:\fakepath\`):])
+	fnames := make([]string, len(r.MultipartForm.File["input-file"]))
+	for i, fh := range r.MultipartForm.File["input-file"] {
+		fnames[i] = uploadName(fh)
 	}
+	fname := analysis.MergedName(fnames)
 	log.Printf("fname: %v", fname)
 
 	chunksz, err := strconv.Atoi(r.PostFormValue("chunksz"))
@@ -228,6 +227,227 @@ func (srv *server) runHandle(w http.ResponseWriter, r *http.Request) error {
 	}
 	log.Printf("xmax: %d", xmax)
 
+	opts := analysis.Options{ChunkSize: chunksz, Method: r.PostFormValue("method")}
+	lopts := analysis.LoadOptions(r.PostFormValue("col"), r.PostFormValue("time-col"))
+	if sep := r.PostFormValue("csv-sep"); sep != "" {
+		d, err := analysis.Dialect(sep, r.PostFormValue("csv-decimal"))
+		if err != nil {
+			return fmt.Errorf("could not parse CSV dialect: %w", err)
+		}
+		d.Comment = r.PostFormValue("csv-comment")
+		if v := r.PostFormValue("csv-header"); v != "" {
+			d.Header, err = strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("could not parse number of CSV header rows: %w", err)
+			}
+		}
+		for _, v := range []struct {
+			name string
+			ptr  *bool
+		}{
+			{"csv-quotes", &d.Quotes},
+			{"csv-skip-blank", &d.SkipBlank},
+			{"csv-skip-trailing", &d.SkipTrailing},
+		} {
+			if s := r.PostFormValue(v.name); s != "" {
+				*v.ptr, err = strconv.ParseBool(s)
+				if err != nil {
+					return fmt.Errorf("could not parse %s: %w", v.name, err)
+				}
+			}
+		}
+		lopts = append(lopts, fouracc.WithDialect(d))
+		log.Printf("csv: %v", d)
+	}
+	switch opts.Method {
+	case "", "stft":
+		opts.Method = "stft"
+	case "cwt":
+		opts.Wavelet, err = fouracc.ParseWavelet(r.PostFormValue("wavelet"))
+		if err != nil {
+			return fmt.Errorf("could not parse wavelet: %w", err)
+		}
+		opts.NScales, err = strconv.Atoi(r.PostFormValue("nscales"))
+		if err != nil {
+			return fmt.Errorf("could not parse number of CWT scales: %w", err)
+		}
+		if v := r.PostFormValue("scales"); v != "" {
+			opts.SMin, opts.SMax, err = analysis.ParseBand(v)
+			if err != nil {
+				return fmt.Errorf("could not parse CWT scales: %w", err)
+			}
+		}
+		log.Printf("cwt: wavelet=%v, scales=%d", opts.Wavelet, opts.NScales)
+	case "mtm":
+		opts.NW, err = strconv.ParseFloat(r.PostFormValue("nw"), 64)
+		if err != nil {
+			return fmt.Errorf("could not parse time-bandwidth product: %w", err)
+		}
+		if v := r.PostFormValue("tapers"); v != "" {
+			opts.Tapers, err = strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("could not parse number of tapers: %w", err)
+			}
+		}
+		log.Printf("mtm: NW=%v, tapers=%d", opts.NW, opts.Tapers)
+	case "lomb":
+		// ok
+	default:
+		return fmt.Errorf("invalid analysis method %q", opts.Method)
+	}
+
+	if v := r.PostFormValue("octave"); v != "" {
+		opts.Octave, err = strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("could not parse octave fraction: %w", err)
+		}
+		log.Printf("octave: 1/%d", opts.Octave)
+	}
+
+	if v := r.PostFormValue("cumrms"); v != "" {
+		opts.CumRMS, err = strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("could not parse cumulative RMS flag: %w", err)
+		}
+		log.Printf("cumrms: %v", opts.CumRMS)
+	}
+
+	if v := r.PostFormValue("vc"); v != "" {
+		opts.VC = true
+		opts.VCTarget, err = fouracc.ParseVCClass(v)
+		if err != nil {
+			return fmt.Errorf("could not parse VC curve: %w", err)
+		}
+		log.Printf("vc: target=%v", opts.VCTarget)
+	}
+	accunit := r.PostFormValue("acc-unit")
+	sel := r.PostFormValue("channels")
+
+	if v := r.PostFormValue("weighting"); v != "" {
+		opts.Weighted = true
+		opts.Weighting, err = fouracc.ParseWeighting(v)
+		if err != nil {
+			return fmt.Errorf("could not parse frequency weighting: %w", err)
+		}
+		opts.Tau, err = strconv.ParseFloat(r.PostFormValue("tau"), 64)
+		if err != nil {
+			return fmt.Errorf("could not parse running RMS integration time: %w", err)
+		}
+		log.Printf("weighting: %v, tau=%v", opts.Weighting, opts.Tau)
+	}
+
+	var calib bool
+	if v := r.PostFormValue("calibrate"); v != "" {
+		calib, err = strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("could not parse calibration flag: %w", err)
+		}
+	}
+
+	align := true
+	if v := r.PostFormValue("align"); v != "" {
+		align, err = strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("could not parse alignment flag: %w", err)
+		}
+	}
+
+	var fill bool
+	if v := r.PostFormValue("merge-fill"); v != "" {
+		fill, err = strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("could not parse gap filling flag: %w", err)
+		}
+	}
+
+	var mopts []msr.Option
+	if calib {
+		mopts = append(mopts, msr.WithCalibration())
+	}
+	if align {
+		mopts = append(mopts, msr.WithAlignment())
+	}
+	if tz := r.PostFormValue("tz"); tz != "" {
+		loc, err := msr.ParseZone(tz)
+		if err != nil {
+			return fmt.Errorf("could not parse time zone: %w", err)
+		}
+		mopts = append(mopts, msr.WithLocation(loc))
+	}
+
+	if fhs := r.MultipartForm.File["baseline-file"]; len(fhs) > 0 {
+		kind, err := fouracc.ParseBaselineKind(r.PostFormValue("baseline-kind"))
+		if err != nil {
+			return fmt.Errorf("could not parse baseline kind: %w", err)
+		}
+		pct, err := strconv.ParseFloat(r.PostFormValue("pct"), 64)
+		if err != nil {
+			return fmt.Errorf("could not parse baseline percentile: %w", err)
+		}
+		opts.Threshold, opts.ScoreUnit, err = fouracc.ParseThreshold(r.PostFormValue("threshold"))
+		if err != nil {
+			return fmt.Errorf("could not parse anomaly threshold: %w", err)
+		}
+		var (
+			refs  = make([][]analysis.Series, len(fhs))
+			freqs = make([]float64, len(fhs))
+		)
+		for i, fh := range fhs {
+			f, err := fh.Open()
+			if err != nil {
+				return fmt.Errorf("could not open baseline file %q: %w", fh.Filename, err)
+			}
+			refs[i], freqs[i], err = analysis.ReadSeries(f, sel, mopts, lopts...)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("could not read baseline file %q: %w", fh.Filename, err)
+			}
+		}
+		opts.Baselines, err = analysis.Baselines(refs, freqs, opts.ChunkSize, kind, pct)
+		if err != nil {
+			return err
+		}
+		log.Printf("baseline: %d file(s), kind=%v, threshold=%v%v", len(fhs), kind, opts.Threshold, opts.ScoreUnit)
+	}
+
+	if win := r.PostFormValue("srs"); win != "" {
+		opts.SRS = true
+		opts.SRST0, opts.SRST1, err = analysis.ParseBand(win)
+		if err != nil {
+			return fmt.Errorf("could not parse SRS event window: %w", err)
+		}
+		opts.SRSQ, err = strconv.ParseFloat(r.PostFormValue("srs-q"), 64)
+		if err != nil {
+			return fmt.Errorf("could not parse SRS quality factor: %w", err)
+		}
+		opts.SRSKind, err = fouracc.ParseSRSKind(r.PostFormValue("srs-kind"))
+		if err != nil {
+			return fmt.Errorf("could not parse SRS kind: %w", err)
+		}
+		if v := r.PostFormValue("srs-freqs"); v != "" {
+			opts.SRSFmin, opts.SRSFmax, err = analysis.ParseBand(v)
+			if err != nil {
+				return fmt.Errorf("could not parse SRS natural frequencies: %w", err)
+			}
+		}
+		log.Printf("srs: window=[%v, %v], Q=%v, kind=%v", opts.SRST0, opts.SRST1, opts.SRSQ, opts.SRSKind)
+	}
+	if band := r.PostFormValue("env"); band != "" {
+		lo, hi, err := analysis.ParseBand(band)
+		if err != nil {
+			return fmt.Errorf("could not parse envelope carrier band: %w", err)
+		}
+		opts.Env = true
+		opts.EnvLo = lo
+		opts.EnvHi = hi
+		log.Printf("envelope: [%v, %v]", lo, hi)
+	}
+
+	err = opts.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid analysis options: %w", err)
+	}
+
 	var head [64]byte
 	_, err = io.ReadFull(f, head[:])
 	if err != nil {
@@ -239,46 +459,77 @@ func (srv *server) runHandle(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	var (
-		isMSR = strings.HasPrefix(string(head[:]), "*CREATOR")
-		imgs  [][]byte
-		names []string
+		isMSR = analysis.IsMSR(head[:])
+		outs  [][]output
+		chans []channelRow
+		hdr   *fileRow
 	)
 
 	switch {
 	case isMSR:
-		msr, err := msr.Parse(f)
+		if fill {
+			mopts = append(mopts, msr.WithGapFill())
+		}
+		file, jcts, err := parseMSR(r.MultipartForm.File["input-file"], mopts...)
+		if err != nil {
+			return err
+		}
+		rate, err := file.Rate()
+		if err != nil {
+			return fmt.Errorf("could not estimate sampling rate: %w", err)
+		}
+		chans = channels(file)
+		hdr = &fileRow{
+			Creator:   file.Creator,
+			Version:   file.Version,
+			Start:     file.Start.Format(time.RFC3339),
+			Modules:   file.Modules(),
+			Rate:      newRateRow(rate),
+			Files:     len(r.MultipartForm.File["input-file"]),
+			Junctions: newJunctionRows(jcts),
+			Check:     newCheckRow(msr.Check(file)),
+		}
+		names, err := analysis.SelectChannels(file, sel)
 		if err != nil {
-			return fmt.Errorf("could not parse MSR file: %w", err)
+			return err
+		}
+		log.Printf("channels: %s", strings.Join(names, ", "))
+		freq := rate.Nominal
+		ts := file.Axis()
+		if opts.Method == "lomb" {
+			// Lomb-Scargle works on the actual, possibly irregular, timestamps.
+			if vs := file.TimeSeries(); vs != nil {
+				ts = vs
+				opts.TScale = 1000
+			}
 		}
-		freq := msr.Freq()
-		ts := msr.Axis()
-		beg, end, err := clean(len(ts), xmin, xmax)
+		beg, end, err := analysis.Range(len(ts), xmin, xmax)
 		if err != nil {
 			return fmt.Errorf("could not infer data slice range: %w", err)
 		}
 		ts = ts[beg:end]
+		if opts.VC {
+			opts.AccScale, err = analysis.AccScale(accunit, analysis.AccUnit(file))
+			if err != nil {
+				return err
+			}
+		}
 		var (
 			grp errgroup.Group
 		)
-		imgs = make([][]byte, 3)
-		names = make([]string, 3)
-		for _, tt := range []struct {
-			id   int
-			name string
-			data []float64
-		}{
-			{0, "x", msr.AccX()[beg:end]},
-			{1, "y", msr.AccY()[beg:end]},
-			{2, "z", msr.AccZ()[beg:end]},
-		} {
-			tt := tt
+		outs = make([][]output, len(names))
+		for i, name := range names {
+			i, name := i, name
+			vs, _, err := file.Channel(name)
+			if err != nil {
+				return err
+			}
 			grp.Go(func() error {
-				img, err := srv.process(id, fname, tt.name, chunksz, ts, tt.data, freq)
+				out, err := srv.process(id, fname, analysis.AxisName(name), opts, ts, vs[beg:end], freq)
 				if err != nil {
-					return fmt.Errorf("could not process axis %s: %w", tt.name, err)
+					return fmt.Errorf("could not process channel %q: %w", name, err)
 				}
-				imgs[tt.id] = img
-				names[tt.id] = tt.name
+				outs[i] = out
 				return nil
 			})
 		}
@@ -287,44 +538,77 @@ func (srv *server) runHandle(w http.ResponseWriter, r *http.Request) error {
 			return fmt.Errorf("could not process MSR file: %w", err)
 		}
 
+	case len(r.MultipartForm.File["input-file"]) > 1:
+		return fmt.Errorf("only MSR files can be merged")
+
 	default:
-		xs, ys, err := fouracc.Load(f)
+		data, err := fouracc.LoadSeries(f, lopts...)
 		if err != nil {
 			log.Printf(">>> err load: %v", err)
 			return fmt.Errorf("could not load input file: %w", err)
 		}
-		beg, end, err := clean(len(xs), xmin, xmax)
+		beg, end, err := analysis.Range(len(data.Xs), xmin, xmax)
 		if err != nil {
 			return fmt.Errorf("could not infer data slice range: %w", err)
 		}
-		xs = xs[beg:end]
-		ys = ys[beg:end]
+		xs := data.Xs[beg:end]
+		ys := data.Ys[beg:end]
+		if opts.VC {
+			if data.Freq <= 0 {
+				return fmt.Errorf("vibration criteria need a sampling frequency: CSV file %q has no time column", fname)
+			}
+			opts.AccScale, err = analysis.AccScale(accunit, "")
+			if err != nil {
+				return err
+			}
+		}
 
-		img, err := srv.process(id, fname, "", chunksz, xs, ys, -1)
+		out, err := srv.process(id, fname, "", opts, xs, ys, data.Freq)
 		if err != nil {
 			return fmt.Errorf("could not process CSV file: %w", err)
 		}
-		imgs = append(imgs, img)
-		names = append(names, "")
+		outs = append(outs, out)
 	}
 
 	var (
-		stdimgs = make([]string, len(imgs))
+		stdimgs []string
+		names   []string
+		kinds   []string
+		vcs     []vcRow
+		cums    []cumRow
 	)
-	for i, img := range imgs {
-		stdimgs[i] = base64.StdEncoding.EncodeToString(img)
+	for _, out := range outs {
+		for _, o := range out {
+			stdimgs = append(stdimgs, base64.StdEncoding.EncodeToString(o.img))
+			names = append(names, o.axis)
+			kinds = append(kinds, o.kind)
+			vcs = append(vcs, o.vc...)
+			if o.cum != nil {
+				cums = append(cums, *o.cum)
+			}
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
 	err = json.NewEncoder(w).Encode(struct {
-		Names  []string `json:"names"`
-		Images []string `json:"imgs"`
-		Error  string   `json:"error"`
+		Names    []string     `json:"names"`
+		Kinds    []string     `json:"kinds"`
+		Images   []string     `json:"imgs"`
+		VC       []vcRow      `json:"vc"`
+		CumRMS   []cumRow     `json:"cumrms"`
+		File     *fileRow     `json:"file,omitempty"`
+		Channels []channelRow `json:"channels"`
+		Error    string       `json:"error"`
 	}{
-		Names:  names,
-		Images: stdimgs,
+		Names:    names,
+		Kinds:    kinds,
+		Images:   stdimgs,
+		VC:       vcs,
+		CumRMS:   cums,
+		File:     hdr,
+		Channels: chans,
 	})
 	if err != nil {
 		log.Printf(">>> err json encoder: %v", err)
@@ -352,11 +636,17 @@ func (srv *server) dlHandle(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	axis := r.Form.Get("axis")
-	switch axis {
-	case "", "x", "y", "z":
+	if axis != analysis.AxisName(axis) {
+		return fmt.Errorf("invalid axis %q", axis)
+	}
+
+	kind := r.Form.Get("kind")
+	switch kind {
+	case analysis.KindSpectrogram, analysis.KindEnvelope, analysis.KindSRS, analysis.KindOctave,
+		analysis.KindCumRMS, analysis.KindVC, analysis.KindWeighted, analysis.KindAnomaly:
 		// ok
 	default:
-		return fmt.Errorf("invalid axis %q", axis)
+		return fmt.Errorf("invalid kind %q", kind)
 	}
 
 	srv.mu.RLock()
@@ -368,9 +658,12 @@ func (srv *server) dlHandle(w http.ResponseWriter, r *http.Request) error {
 
 	dir := filepath.Join(srv.dir, "id", id)
 
-	glob := "*.csv"
+	glob := "*.processed.*.csv"
+	if kind != "" {
+		glob = "*." + kind + ".csv"
+	}
 	if axis != "" {
-		glob = "*-" + axis + ".processed.*.csv"
+		glob = "*-" + axis + glob[1:]
 	}
 
 	matches, err := filepath.Glob(filepath.Join(dir, glob))
@@ -441,7 +734,7 @@ func (srv *server) rmHandle(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-func (srv *server) save(dir, id, fname, axis string, img []byte, fft fouracc.FFT) error {
+func (srv *server) save(dir, id, fname, axis string, img []byte, spec fouracc.Spectrogram, cols [][]float64) error {
 	err := os.MkdirAll(dir, 0755)
 	if err != nil {
 		log.Printf("could not create output directory for results %s: %v", dir, err)
@@ -458,89 +751,368 @@ func (srv *server) save(dir, id, fname, axis string, img []byte, fft fouracc.FFT
 		return fmt.Errorf("could not save plot %q: %w", id, err)
 	}
 
-	oname := filepath.Join(dir, fmt.Sprintf("%s.processed.chunksz-%d.csv", bname, fft.Chunks))
+	var oname string
+	switch spec := spec.(type) {
+	case fouracc.CWT:
+		oname = filepath.Join(dir, fmt.Sprintf("%s.processed.cwt-%v.csv", bname, spec.Wavelet))
+	case fouracc.Multitaper:
+		oname = filepath.Join(dir, fmt.Sprintf("%s.processed.mtm-chunksz-%d-nw-%v.csv", bname, spec.Chunks, spec.NW))
+	case fouracc.Periodogram:
+		oname = filepath.Join(dir, fmt.Sprintf("%s.processed.lomb-chunksz-%d.csv", bname, spec.Chunks))
+	case fouracc.FFT:
+		oname = filepath.Join(dir, fmt.Sprintf("%s.processed.chunksz-%d.csv", bname, spec.Chunks))
+	}
 
-	tbl, err := csvutil.Create(oname)
+	err = analysis.WriteCSV(oname, "", cols...)
 	if err != nil {
-		log.Printf("could not create output data file %q: %v", oname, err)
-		return fmt.Errorf("could not create output data file %q: %w", id, err)
+		log.Printf("could not save output data file: %v", err)
+		return fmt.Errorf("could not save output data file %q", id)
 	}
-	defer tbl.Close()
 
-	tbl.Writer.Comma = '\t'
+	return nil
+}
 
-	for i, row := range fft.Coeffs {
-		args := make([]interface{}, len(row))
-		for i, v := range row {
-			args[i] = v
+// output is a plot produced by an analysis of a data set.
+type output struct {
+	axis string // axis of the analyzed data set
+	kind string // kind of analysis
+	img  []byte // PNG plot
+	vc   []vcRow
+	cum  *cumRow
+}
+
+// vcRow is a row of the vibration criteria summary table.
+type vcRow struct {
+	Axis      string    `json:"axis"`
+	T         float64   `json:"t"`
+	Class     string    `json:"class"`
+	Pass      bool      `json:"pass"`
+	Exceeding []float64 `json:"exceeding"` // centres of the bands exceeding the target curve
+}
+
+// cumRow is the summary of the cumulative RMS of an axis.
+type cumRow struct {
+	Axis   string        `json:"axis"`
+	RMS    float64       `json:"rms"`
+	Levels []energyLevel `json:"levels"`
+}
+
+// energyLevel holds the frequencies where a fraction of the total mean
+// square is reached.
+type energyLevel struct {
+	Level float64 `json:"level"`
+	Up    float64 `json:"up"`   // integrating from low to high frequencies
+	Down  float64 `json:"down"` // integrating from high to low frequencies
+}
+
+// process runs the analyses selected by opts on the time series of the
+// axis of the named file, and saves their plots and data tables.
+func (srv *server) process(id, fname, axis string, opts analysis.Options, xs, ys []float64, freq float64) ([]output, error) {
+	name := analysis.Name(fname, axis)
+	outs, err := analysis.Run(fname, axis, opts, xs, ys, freq)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		dir  = filepath.Join(srv.dir, "id", id)
+		rows = make([]output, 0, len(outs))
+	)
+	for _, out := range outs {
+		switch out.Kind {
+		case analysis.KindSpectrogram:
+			err = srv.save(dir, id, fname, axis, out.PNG, out.Spec, out.Cols)
+		default:
+			err = srv.writeCSV(dir, id, fname, axis, out.Kind, out.Header, out.Cols...)
 		}
-		err = tbl.WriteRow(args...)
 		if err != nil {
-			log.Printf("could not write row %d for output data file %q: %v", i, id, err)
-			return fmt.Errorf("could not write row %d for output data file %q: %w", i, id, err)
+			log.Printf("could not save report for %q: %v", name, err)
+			return nil, fmt.Errorf("could not save report for %q: %w", name, err)
+		}
+		if out.PNG == nil {
+			continue
+		}
+		row := output{axis: axis, kind: out.Kind, img: out.PNG}
+		if out.CumRMS != nil {
+			row.cum = newCumRow(axis, *out.CumRMS)
 		}
+		if out.VC != nil {
+			row.vc = newVCRows(axis, *out.VC)
+		}
+		rows = append(rows, row)
 	}
 
-	err = tbl.Close()
-	if err != nil {
-		log.Printf("could not close output data file %q: %v", id, err)
-		return fmt.Errorf("could not close output data file %q: %w", id, err)
+	log.Printf("processing %q... [done]", name)
+	return rows, nil
+}
+
+// newCumRow returns the summary of the cumulative RMS of an axis.
+func newCumRow(axis string, cum fouracc.CumRMS) *cumRow {
+	row := cumRow{Axis: axis, RMS: cum.RMS, Levels: make([]energyLevel, 0, len(cum.Levels))}
+	for i, lvl := range cum.Levels {
+		if math.IsNaN(cum.UpFreqs[i]) || math.IsNaN(cum.DownFreqs[i]) {
+			// no energy: JSON can not encode NaNs.
+			continue
+		}
+		row.Levels = append(row.Levels, energyLevel{Level: lvl, Up: cum.UpFreqs[i], Down: cum.DownFreqs[i]})
 	}
+	return &row
+}
 
-	return nil
+// newVCRows returns the rows of the vibration criteria summary table of an
+// axis, one per chunk.
+func newVCRows(axis string, vc fouracc.VC) []vcRow {
+	rows := make([]vcRow, len(vc.Class))
+	for i, class := range vc.Class {
+		rows[i] = vcRow{
+			Axis:      axis,
+			T:         vc.Ts[i],
+			Class:     class.String(),
+			Pass:      class >= vc.Target,
+			Exceeding: make([]float64, 0, len(vc.Exceeding[i])),
+		}
+		for _, j := range vc.Exceeding[i] {
+			rows[i].Exceeding = append(rows[i].Exceeding, vc.Bands.Centers[j])
+		}
+	}
+	return rows
 }
 
-func (srv *server) process(id, fname, axis string, chunksz int, xs, ys []float64, freq float64) ([]byte, error) {
-	name := fname
-	if axis != "" {
-		name += " [axis=" + axis + "]"
+// fileRow describes the header of a MSR file.
+type fileRow struct {
+	Creator string   `json:"creator"`
+	Version string   `json:"version"`
+	Start   string   `json:"start"`
+	Modules []string `json:"modules"`
+	Rate    rateRow  `json:"rate"`
+
+	Files     int           `json:"files"`               // number of merged files
+	Junctions []junctionRow `json:"junctions,omitempty"` // boundaries between merged files
+
+	Check checkRow `json:"check"` // data-integrity report
+}
+
+// checkRow describes the data-integrity report of a MSR file.
+type checkRow struct {
+	Samples  int        `json:"samples"`
+	Interval float64    `json:"interval"` // s
+	Issues   []issueRow `json:"issues"`
+}
+
+// issueRow describes a data-integrity issue of a MSR file.
+type issueRow struct {
+	Kind    string `json:"kind"`
+	Channel string `json:"channel,omitempty"`
+	Beg     int    `json:"beg"`
+	End     int    `json:"end"`
+	Start   string `json:"start"`
+	Stop    string `json:"stop"`
+	Msg     string `json:"msg"`
+}
+
+func newCheckRow(rep msr.Report) checkRow {
+	row := checkRow{
+		Samples:  rep.Samples,
+		Interval: rep.Interval.Seconds(),
+		Issues:   make([]issueRow, len(rep.Issues)),
+	}
+	for i, is := range rep.Issues {
+		row.Issues[i] = issueRow{
+			Kind:    is.Kind.String(),
+			Channel: is.Channel,
+			Beg:     is.Beg,
+			End:     is.End,
+			Start:   is.Start.Format(time.RFC3339Nano),
+			Stop:    is.Stop.Format(time.RFC3339Nano),
+			Msg:     is.Msg,
+		}
 	}
+	return row
+}
 
-	log.Printf("processing %q...", name)
+// junctionRow describes the boundary between two merged MSR files.
+type junctionRow struct {
+	Index   int     `json:"index"`
+	Time    string  `json:"time"`
+	Gap     float64 `json:"gap"` // s
+	Missing int     `json:"missing"`
+	Filled  int     `json:"filled"`
+	Dropped int     `json:"dropped"`
+}
 
-	fft := fouracc.ChunkedFFT(name, chunksz, xs, ys, freq)
+func newJunctionRows(jcts []msr.Junction) []junctionRow {
+	rows := make([]junctionRow, len(jcts))
+	for i, jct := range jcts {
+		rows[i] = junctionRow{
+			Index:   jct.Index,
+			Time:    jct.Time.Format(time.RFC3339Nano),
+			Gap:     jct.Gap.Seconds(),
+			Missing: jct.Missing,
+			Filled:  jct.Filled,
+			Dropped: jct.Dropped,
+		}
+	}
+	return rows
+}
 
-	const (
-		width  = 20 * vg.Centimeter
-		height = 30 * vg.Centimeter
-	)
+// uploadName returns the name of the uploaded file, without the fake path
+// some browsers prepend to it.
+func uploadName(fh *multipart.FileHeader) string {
+	return strings.TrimPrefix(fh.Filename, `C:\fakepath\`)
+}
 
-	c := vgimg.PngCanvas{Canvas: vgimg.New(width, height)}
-	err := fouracc.Plot(draw.New(c), fft)
-	if err != nil {
-		return nil, fmt.Errorf("could not plot FFT: %w", err)
+// parseMSR parses the uploaded MSR files, merging them into one continuous
+// file if more than one.
+func parseMSR(fhs []*multipart.FileHeader, opts ...msr.Option) (msr.File, []msr.Junction, error) {
+	files := make([]msr.File, len(fhs))
+	for i, fh := range fhs {
+		f, err := fh.Open()
+		if err != nil {
+			return msr.File{}, nil, fmt.Errorf("could not open MSR file %q: %w", fh.Filename, err)
+		}
+		files[i], err = analysis.ReadMSR(fh.Filename, f, opts...)
+		f.Close()
+		if err != nil {
+			return msr.File{}, nil, err
+		}
+	}
+	if len(files) == 1 {
+		return files[0], nil, nil
 	}
 
-	o := new(bytes.Buffer)
-	_, err = c.WriteTo(o)
+	f, jcts, err := msr.Merge(files, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("could not create output plot: %w", err)
+		return f, nil, fmt.Errorf("could not merge MSR files: %w", err)
 	}
+	log.Printf("merge: %d files, %d junction(s)", len(files), len(jcts))
+	return f, jcts, nil
+}
 
-	dir := filepath.Join(srv.dir, "id", id)
-	err = srv.save(dir, id, fname, axis, o.Bytes(), fft)
-	if err != nil {
-		log.Printf("could not save report for %q: %v", name, err)
-		return nil, fmt.Errorf("could not save report for %q: %w", name, err)
+// rateRow describes the sampling rate of a MSR file.
+type rateRow struct {
+	Nominal  float64         `json:"nominal"`  // Hz
+	Measured float64         `json:"measured"` // Hz
+	Jitter   float64         `json:"jitter"`   // s
+	Outliers int             `json:"outliers"`
+	Changes  []rateChangeRow `json:"changes"`
+}
+
+type rateChangeRow struct {
+	Index int     `json:"index"`
+	Time  string  `json:"time"`
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+}
+
+func newRateRow(rate msr.Rate) rateRow {
+	row := rateRow{
+		Nominal:  rate.Nominal,
+		Measured: rate.Measured,
+		Jitter:   rate.Jitter.Seconds(),
+		Outliers: rate.Outliers,
+		Changes:  make([]rateChangeRow, len(rate.Changes)),
 	}
+	for i, c := range rate.Changes {
+		row.Changes[i] = rateChangeRow{
+			Index: c.Index,
+			Time:  c.Time.Format(time.RFC3339Nano),
+			From:  c.From,
+			To:    c.To,
+		}
+	}
+	return row
+}
 
-	log.Printf("processing %q... [done]", name)
-	return o.Bytes(), nil
+// channelRow describes a channel of a MSR file, with its limits, time delay
+// and calibration.
+// Levels and calibration points not set in the file are omitted.
+type channelRow struct {
+	Name       string     `json:"name"`
+	Unit       string     `json:"unit"`
+	Sensor     string     `json:"sensor"`
+	Serial     string     `json:"serial"`
+	Limits     *limitsRow `json:"limits,omitempty"`
+	Calib      *calibRow  `json:"calibration,omitempty"`
+	Calibrated bool       `json:"calibrated"`
+	TimeDelay  float64    `json:"time-delay"` // s
+	Aligned    bool       `json:"aligned"`
+}
+
+type limitsRow struct {
+	Alarm    *float64 `json:"alarm,omitempty"`
+	Recorded *float64 `json:"recorded,omitempty"`
+	Limit1   *float64 `json:"limit1,omitempty"`
+	Limit2   *float64 `json:"limit2,omitempty"`
+}
+
+type calibRow struct {
+	Info string  `json:"info"`
+	Date string  `json:"date,omitempty"`
+	X0   float64 `json:"x0"`
+	Y0   float64 `json:"y0"`
+	X1   float64 `json:"x1"`
+	Y1   float64 `json:"y1"`
 }
 
-func clean(len, beg, end int) (int, int, error) {
-	if end == -1 {
-		end = len
+// channels returns the description of the data channels of the MSR file.
+func channels(f msr.File) []channelRow {
+	level := func(v float64) *float64 {
+		if math.IsNaN(v) {
+			return nil
+		}
+		return &v
 	}
-	switch {
-	case end > len:
-		return beg, end, fmt.Errorf("invalid data range (end=%d > len=%d)", end, len)
-	case beg > end:
-		return beg, end, fmt.Errorf("invalid data range (beg=%d > end=%d)", beg, end)
-	case beg > len:
-		return beg, end, fmt.Errorf("invalid data range (beg=%d > len=%d)", end, len)
+	var rows []channelRow
+	for _, col := range f.Cols[1:] {
+		row := channelRow{
+			Name:       col.Name,
+			Unit:       col.Unit,
+			Sensor:     col.Sensor,
+			Serial:     col.SensorID,
+			Calibrated: col.Calibrated,
+			TimeDelay:  col.TimeDelay.Seconds(),
+			Aligned:    col.Aligned,
+		}
+		if lim := col.Limits; lim.IsSet() {
+			row.Limits = &limitsRow{
+				Alarm:    level(lim.Alarm),
+				Recorded: level(lim.Recorded),
+				Limit1:   level(lim.Limit1),
+				Limit2:   level(lim.Limit2),
+			}
+		}
+		if cal := col.CalibData; cal.IsValid() {
+			row.Calib = &calibRow{
+				Info: cal.Info,
+				X0:   cal.X0,
+				Y0:   cal.Y0,
+				X1:   cal.X1,
+				Y1:   cal.Y1,
+			}
+			if !cal.Date.IsZero() {
+				row.Calib.Date = cal.Date.Format("2006-01-02")
+			}
+		}
+		rows = append(rows, row)
 	}
-	return beg, end, nil
+	return rows
+}
+
+// writeCSV saves the provided columns as a tab-separated table, for the
+// provided kind of analysis.
+func (srv *server) writeCSV(dir, id, fname, axis, kind, hdr string, cols ...[]float64) error {
+	bname := fname[:len(fname)-len(filepath.Ext(fname))]
+	if axis != "" {
+		bname += "-" + axis
+	}
+	oname := filepath.Join(dir, bname+"."+kind+".csv")
+
+	err := analysis.WriteCSV(oname, hdr, cols...)
+	if err != nil {
+		log.Printf("could not save output data file: %v", err)
+		return fmt.Errorf("could not save output data file %q", id)
+	}
+	return nil
 }
 
 const page = `<html>
@@ -608,20 +1180,94 @@ const page = `<html>
 	function run() {
 		var id = uuidv4();
 
-		var file = $("#app-form input")[0].files[0];
+		var files = $("#input-file")[0].files;
 		var uri = $("#input-file").val();
 		//$("#input-file").val("");
 		
 		var chunks = $("#chunksz").val();
 		var xmin = $("#xmin").val();
 		var xmax = $("#xmax").val();
+		var col = $("#col").val();
+		var timecol = $("#time-col").val();
+		var csvsep = $("#csv-sep").val();
+		var csvcomment = $("#csv-comment").val();
+		var csvdecimal = $("#csv-decimal").val();
+		var csvheader = $("#csv-header").val();
+		var csvquotes = $("#csv-quotes").is(":checked");
+		var csvskipblank = $("#csv-skip-blank").is(":checked");
+		var csvskiptrailing = $("#csv-skip-trailing").is(":checked");
+		var env = $("#env").val();
+		var method = $("#method").val();
+		var wavelet = $("#wavelet").val();
+		var scales = $("#scales").val();
+		var nscales = $("#nscales").val();
+		var nw = $("#nw").val();
+		var octave = $("#octave").val();
+		var cumrms = $("#cumrms").is(":checked");
+		var calibrate = $("#calibrate").is(":checked");
+		var align = $("#align").is(":checked");
+		var tz = $("#tz").val();
+		var channels = $("#channels").val();
+		var mergefill = $("#merge-fill").is(":checked");
+		var vc = $("#vc").val();
+		var accunit = $("#acc-unit").val();
+		var weighting = $("#weighting").val();
+		var tau = $("#tau").val();
+		var baselines = $("#baseline-file")[0].files;
+		var baselinekind = $("#baseline-kind").val();
+		var pct = $("#pct").val();
+		var threshold = $("#threshold").val();
+		var srs = $("#srs").val();
+		var srsq = $("#srs-q").val();
+		var srskind = $("#srs-kind").val();
+		var srsfreqs = $("#srs-freqs").val();
+		var tapers = $("#tapers").val();
 		var data = new FormData();
 		data.append("chunksz", chunks);
 		data.append("uri", uri);
-		data.append("input-file", file, uri);
+		for (var i = 0; i < files.length; i++) {
+			data.append("input-file", files[i], files[i].name);
+		}
 		data.append("id", id);
 		data.append("xmin", xmin);
 		data.append("xmax", xmax);
+		data.append("col", col);
+		data.append("time-col", timecol);
+		data.append("csv-sep", csvsep);
+		data.append("csv-comment", csvcomment);
+		data.append("csv-decimal", csvdecimal);
+		data.append("csv-header", csvheader);
+		data.append("csv-quotes", csvquotes);
+		data.append("csv-skip-blank", csvskipblank);
+		data.append("csv-skip-trailing", csvskiptrailing);
+		data.append("env", env);
+		data.append("method", method);
+		data.append("wavelet", wavelet);
+		data.append("scales", scales);
+		data.append("nscales", nscales);
+		data.append("nw", nw);
+		data.append("octave", octave);
+		data.append("cumrms", cumrms);
+		data.append("calibrate", calibrate);
+		data.append("align", align);
+		data.append("tz", tz);
+		data.append("channels", channels);
+		data.append("merge-fill", mergefill);
+		data.append("vc", vc);
+		data.append("acc-unit", accunit);
+		data.append("weighting", weighting);
+		data.append("tau", tau);
+		for (var i = 0; i < baselines.length; i++) {
+			data.append("baseline-file", baselines[i], baselines[i].name);
+		}
+		data.append("baseline-kind", baselinekind);
+		data.append("pct", pct);
+		data.append("threshold", threshold);
+		data.append("srs", srs);
+		data.append("srs-q", srsq);
+		data.append("srs-kind", srskind);
+		data.append("srs-freqs", srsfreqs);
+		data.append("tapers", tapers);
 
 		plotPlaceholder(id);
 
@@ -678,16 +1324,94 @@ const page = `<html>
 			if (data.names[i] != "") {
 				button = "Download "+data.names[i]+"-axis";
 			}
+			if (data.kinds[i] != "") {
+				button += " ("+data.kinds[i]+")";
+			}
 			node.append(
 				"<br>\n"
 				+"<div>\n"
 				+"<img src=\"data:image/png;base64, "+ v + "\" />"
 				+"<form>\n"
-				+" <input type=\"button\" value=\""+button+"\" onclick=\"window.location.href='/dl?id="+id+"&axis="+data.names[i]+"'\"/>\n"
+				+" <input type=\"button\" value=\""+button+"\" onclick=\"window.location.href='/dl?id="+id+"&axis="+data.names[i]+"&kind="+data.kinds[i]+"'\"/>\n"
 				+"</form>\n"
 				+"</div>\n"
 			);
 		});
+		if (data.file) {
+			node.append("<br>\n<p class=\"w3-small\">"+data.file.creator+" "+data.file.version
+				+" -- start: "+data.file.start+" -- modules: "+data.file.modules.join(", ")+"</p>\n");
+			var rate = data.file.rate;
+			var changes = rate.changes.map(function(c) {
+				return c.from.toPrecision(6)+" Hz -> "+c.to.toPrecision(6)+" Hz at sample "+c.index+" ("+c.time+")";
+			});
+			node.append("<p class=\"w3-small\">rate: nominal="+rate.nominal.toPrecision(6)+" Hz, measured="+rate.measured.toPrecision(6)
+				+" Hz, jitter="+(1e3*rate.jitter).toPrecision(3)+" ms, outliers="+rate.outliers
+				+(changes.length > 0 ? " -- rate changes: "+changes.join("; ") : "")+"</p>\n");
+			if (data.file.junctions && data.file.junctions.length > 0) {
+				var jcts = data.file.junctions.map(function(j) {
+					var msg = "contiguous";
+					if (j.dropped > 0) {
+						msg = "overlap of "+(-j.gap).toPrecision(4)+" s, "+j.dropped+" sample(s) dropped";
+					} else if (j.missing > 0) {
+						msg = "gap of "+j.gap.toPrecision(4)+" s, "+j.missing+" missing sample(s), "+j.filled+" filled";
+					}
+					return "sample "+j.index+" ("+j.time+"): "+msg;
+				});
+				node.append("<p class=\"w3-small\">merged "+data.file.files+" files -- junctions: "+jcts.join("; ")+"</p>\n");
+			}
+			var check = data.file.check;
+			var issues = check.issues.slice(0, 10).map(function(is) {
+				return is.kind+(is.channel ? " ["+is.channel+"]" : "")+" at samples ["+is.beg+", "+is.end+"): "+is.msg;
+			});
+			if (check.issues.length > issues.length) {
+				issues.push("... and "+(check.issues.length-issues.length)+" more");
+			}
+			node.append("<p class=\"w3-small\">integrity: "+check.issues.length+" issue(s) over "+check.samples
+				+" samples (interval="+(1e3*check.interval).toPrecision(4)+" ms)"
+				+(issues.length > 0 ? " -- "+issues.join("; ") : "")+"</p>\n");
+		}
+		if (data.channels && data.channels.length > 0) {
+			var fmt = function(v) { return v === undefined ? "" : v; };
+			var tbl = "<br>\n<table class=\"w3-table w3-bordered w3-small\">\n"
+				+"<tr><th>channel</th><th>unit</th><th>sensor</th><th>serial</th><th>alarm</th><th>recorded</th><th>limit 1</th><th>limit 2</th><th>time delay (s)</th><th>calibration</th></tr>\n";
+			data.channels.forEach(function(v) {
+				var lim = v.limits || {};
+				var cal = "";
+				if (v.calibration) {
+					var c = v.calibration;
+					cal = "("+c.x0+","+c.y0+")-("+c.x1+","+c.y1+")";
+					if (c.date) { cal += " "+c.date; }
+					if (c.info) { cal += " "+c.info; }
+					cal += v.calibrated ? " [applied]" : " [not applied]";
+				}
+				var delay = v["time-delay"] == 0 ? "" : v["time-delay"]+(v.aligned ? " [aligned]" : " [not aligned]");
+				tbl += "<tr><td>"+v.name+"</td><td>"+v.unit+"</td><td>"+v.sensor+"</td><td>"+v.serial+"</td><td>"+fmt(lim.alarm)+"</td><td>"+fmt(lim.recorded)+"</td><td>"+fmt(lim.limit1)+"</td><td>"+fmt(lim.limit2)+"</td><td>"+delay+"</td><td>"+cal+"</td></tr>\n";
+			});
+			tbl += "</table>\n";
+			node.append(tbl);
+		}
+		if (data.cumrms && data.cumrms.length > 0) {
+			var tbl = "<br>\n<table class=\"w3-table w3-bordered w3-small\">\n"
+				+"<tr><th>axis</th><th>RMS</th><th>energy</th><th>low to high (Hz)</th><th>high to low (Hz)</th></tr>\n";
+			data.cumrms.forEach(function(v) {
+				v.levels.forEach(function(l) {
+					tbl += "<tr><td>"+v.axis+"</td><td>"+v.rms.toPrecision(4)+"</td><td>"+(100*l.level)+"%</td><td>"+l.up.toPrecision(4)+"</td><td>"+l.down.toPrecision(4)+"</td></tr>\n";
+				});
+			});
+			tbl += "</table>\n";
+			node.append(tbl);
+		}
+		if (data.vc && data.vc.length > 0) {
+			var tbl = "<br>\n<table class=\"w3-table w3-bordered w3-small\">\n"
+				+"<tr><th>axis</th><th>t</th><th>VC class</th><th>status</th><th>exceeding bands (Hz)</th></tr>\n";
+			data.vc.forEach(function(v) {
+				var bands = v.exceeding.map(function(f) { return f.toPrecision(3); }).join(", ");
+				var status = v.pass ? "PASS" : "<b>FAIL</b>";
+				tbl += "<tr><td>"+v.axis+"</td><td>"+v.t+"</td><td>"+v.class+"</td><td>"+status+"</td><td>"+bands+"</td></tr>\n";
+			});
+			tbl += "</table>\n";
+			node.append(tbl);
+		}
 		updateHeight();
 	};
 
@@ -735,14 +1459,127 @@ const page = `<html>
 	<div>
 		<form id="app-form" enctype="multipart/form-data">
 			File:
-			<input id="input-file" type="file" name="input-file"/>
+			<input id="input-file" type="file" name="input-file" multiple/>
+			<br>
+			Method:
+			<select id="method" name="method">
+				<option value="stft" selected>STFT</option>
+				<option value="cwt">CWT</option>
+				<option value="mtm">Multitaper</option>
+				<option value="lomb">Lomb-Scargle</option>
+			</select>
 			<br>
 			Chunk size: <input id="chunksz" type="number" name="chunksz" min="1"  value="256">
 			<br>
+			Wavelet:
+			<select id="wavelet" name="wavelet">
+				<option value="morlet" selected>Morlet</option>
+				<option value="mexhat">Mexican hat</option>
+				<option value="paul">Paul</option>
+			</select>
+			<br>
+			Scales (s): <input id="scales" type="text" name="scales" placeholder="smin:smax" value="">
+			<br>
+			Nb scales: <input id="nscales" type="number" name="nscales" min="1"  value="64">
+			<br>
+			Multitaper NW: <input id="nw" type="number" name="nw" min="1" step="0.5" value="4">
+			<br>
+			Nb tapers: <input id="tapers" type="number" name="tapers" min="0"  value="0">
+			<br>
 			x-min: <input id="xmin" type="number" name="xmin" min="0"  value="0">
 			<br>
 			x-max: <input id="xmax" type="number" name="xmax" min="-1"  value="-1">
 			<br>
+			CSV column: <input id="col" type="text" name="col" placeholder="name or index" value="">
+			<br>
+			CSV time column: <input id="time-col" type="text" name="time-col" placeholder="name, index or none" value="">
+			<br>
+			CSV separator: <input id="csv-sep" type="text" name="csv-sep" placeholder="sniffed if empty (e.g. ; or tab)" value="">
+			<br>
+			CSV comment prefix: <input id="csv-comment" type="text" name="csv-comment" placeholder="#" value="">
+			<br>
+			CSV decimal separator:
+			<select id="csv-decimal" name="csv-decimal">
+				<option value="." selected>.</option>
+				<option value=",">,</option>
+			</select>
+			<br>
+			CSV header rows: <input id="csv-header" type="number" name="csv-header" min="-1" value="-1">
+			<br>
+			CSV quotes: <input id="csv-quotes" type="checkbox" name="csv-quotes" checked>
+			skip blank lines: <input id="csv-skip-blank" type="checkbox" name="csv-skip-blank" checked>
+			skip trailing fields: <input id="csv-skip-trailing" type="checkbox" name="csv-skip-trailing">
+			<br>
+			Envelope band (Hz): <input id="env" type="text" name="env" placeholder="lo:hi" value="">
+			<br>
+			VC target:
+			<select id="vc" name="vc">
+				<option value="" selected>none</option>
+				<option value="A">VC-A</option>
+				<option value="B">VC-B</option>
+				<option value="C">VC-C</option>
+				<option value="D">VC-D</option>
+				<option value="E">VC-E</option>
+			</select>
+			<br>
+			Acc. unit: <input id="acc-unit" type="text" name="acc-unit" placeholder="g, mg, m/s2" value="">
+			<br>
+			Octave bands:
+			<select id="octave" name="octave">
+				<option value="" selected>none</option>
+				<option value="1">1/1</option>
+				<option value="3">1/3</option>
+				<option value="6">1/6</option>
+				<option value="12">1/12</option>
+			</select>
+			<br>
+			Cumulative RMS: <input id="cumrms" type="checkbox" name="cumrms">
+			<br>
+			Apply MSR calibration: <input id="calibrate" type="checkbox" name="calibrate">
+			<br>
+			Align MSR channels on their time delay: <input id="align" type="checkbox" name="align" checked>
+			<br>
+			MSR time zone: <input id="tz" type="text" name="tz" placeholder="e.g. Europe/Paris, +02:00" value="">
+			<br>
+			MSR channels: <input id="channels" type="text" name="channels" placeholder="e.g. ACC x, TEMP, all (empty for ACC)" value="">
+			<br>
+			Fill gaps between merged MSR files: <input id="merge-fill" type="checkbox" name="merge-fill">
+			<br>
+			Weighting:
+			<select id="weighting" name="weighting">
+				<option value="" selected>none</option>
+				<option value="wk">Wk</option>
+				<option value="wd">Wd</option>
+				<option value="wc">Wc</option>
+				<option value="wm">Wm</option>
+			</select>
+			<br>
+			Running RMS tau (s): <input id="tau" type="number" name="tau" min="0.125" step="0.125" value="1">
+			<br>
+			Baseline files: <input id="baseline-file" type="file" name="baseline-file" multiple/>
+			<br>
+			Baseline:
+			<select id="baseline-kind" name="baseline-kind">
+				<option value="mean" selected>mean &plusmn; sigma</option>
+				<option value="pct">percentiles</option>
+			</select>
+			<br>
+			Percentile: <input id="pct" type="number" name="pct" min="51" max="99.9" step="0.1" value="95">
+			<br>
+			Threshold: <input id="threshold" type="text" name="threshold" placeholder="6dB, 3sigma" value="6dB">
+			<br>
+			SRS window (s): <input id="srs" type="text" name="srs" placeholder="t0:t1" value="">
+			<br>
+			SRS Q: <input id="srs-q" type="number" name="srs-q" min="0.5" step="0.5" value="10">
+			<br>
+			SRS kind:
+			<select id="srs-kind" name="srs-kind">
+				<option value="acc" selected>absolute acceleration</option>
+				<option value="pv">pseudo-velocity</option>
+			</select>
+			<br>
+			SRS frequencies (Hz): <input id="srs-freqs" type="text" name="srs-freqs" placeholder="fmin:fmax" value="">
+			<br>
 			<input type="button" onclick="run()" value="Run">
 		</form>
 
@@ -0,0 +1,334 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lsst-lpc/fouracc"
+	"github.com/lsst-lpc/fouracc/internal/analysis"
+	"github.com/lsst-lpc/fouracc/msr"
+)
+
+// runConvert runs the "fouracc convert in.csv out.csv" command, trimming
+// data files and converting them between the MSR and plain CSV formats.
+func runConvert(args []string) {
+	var (
+		fset    = flag.NewFlagSet("convert", flag.ExitOnError)
+		to      = fset.String("to", "", "output format (msr, csv; empty for the other format than the input one)")
+		trim    = fset.String("trim", "", "time range t0:t1 (s since the first sample) of the samples to keep (empty to keep all)")
+		chans   = fset.String("channels", "all", "comma-separated list of MSR channels to keep (empty for the ACC channels, all for all channels)")
+		calib   = fset.Bool("calibrate", false, "apply the two-point calibration of the MSR channels to their data")
+		align   = fset.Bool("align", true, "shift the MSR channels by their time delay onto a common time base")
+		tz      = fset.String("tz", "", "time zone of the MSR timestamps (e.g. Local, Europe/Paris, +02:00; empty for the zone of the file, or UTC)")
+		start   = fset.String("start", "", "start time (RFC3339) of plain CSV files without timestamps (empty for 1970-01-01T00:00:00Z)")
+		freq    = fset.Float64("freq", 0, "sampling frequency (Hz) of plain CSV files without time column")
+		name    = fset.String("name", "", "channel name of plain CSV data (empty for the column name, or ACC x)")
+		unit    = fset.String("unit", "", "unit of plain CSV data")
+		csvopts = csvFlags(fset)
+	)
+	fset.Usage = func() {
+		fmt.Fprintf(fset.Output(), "Usage: fouracc convert [options] in.csv out.csv\n\nOptions:\n")
+		fset.PrintDefaults()
+	}
+
+	err := fset.Parse(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if fset.NArg() != 2 {
+		fset.Usage()
+		log.Fatalf("convert needs an input and an output file, got %d file(s)", fset.NArg())
+	}
+
+	lopts, err := csvopts()
+	if err != nil {
+		log.Fatalf("could not parse CSV dialect: %v", err)
+	}
+
+	var loc *time.Location
+	if *tz != "" {
+		loc, err = msr.ParseZone(*tz)
+		if err != nil {
+			log.Fatalf("could not parse time zone: %v", err)
+		}
+	}
+
+	t0 := time.Unix(0, 0).UTC()
+	if *start != "" {
+		t0, err = time.Parse(time.RFC3339Nano, *start)
+		if err != nil {
+			log.Fatalf("could not parse start time: %v", err)
+		}
+	}
+
+	f, data, err := convertInput(fset.Arg(0), *calib, *align, loc, lopts)
+	if err != nil {
+		log.Fatalf("could not read %q: %v", fset.Arg(0), err)
+	}
+	isMSR := data == nil
+	if !isMSR {
+		f, err = plainFile(*data, t0, *freq, loc)
+		if err != nil {
+			log.Fatalf("could not convert %q: %v", fset.Arg(0), err)
+		}
+		if *name != "" {
+			f.Cols[1].Name = *name
+		}
+		f.Cols[1].Unit = *unit
+	}
+
+	format := *to
+	switch format {
+	case "":
+		format = "msr"
+		if isMSR {
+			format = "csv"
+		}
+	case "msr", "csv":
+		// ok
+	default:
+		log.Fatalf("invalid output format %q", format)
+	}
+
+	names, err := analysis.SelectChannels(f, *chans)
+	if err != nil {
+		log.Fatal(err)
+	}
+	f, err = f.Select(names...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *trim != "" {
+		t0, t1, err := analysis.ParseBand(*trim)
+		if err != nil {
+			log.Fatalf("could not parse time range: %v", err)
+		}
+		beg, end := trimRange(f, t0, t1)
+		f, err = f.Slice(beg, end)
+		if err != nil {
+			log.Fatalf("could not trim data: %v", err)
+		}
+	}
+
+	ts, _ := f.Times()
+	log.Printf("convert:    %s -> %s (%s)", fset.Arg(0), fset.Arg(1), format)
+	log.Printf("channels:   %s", strings.Join(names, ", "))
+	log.Printf("samples:    %d", len(ts))
+
+	o, err := os.Create(fset.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer o.Close()
+
+	switch format {
+	case "msr":
+		err = msr.Write(o, f)
+	case "csv":
+		err = writePlain(o, f)
+	}
+	if err != nil {
+		log.Fatalf("could not write %q: %v", fset.Arg(1), err)
+	}
+
+	err = o.Close()
+	if err != nil {
+		log.Fatalf("could not close %q: %v", fset.Arg(1), err)
+	}
+}
+
+// convertInput reads the named MSR file, or else the named plain CSV file
+// whose data are then returned instead.
+func convertInput(fname string, calib, align bool, loc *time.Location, lopts []fouracc.LoadOption) (msr.File, *fouracc.Series, error) {
+	r, err := os.Open(fname)
+	if err != nil {
+		return msr.File{}, nil, err
+	}
+	defer r.Close()
+
+	var head [64]byte
+	_, err = io.ReadFull(r, head[:])
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return msr.File{}, nil, fmt.Errorf("could not read CSV header: %w", err)
+	}
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return msr.File{}, nil, fmt.Errorf("could not rewind CSV file: %w", err)
+	}
+
+	if !strings.HasPrefix(string(head[:]), "*CREATOR") {
+		data, err := fouracc.LoadSeries(r, lopts...)
+		if err != nil {
+			return msr.File{}, nil, fmt.Errorf("could not load CSV file: %w", err)
+		}
+		log.Printf("csv:        %v", data.Dialect)
+		return msr.File{}, &data, nil
+	}
+
+	var mopts []msr.Option
+	if calib {
+		mopts = append(mopts, msr.WithCalibration())
+	}
+	if align {
+		mopts = append(mopts, msr.WithAlignment())
+	}
+	if loc != nil {
+		mopts = append(mopts, msr.WithLocation(loc))
+	}
+	f, err := msr.Parse(r, mopts...)
+	if err != nil {
+		return f, nil, fmt.Errorf("could not parse MSR file: %w", err)
+	}
+	return f, nil, nil
+}
+
+// plainFile returns the MSR file holding the time series of a plain CSV
+// file, as a single channel named after its column (or "ACC x").
+// Timestamps start at the first RFC3339 timestamp of the CSV file, or
+// else at t0.
+// Files without time column are sampled at freq.
+// Empty cells are forward-filled, as in MSR files.
+func plainFile(data fouracc.Series, t0 time.Time, freq float64, loc *time.Location) (msr.File, error) {
+	if len(data.Xs) == 0 {
+		return msr.File{}, fmt.Errorf("no data")
+	}
+
+	start := t0
+	if !data.Start.IsZero() {
+		start = data.Start
+	}
+	var (
+		ts     = make([]time.Time, len(data.Xs))
+		ys     = make([]float64, len(data.Ys))
+		filled []int
+		nan    = math.NaN()
+	)
+	for i, x := range data.Xs {
+		var dt float64 // time since the first sample (s)
+		switch {
+		case data.Freq > 0:
+			dt = x - data.Xs[0]
+		case freq > 0:
+			dt = float64(i) / freq
+		default:
+			return msr.File{}, fmt.Errorf("no time column: the sampling frequency is needed (see -freq)")
+		}
+		ts[i] = start.Add(time.Duration(math.Round(dt * 1e9)))
+
+		ys[i] = data.Ys[i]
+		if math.IsNaN(ys[i]) {
+			ys[i] = 0
+			if i > 0 {
+				ys[i] = ys[i-1]
+			}
+			filled = append(filled, i)
+		}
+	}
+
+	name := data.Column
+	if name == "" {
+		name = "ACC x"
+	}
+	f := msr.File{
+		Creator:  "fouracc",
+		Start:    ts[0].Truncate(time.Second),
+		Location: start.Location(),
+		Cols: []msr.Column{
+			{Name: "Time", Data: ts},
+			{
+				Name:      name,
+				Data:      ys,
+				Filled:    filled,
+				Limits:    msr.Limits{Alarm: nan, Recorded: nan, Limit1: nan, Limit2: nan},
+				CalibData: msr.CalibData{X0: nan, Y0: nan, X1: nan, Y1: nan},
+			},
+		},
+	}
+	if loc != nil {
+		f.Location = loc
+	}
+	return f, nil
+}
+
+// trimRange returns the range [beg, end) of the samples of the file whose
+// times since the first sample are within [t0, t1) seconds.
+// A non-positive t1 keeps all the samples after t0.
+func trimRange(f msr.File, t0, t1 float64) (beg, end int) {
+	ts, _ := f.Times()
+	if len(ts) == 0 {
+		return 0, 0
+	}
+	end = len(ts)
+	for i, t := range ts {
+		dt := t.Sub(ts[0]).Seconds()
+		if dt < t0 {
+			beg = i + 1
+		}
+		if t1 > 0 && dt >= t1 {
+			end = i
+			break
+		}
+	}
+	if beg > end {
+		beg = end
+	}
+	return beg, end
+}
+
+// writePlain writes the data channels of the MSR file as a plain CSV file,
+// with a header row and a column of RFC3339 timestamps.
+func writePlain(w io.Writer, f msr.File) error {
+	ts, err := f.Times()
+	if err != nil {
+		return err
+	}
+	var (
+		chs = f.Channels()
+		vs  = make([][]float64, len(chs))
+		rec = make([]string, 1+len(chs))
+		loc = f.Location
+	)
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	rec[0] = "time"
+	for i, col := range chs {
+		rec[i+1] = col.Name
+		vs[i], _, err = f.Channel(col.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	o := csv.NewWriter(w)
+	err = o.Write(rec)
+	if err != nil {
+		return fmt.Errorf("could not write CSV header: %w", err)
+	}
+	for j, t := range ts {
+		rec[0] = t.In(loc).Format(time.RFC3339Nano)
+		for i := range vs {
+			rec[i+1] = strconv.FormatFloat(vs[i][j], 'f', -1, 64)
+		}
+		err = o.Write(rec)
+		if err != nil {
+			return fmt.Errorf("could not write CSV row %d: %w", j, err)
+		}
+	}
+	o.Flush()
+	return o.Error()
+}
@@ -0,0 +1,153 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+
+	"github.com/lsst-lpc/fouracc"
+	"github.com/lsst-lpc/fouracc/internal/analysis"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// runDiff runs the "fouracc diff a.csv b.csv" command, comparing the
+// spectrograms of two data files.
+func runDiff(args []string) {
+	var (
+		fset    = flag.NewFlagSet("diff", flag.ExitOnError)
+		chunksz = fset.Int("chunks", 256, "chunk size of Fourier processing")
+		xmin    = fset.Int("xmin", 0, "start of analysis range index")
+		xmax    = fset.Int("xmax", -1, "end of analysis range index")
+		rel     = fset.Bool("rel", false, "pair chunks on their time relative to the start of each file, instead of in order")
+		chans   = fset.String("channels", "", "comma-separated list of MSR channels to compare (empty for the ACC channels, all for all channels)")
+		csvopts = csvFlags(fset)
+		msropts = msrFlags(fset)
+	)
+	fset.Usage = func() {
+		fmt.Fprintf(fset.Output(), "Usage: fouracc diff [options] a.csv b.csv\n\nOptions:\n")
+		fset.PrintDefaults()
+	}
+
+	err := fset.Parse(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if fset.NArg() != 2 {
+		fset.Usage()
+		log.Fatalf("diff needs 2 data files, got %d", fset.NArg())
+	}
+
+	lopts, err := csvopts()
+	if err != nil {
+		log.Fatalf("could not parse CSV dialect: %v", err)
+	}
+	mopts, err := msropts()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("chunk size: %v", *chunksz)
+	log.Printf("files:      %v, %v", fset.Arg(0), fset.Arg(1))
+	log.Printf("range:      data[%d:%d]", *xmin, *xmax)
+
+	var (
+		sets  = make([][]analysis.Series, 2)
+		freqs = make([]float64, 2)
+	)
+	for i, fname := range fset.Args() {
+		sets[i], freqs[i], err = readFile(fname, *chans, mopts, lopts...)
+		if err != nil {
+			log.Fatalf("could not read %q: %v", fname, err)
+		}
+	}
+
+	for _, a := range sets[0] {
+		var b *analysis.Series
+		for i := range sets[1] {
+			if sets[1][i].Name == a.Name {
+				b = &sets[1][i]
+				break
+			}
+		}
+		if b == nil {
+			log.Fatalf("no axis %q in %q", a.Name, fset.Arg(1))
+		}
+		err = diff(fset.Arg(0), fset.Arg(1), a, *b, freqs, *chunksz, *xmin, *xmax, *rel)
+		if err != nil {
+			log.Fatalf("could not compare axis %q: %v", a.Name, err)
+		}
+	}
+}
+
+func diff(fa, fb string, a, b analysis.Series, freqs []float64, chunksz, xmin, xmax int, rel bool) error {
+	var (
+		title = a.Name
+		ffts  = make([]fouracc.FFT, 2)
+	)
+	for i, s := range []struct {
+		fname string
+		data  analysis.Series
+	}{{fa, a}, {fb, b}} {
+		beg, end, err := analysis.Range(len(s.data.Xs), xmin, xmax)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.fname, err)
+		}
+		name := analysis.Name(filepath.Base(s.fname), title)
+		ffts[i] = fouracc.ChunkedFFT(name, chunksz, s.data.Xs[beg:end], s.data.Ys[beg:end], freqs[i])
+	}
+
+	d, err := fouracc.Difference(ffts[0], ffts[1], rel)
+	if err != nil {
+		return err
+	}
+
+	var (
+		fmax = math.NaN()
+		dmax = 0.0
+	)
+	for i, v := range d.MeanDiff {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		if math.Abs(v) > math.Abs(dmax) {
+			fmax = d.Freqs[i]
+			dmax = v
+		}
+	}
+	switch {
+	case math.IsNaN(fmax):
+		log.Printf("diff [%s]: %d/%d chunk(s) paired, identical mean spectra", title, d.Pairs, len(d.Ts))
+	default:
+		log.Printf("diff [%s]: %d/%d chunk(s) paired, max mean difference %+.3g dB at f=%.4g", title, d.Pairs, len(d.Ts), dmax, fmax)
+	}
+
+	img, err := analysis.Render(30*vg.Centimeter, func(dc draw.Canvas) error {
+		return fouracc.PlotDiff(dc, d)
+	})
+	if err != nil {
+		return fmt.Errorf("could not plot difference: %w", err)
+	}
+
+	err = writePNG(oname("out-diff", title, ".png"), img)
+	if err != nil {
+		return err
+	}
+
+	err = analysis.WriteCSV(
+		oname("out-diff", title, ".csv"),
+		"# freq\tmean-a\tmean-b\tdiff(dB)",
+		d.Freqs, d.MeanA, d.MeanB, d.MeanDiff,
+	)
+	if err != nil {
+		return fmt.Errorf("could not write difference data: %w", err)
+	}
+
+	return nil
+}
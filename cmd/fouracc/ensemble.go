@@ -0,0 +1,209 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lsst-lpc/fouracc"
+	"github.com/lsst-lpc/fouracc/internal/analysis"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// runEnsemble runs the "fouracc ensemble f1.csv f2.csv ..." command,
+// averaging the spectra of repeated recordings of the same measurement.
+func runEnsemble(args []string) {
+	var (
+		fset     = flag.NewFlagSet("ensemble", flag.ExitOnError)
+		chunksz  = fset.Int("chunks", 256, "chunk size of Fourier processing")
+		xmin     = fset.Int("xmin", 0, "start of analysis range index")
+		xmax     = fset.Int("xmax", -1, "end of analysis range index")
+		level    = fset.Float64("level", 0.95, "confidence level of the band of the ensemble-averaged spectrum")
+		resample = fset.Bool("resample", false, "resample recordings to the sampling frequency of the first one, instead of rejecting them")
+		chans    = fset.String("channels", "", "comma-separated list of MSR channels to average (empty for the ACC channels, all for all channels)")
+		csvopts  = csvFlags(fset)
+		msropts  = msrFlags(fset)
+	)
+	fset.Usage = func() {
+		fmt.Fprintf(fset.Output(), "Usage: fouracc ensemble [options] f1.csv f2.csv [f3.csv ...]\n\nOptions:\n")
+		fset.PrintDefaults()
+	}
+
+	err := fset.Parse(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if fset.NArg() < 2 {
+		fset.Usage()
+		log.Fatalf("ensemble needs at least 2 data files, got %d", fset.NArg())
+	}
+
+	lopts, err := csvopts()
+	if err != nil {
+		log.Fatalf("could not parse CSV dialect: %v", err)
+	}
+	mopts, err := msropts()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("chunk size: %v", *chunksz)
+	log.Printf("files:      %v", strings.Join(fset.Args(), ", "))
+	log.Printf("range:      data[%d:%d]", *xmin, *xmax)
+	log.Printf("level:      %v", *level)
+
+	var (
+		ref   []analysis.Series
+		rfreq float64
+		ffts  = make(map[string][]fouracc.FFT)
+	)
+	for i, fname := range fset.Args() {
+		set, freq, err := readFile(fname, *chans, mopts, lopts...)
+		if err != nil {
+			log.Fatalf("could not read %q: %v", fname, err)
+		}
+		if i == 0 {
+			ref, rfreq = set, freq
+		}
+		if !sameAxes(ref, set) {
+			log.Printf("ensemble: reject %q: axes %v differ from %v", fname, axes(set), axes(ref))
+			continue
+		}
+		if !sameRate(freq, rfreq) {
+			if !*resample || freq <= 0 || rfreq <= 0 {
+				log.Printf("ensemble: reject %q: sampling frequency %v Hz differs from %v Hz", fname, freq, rfreq)
+				continue
+			}
+			log.Printf("ensemble: resample %q from %v Hz to %v Hz", fname, freq, rfreq)
+		}
+
+		for _, s := range set {
+			beg, end, err := analysis.Range(len(s.Xs), *xmin, *xmax)
+			if err != nil {
+				log.Fatalf("%s: %v", fname, err)
+			}
+			xs, ys := s.Xs[beg:end], s.Ys[beg:end]
+			if !sameRate(freq, rfreq) {
+				xs, ys, err = fouracc.Resample(xs, ys, freq, rfreq)
+				if err != nil {
+					log.Fatalf("could not resample %q: %v", fname, err)
+				}
+			}
+			name := analysis.Name(filepath.Base(fname), s.Name)
+			ffts[s.Name] = append(ffts[s.Name], fouracc.ChunkedFFT(name, *chunksz, xs, ys, rfreq))
+		}
+	}
+
+	for _, s := range ref {
+		err = ensemble(s.Name, ffts[s.Name], *level)
+		if err != nil {
+			log.Fatalf("could not average axis %q: %v", s.Name, err)
+		}
+	}
+}
+
+func ensemble(title string, ffts []fouracc.FFT, level float64) error {
+	ens, err := fouracc.EnsembleAverage(ffts, level)
+	if err != nil {
+		return err
+	}
+
+	log.Printf(
+		"ensemble [%s]: %d recording(s), %d spectra, %d chunk(s)",
+		title, ens.Count, ens.Spectra, len(ens.Ts),
+	)
+	if ens.Count < 2 {
+		log.Printf("ensemble [%s]: no variance nor confidence band with a single recording", title)
+	}
+
+	img, err := analysis.Render(30*vg.Centimeter, func(dc draw.Canvas) error {
+		return fouracc.PlotEnsemble(dc, ens)
+	})
+	if err != nil {
+		return fmt.Errorf("could not plot ensemble: %w", err)
+	}
+
+	err = writePNG(oname("out-ensemble", title, ".png"), img)
+	if err != nil {
+		return err
+	}
+
+	count := make([]float64, len(ens.Freqs))
+	for i := range count {
+		count[i] = float64(ens.Count)
+	}
+	err = analysis.WriteCSV(
+		oname("out-ensemble", title, ".csv"),
+		"# freq\tmean\tvar\tlower\tupper\tcount",
+		ens.Freqs, ens.Mean, ens.Var, ens.Lower, ens.Upper, count,
+	)
+	if err != nil {
+		return fmt.Errorf("could not write ensemble spectrum: %w", err)
+	}
+
+	hdr, cols := ensembleTable(ens)
+	err = analysis.WriteCSV(oname("out-ensemble-spec", title, ".csv"), hdr, cols...)
+	if err != nil {
+		return fmt.Errorf("could not write ensemble spectrogram: %w", err)
+	}
+
+	return nil
+}
+
+// ensembleTable returns the header and columns of the spectrogram
+// statistics of an ensemble, one row per time/frequency cell.
+func ensembleTable(ens fouracc.Ensemble) (string, [][]float64) {
+	cols := make([][]float64, 5)
+	for c, t := range ens.Ts {
+		for r, f := range ens.Freqs {
+			cols[0] = append(cols[0], t)
+			cols[1] = append(cols[1], f)
+			cols[2] = append(cols[2], ens.Coeffs[c][r])
+			cols[3] = append(cols[3], ens.Vars[c][r])
+			cols[4] = append(cols[4], float64(ens.Counts[c]))
+		}
+	}
+	return "# t\tfreq\tmean\tvar\tcount", cols
+}
+
+// sameRate returns whether the two sampling frequencies agree within 1%.
+// Plain CSV files, without sampling frequency, agree with each other.
+func sameRate(a, b float64) bool {
+	if a <= 0 || b <= 0 {
+		return a <= 0 && b <= 0
+	}
+	return math.Abs(a-b) <= 0.01*math.Max(a, b)
+}
+
+// sameAxes returns whether the two data sets hold the same axes.
+func sameAxes(a, b []analysis.Series) bool {
+	na, nb := axes(a), axes(b)
+	if len(na) != len(nb) {
+		return false
+	}
+	for i := range na {
+		if na[i] != nb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// axes returns the sorted names of the axes of a data set.
+func axes(set []analysis.Series) []string {
+	names := make([]string, len(set))
+	for i, s := range set {
+		names[i] = s.Name
+	}
+	sort.Strings(names)
+	return names
+}
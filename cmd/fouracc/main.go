@@ -3,41 +3,251 @@
 // license that can be found in the LICENSE file.
 
 // Command fouracc runs a FFT analysis on an MSR acceleration file.
+// MSR channels are shifted by their time delay onto a common time base,
+// unless -align=false.
+//
+// Sequential MSR files are merged into one continuous recording:
+//
+//	$> fouracc [options] f1.csv f2.csv [f3.csv ...]
+//
+// The diff sub-command compares the spectrograms of two data files:
+//
+//	$> fouracc diff [options] a.csv b.csv
+//
+// The ensemble sub-command averages the spectra of repeated recordings of
+// the same measurement:
+//
+//	$> fouracc ensemble [options] f1.csv f2.csv [f3.csv ...]
+//
+// The convert sub-command trims data files, selects their channels and
+// converts them between the MSR and plain CSV formats:
+//
+//	$> fouracc convert [options] in.csv out.csv
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/lsst-lpc/fouracc"
+	"github.com/lsst-lpc/fouracc/internal/analysis"
 	"github.com/lsst-lpc/fouracc/msr"
 	"golang.org/x/sync/errgroup"
-	"gonum.org/v1/plot/vg"
-	"gonum.org/v1/plot/vg/draw"
-	"gonum.org/v1/plot/vg/vgimg"
 )
 
 func main() {
 	log.SetPrefix("fouracc: ")
 	log.SetFlags(0)
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "ensemble":
+			runEnsemble(os.Args[2:])
+			return
+		case "convert":
+			runConvert(os.Args[2:])
+			return
+		}
+	}
+
 	var (
-		chunksz = flag.Int("chunks", 256, "chunk size of Fourier processing")
-		xmin    = flag.Int("xmin", 0, "start of analysis range index")
-		xmax    = flag.Int("xmax", -1, "end of analysis range index")
+		chunksz  = flag.Int("chunks", 256, "chunk size of Fourier processing")
+		xmin     = flag.Int("xmin", 0, "start of analysis range index")
+		xmax     = flag.Int("xmax", -1, "end of analysis range index")
+		envband  = flag.String("env", "", "carrier band lo:hi (Hz) of the envelope analysis (empty to disable)")
+		method   = flag.String("method", "stft", "time-frequency analysis method (stft, cwt, mtm, lomb)")
+		wavelet  = flag.String("wavelet", "morlet", "mother wavelet of the CWT (morlet, mexhat, paul)")
+		scales   = flag.String("scales", "", "range smin:smax (s) of the CWT scales (empty for automatic)")
+		nscales  = flag.Int("nscales", 64, "number of CWT scales")
+		nw       = flag.Float64("nw", 4, "time-bandwidth product of the multitaper analysis")
+		ntapers  = flag.Int("tapers", 0, "number of DPSS tapers of the multitaper analysis (0 for 2*nw-1)")
+		octfrac  = flag.Int("octave", 0, "bandwidth designator b of the 1/b octave band analysis (1, 3, 6, 12; 0 to disable)")
+		nmodes   = flag.Int("modes", 0, "number of spectral peaks of the modal parameter extraction (0 to disable)")
+		modalm   = flag.String("modal-method", "rfp", "modal parameters annotated on the spectrogram (hp, circle, rfp)")
+		harmtol  = flag.Float64("harmonics", 0, "relative frequency tolerance of the harmonic family detection (0 to disable)")
+		harmmin  = flag.Int("harmonics-min", 3, "minimum number of peaks of a harmonic family")
+		cumrms   = flag.Bool("cumrms", false, "compute the cumulative RMS vs frequency")
+		vctarget = flag.String("vc", "", "VC curve (A, B, C, D, E) to comply with; exit status is 2 if not met (empty to disable)")
+		fill     = flag.Bool("merge-fill", false, "fill the gaps between merged MSR files with forward-filled samples")
+		chans    = flag.String("channels", "", "comma-separated list of MSR channels to analyze (empty for the ACC channels, all for all channels)")
+		accunit  = flag.String("acc-unit", "", "unit of the acceleration data (g, mg, m/s2; empty for the MSR channel unit, or g)")
+		weight   = flag.String("weighting", "", "frequency weighting of the acceleration (wk, wd, wc, wm; empty to disable)")
+		tau      = flag.Float64("tau", 1, "integration time (s) of the running RMS of the weighted acceleration")
+		baseline = flag.String("baseline", "", "comma-separated list of reference files of the anomaly detection (empty to disable)")
+		basekind = flag.String("baseline-kind", "mean", "kind of baseline of the anomaly detection (mean, pct)")
+		pct      = flag.Float64("pct", 95, "percentile of the upper envelope of a pct baseline")
+		thresh   = flag.String("threshold", "6dB", "anomaly threshold over the baseline (e.g. 6dB, 3sigma)")
+		srswin   = flag.String("srs", "", "event window t0:t1 (s) of the shock response spectrum (empty to disable)")
+		srsq     = flag.Float64("srs-q", 10, "quality factor of the SRS oscillators")
+		srskind  = flag.String("srs-kind", "acc", "response quantity of the SRS (acc, pv)")
+		srsfs    = flag.String("srs-freqs", "", "range fmin:fmax (Hz) of the SRS natural frequencies (empty for automatic)")
+		csvopts  = csvFlags(flag.CommandLine)
+		msropts  = msrFlags(flag.CommandLine)
 	)
 
 	flag.Parse()
 
+	lopts, err := csvopts()
+	if err != nil {
+		log.Fatalf("could not parse CSV dialect: %v", err)
+	}
+	mopts, err := msropts()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	log.Printf("chunk size: %v", *chunksz)
-	log.Printf("file:       %v", flag.Arg(0))
+	log.Printf("file:       %v", strings.Join(flag.Args(), ", "))
 	log.Printf("range:      data[%d:%d]", *xmin, *xmax)
 
+	opts := analysis.Options{ChunkSize: *chunksz, Method: *method}
+	switch *method {
+	case "stft":
+		// ok
+	case "cwt":
+		w, err := fouracc.ParseWavelet(*wavelet)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Wavelet = w
+		opts.NScales = *nscales
+		if *scales != "" {
+			opts.SMin, opts.SMax, err = analysis.ParseBand(*scales)
+			if err != nil {
+				log.Fatalf("could not parse CWT scales: %v", err)
+			}
+		}
+		log.Printf("cwt:        wavelet=%v, scales=%d", w, *nscales)
+	case "mtm":
+		opts.NW = *nw
+		opts.Tapers = *ntapers
+		log.Printf("mtm:        NW=%v, tapers=%d", *nw, *ntapers)
+	case "lomb":
+		// ok
+	default:
+		log.Fatalf("invalid analysis method %q", *method)
+	}
+
+	if *octfrac != 0 {
+		opts.Octave = *octfrac
+		log.Printf("octave:     1/%d", opts.Octave)
+	}
+
+	if *nmodes > 0 {
+		var err error
+		opts.Modes = *nmodes
+		opts.ModalMethod, err = fouracc.ParseModalMethod(*modalm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("modes:      %d, annotated=%v", opts.Modes, opts.ModalMethod)
+	}
+
+	if *harmtol > 0 {
+		opts.HarmTol = *harmtol
+		opts.HarmMin = *harmmin
+		log.Printf("harmonics:  tol=%v, min=%d", opts.HarmTol, opts.HarmMin)
+	}
+
+	if *cumrms {
+		opts.CumRMS = true
+		log.Printf("cumrms:     levels=%v", fouracc.EnergyLevels)
+	}
+
+	if *vctarget != "" {
+		var err error
+		opts.VC = true
+		opts.VCTarget, err = fouracc.ParseVCClass(*vctarget)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("vc:         target=%v", opts.VCTarget)
+	}
+
+	if *weight != "" {
+		var err error
+		opts.Weighted = true
+		opts.Tau = *tau
+		opts.Weighting, err = fouracc.ParseWeighting(*weight)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("weighting:  %v, tau=%vs", opts.Weighting, opts.Tau)
+	}
+
+	if *baseline != "" {
+		var err error
+		kind, err := fouracc.ParseBaselineKind(*basekind)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Threshold, opts.ScoreUnit, err = fouracc.ParseThreshold(*thresh)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var (
+			fnames = strings.Split(*baseline, ",")
+			refs   = make([][]analysis.Series, len(fnames))
+			freqs  = make([]float64, len(fnames))
+		)
+		for i, fname := range fnames {
+			refs[i], freqs[i], err = readFile(fname, *chans, mopts, lopts...)
+			if err != nil {
+				log.Fatalf("could not read baseline file %q: %v", fname, err)
+			}
+		}
+		opts.Baselines, err = analysis.Baselines(refs, freqs, opts.ChunkSize, kind, *pct)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("baseline:   %d file(s), kind=%v, threshold=%v%v", len(fnames), kind, opts.Threshold, opts.ScoreUnit)
+	}
+
+	if *srswin != "" {
+		var err error
+		opts.SRS = true
+		opts.SRSQ = *srsq
+		opts.SRST0, opts.SRST1, err = analysis.ParseBand(*srswin)
+		if err != nil {
+			log.Fatalf("could not parse SRS event window: %v", err)
+		}
+		opts.SRSKind, err = fouracc.ParseSRSKind(*srskind)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *srsfs != "" {
+			opts.SRSFmin, opts.SRSFmax, err = analysis.ParseBand(*srsfs)
+			if err != nil {
+				log.Fatalf("could not parse SRS natural frequencies: %v", err)
+			}
+		}
+		log.Printf("srs:        window=[%v, %v], Q=%v, kind=%v", opts.SRST0, opts.SRST1, opts.SRSQ, opts.SRSKind)
+	}
+	if *envband != "" {
+		lo, hi, err := analysis.ParseBand(*envband)
+		if err != nil {
+			log.Fatalf("could not parse envelope carrier band: %v", err)
+		}
+		opts.Env = true
+		opts.EnvLo = lo
+		opts.EnvHi = hi
+		log.Printf("envelope:   [%v, %v]", lo, hi)
+	}
+
+	err = opts.Validate()
+	if err != nil {
+		log.Fatalf("invalid analysis options: %v", err)
+	}
+
 	f, err := os.Open(flag.Arg(0))
 	if err != nil {
 		log.Fatal(err)
@@ -52,33 +262,66 @@ func main() {
 	}
 	f.Seek(0, io.SeekStart)
 
+	fname := analysis.MergedName(flag.Args())
 	switch {
-	case strings.HasPrefix(string(head[:]), "*CREATOR"):
-		msr, err := msr.Parse(f)
+	case analysis.IsMSR(head[:]):
+		mopts := append([]msr.Option{msr.WithMask()}, mopts...)
+		if *fill {
+			mopts = append(mopts, msr.WithGapFill())
+		}
+		file, err := parseMSR(flag.Args(), mopts...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		names, err := analysis.SelectChannels(file, *chans)
 		if err != nil {
-			log.Fatalf("could not parse MSR file: %v", err)
+			log.Fatal(err)
+		}
+		rate, err := file.Rate()
+		if err != nil {
+			log.Fatalf("could not estimate sampling rate: %v", err)
+		}
+		ts := file.Axis()
+		freq := rate.Nominal
+		if opts.Method == "lomb" {
+			// Lomb-Scargle works on the actual, possibly irregular, timestamps.
+			if vs := file.TimeSeries(); vs != nil {
+				ts = vs
+				opts.TScale = 1000
+			}
 		}
-		ts := msr.Axis()
-		freq := msr.Freq()
-		beg, end, err := clean(len(ts), *xmin, *xmax)
+		beg, end, err := analysis.Range(len(ts), *xmin, *xmax)
 		if err != nil {
 			log.Fatal(err)
 		}
 		ts = ts[beg:end]
-		var grp errgroup.Group
-		for _, tt := range []struct {
-			Name string
-			Data []float64
-		}{
-			{"x", msr.AccX()[beg:end]},
-			{"y", msr.AccY()[beg:end]},
-			{"z", msr.AccZ()[beg:end]},
-		} {
-			tt := tt
+		info(file)
+		logRate(rate)
+		check(file, beg, end, *chunksz)
+		log.Printf("channels:   %s", strings.Join(names, ", "))
+		if opts.VC {
+			opts.AccScale, err = analysis.AccScale(*accunit, analysis.AccUnit(file))
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		var (
+			grp    errgroup.Group
+			failed = make([]bool, len(names))
+		)
+		for i, name := range names {
+			i, name := i, name
+			vs, _, err := file.Channel(name)
+			if err != nil {
+				log.Fatal(err)
+			}
 			grp.Go(func() error {
-				err := process(filepath.Base(flag.Arg(0)), tt.Name, *chunksz, ts, tt.Data, freq)
-				if err != nil {
-					return fmt.Errorf("could not process axis %s: %w", tt.Name, err)
+				err := process(fname, analysis.AxisName(name), opts, ts, vs[beg:end], freq)
+				switch {
+				case errors.Is(err, errVCFailed):
+					failed[i] = true
+				case err != nil:
+					return fmt.Errorf("could not process channel %q: %w", name, err)
 				}
 				return nil
 			})
@@ -87,83 +330,313 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
+		for _, v := range failed {
+			if v {
+				log.Printf("vc:         FAIL (target=%v)", opts.VCTarget)
+				os.Exit(2)
+			}
+		}
+		if opts.VC {
+			log.Printf("vc:         PASS (target=%v)", opts.VCTarget)
+		}
+
+	case flag.NArg() > 1:
+		log.Fatalf("only MSR files can be merged")
 
 	default:
-		xs, ys, err := fouracc.Load(f)
+		data, err := fouracc.LoadSeries(f, lopts...)
 		if err != nil {
 			log.Fatal(err)
 		}
-		beg, end, err := clean(len(xs), *xmin, *xmax)
+		log.Printf("csv:        %v", data.Dialect)
+		if data.Freq > 0 {
+			log.Printf("csv:        column=%q, time=%q, freq=%v Hz", data.Column, data.Time, data.Freq)
+		}
+		beg, end, err := analysis.Range(len(data.Xs), *xmin, *xmax)
 		if err != nil {
 			log.Fatal(err)
 		}
-		xs = xs[beg:end]
-		ys = ys[beg:end]
-		err = process(filepath.Base(flag.Arg(0)), "", *chunksz, xs, ys, -1)
-		if err != nil {
+		xs := data.Xs[beg:end]
+		ys := data.Ys[beg:end]
+		if opts.VC {
+			if data.Freq <= 0 {
+				log.Fatalf("-vc needs a sampling frequency: CSV file %q has no time column", fname)
+			}
+			opts.AccScale, err = analysis.AccScale(*accunit, "")
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		err = process(fname, "", opts, xs, ys, data.Freq)
+		switch {
+		case errors.Is(err, errVCFailed):
+			log.Printf("vc:         FAIL (target=%v)", opts.VCTarget)
+			os.Exit(2)
+		case err != nil:
 			log.Fatalf("could not process data: %v", err)
 		}
+		if opts.VC {
+			log.Printf("vc:         PASS (target=%v)", opts.VCTarget)
+		}
 	}
 }
 
-func clean(len, beg, end int) (int, int, error) {
-	if end == -1 {
-		end = len
+// parseMSR parses the named MSR files, merging them into one continuous
+// file if more than one.
+func parseMSR(fnames []string, opts ...msr.Option) (msr.File, error) {
+	files := make([]msr.File, len(fnames))
+	for i, fname := range fnames {
+		f, err := os.Open(fname)
+		if err != nil {
+			return msr.File{}, err
+		}
+		files[i], err = analysis.ReadMSR(fname, f, opts...)
+		f.Close()
+		if err != nil {
+			return msr.File{}, err
+		}
+	}
+	if len(files) == 1 {
+		return files[0], nil
+	}
+
+	f, jcts, err := msr.Merge(files, opts...)
+	if err != nil {
+		return f, fmt.Errorf("could not merge MSR files: %w", err)
 	}
-	switch {
-	case end > len:
-		return beg, end, fmt.Errorf("invalid data range (end=%d > len=%d)", end, len)
-	case beg > end:
-		return beg, end, fmt.Errorf("invalid data range (beg=%d > end=%d)", beg, end)
-	case beg > len:
-		return beg, end, fmt.Errorf("invalid data range (beg=%d > len=%d)", end, len)
-	}
-	return beg, end, nil
+	log.Printf("merge:      %d files, %d junction(s)", len(files), len(jcts))
+	for _, jct := range jcts {
+		var msg string
+		switch {
+		case jct.Dropped > 0:
+			msg = fmt.Sprintf("overlap of %v, %d sample(s) dropped", -jct.Gap, jct.Dropped)
+		case jct.Missing > 0:
+			msg = fmt.Sprintf("gap of %v, %d missing sample(s), %d filled", jct.Gap, jct.Missing, jct.Filled)
+		default:
+			msg = "contiguous"
+		}
+		log.Printf("  junction at sample %d (%s): %s", jct.Index, jct.Time.Format("2006-01-02 15:04:05.000"), msg)
+	}
+	return f, nil
 }
 
-func process(fname, title string, chunksz int, xs, ys []float64, freq float64) error {
-	log.Printf("data: %d", len(ys))
+// info displays the header of the MSR file and its channels, with their
+// limits, time delay and calibration.
+func info(f msr.File) {
+	log.Printf("creator:    %s (version=%s)", f.Creator, f.Version)
+	log.Printf("start:      %v", f.Start)
+	log.Printf("modules:    %s", strings.Join(f.Modules(), ", "))
+	for _, col := range f.Cols[1:] {
+		log.Printf("channel:    %q [%s] sensor=%q serial=%s", col.Name, col.Unit, col.Sensor, col.SensorID)
+		if lim := col.Limits; lim.IsSet() {
+			log.Printf(
+				"  limits:      alarm=%v recorded=%v limit1=%v limit2=%v",
+				lim.Alarm, lim.Recorded, lim.Limit1, lim.Limit2,
+			)
+		}
+		if col.TimeDelay != 0 {
+			log.Printf("  time delay:  %v aligned=%v", col.TimeDelay, col.Aligned)
+		}
+		cal := col.CalibData
+		if !cal.IsValid() {
+			continue
+		}
+		var date string
+		if !cal.Date.IsZero() {
+			date = " date=" + cal.Date.Format("2006-01-02")
+		}
+		log.Printf(
+			"  calibration: (%v,%v)-(%v,%v)%s info=%q applied=%v",
+			cal.X0, cal.Y0, cal.X1, cal.Y1, date, cal.Info, col.Calibrated,
+		)
+	}
+}
 
-	if title != "" {
-		fname += " [axis=" + title + "]"
+// logRate displays the sampling rate of the MSR file.
+func logRate(rate msr.Rate) {
+	log.Printf(
+		"rate:       nominal=%.6g Hz, measured=%.6g Hz, jitter=%v, outliers=%d",
+		rate.Nominal, rate.Measured, rate.Jitter.Round(time.Microsecond), rate.Outliers,
+	)
+	for i, c := range rate.Changes {
+		if i == maxIssues {
+			log.Printf("  ... and %d more", len(rate.Changes)-maxIssues)
+			break
+		}
+		log.Printf("  rate change at sample %d (%s): %.6g Hz -> %.6g Hz", c.Index, c.Time.Format("2006-01-02 15:04:05.000"), c.From, c.To)
 	}
+}
+
+// maxIssues is the maximum number of data-integrity issues displayed.
+const maxIssues = 10
 
-	fft := fouracc.ChunkedFFT(fname, chunksz, xs, ys, freq)
-	log.Printf("coeffs: %d", len(fft.Coeffs))
-	{
-		c, r := fft.Dims()
-		log.Printf("dims: (c=%d, r=%d)", c, r)
+// check runs a data-integrity pass over the MSR file and reports the
+// analysis chunks of the [beg,end) range containing invalid samples.
+func check(f msr.File, beg, end, chunksz int) {
+	rep := msr.Check(f)
+	log.Printf("integrity:  %d issue(s) (interval=%v)", len(rep.Issues), rep.Interval)
+	for i, issue := range rep.Issues {
+		if i == maxIssues {
+			log.Printf("  ... and %d more", len(rep.Issues)-maxIssues)
+			break
+		}
+		log.Printf("  %v", issue)
 	}
 
-	const (
-		width  = 20 * vg.Centimeter
-		height = 30 * vg.Centimeter
-	)
+	var chunks []int
+	mask := f.Mask[beg:end]
+	for i := 0; i < len(mask); i += chunksz {
+		j := i + chunksz
+		if j > len(mask) {
+			j = len(mask)
+		}
+		for _, ok := range mask[i:j] {
+			if !ok {
+				chunks = append(chunks, i/chunksz)
+				break
+			}
+		}
+	}
+	if len(chunks) > 0 {
+		log.Printf("integrity:  %d/%d chunk(s) with invalid samples: %v", len(chunks), (len(mask)+chunksz-1)/chunksz, chunks)
+	}
+}
 
-	c := vgimg.PngCanvas{Canvas: vgimg.New(width, height)}
-	err := fouracc.Plot(draw.New(c), fft)
+// readFile reads the time series of the named data file.
+func readFile(fname, sel string, mopts []msr.Option, lopts ...fouracc.LoadOption) ([]analysis.Series, float64, error) {
+	f, err := os.Open(fname)
 	if err != nil {
-		return fmt.Errorf("could not plot FFT: %w", err)
+		return nil, 0, err
 	}
+	defer f.Close()
+	return analysis.ReadSeries(f, sel, mopts, lopts...)
+}
 
-	oname := "out.png"
-	if title != "" {
-		oname = fmt.Sprintf("out-%s.png", title)
+// msrFlags registers the flags describing how MSR files are parsed on the
+// provided flag set, and returns the function building the corresponding
+// parse options.
+func msrFlags(fset *flag.FlagSet) func() ([]msr.Option, error) {
+	var (
+		tz    = fset.String("tz", "", "time zone of the MSR timestamps (e.g. Local, Europe/Paris, +02:00; empty for the zone of the file, or UTC)")
+		calib = fset.Bool("calibrate", false, "apply the two-point calibration of the MSR channels to their data")
+		align = fset.Bool("align", true, "shift the MSR channels by their time delay onto a common time base")
+	)
+	return func() ([]msr.Option, error) {
+		var mopts []msr.Option
+		if *calib {
+			mopts = append(mopts, msr.WithCalibration())
+		}
+		if *align {
+			mopts = append(mopts, msr.WithAlignment())
+		}
+		if *tz != "" {
+			loc, err := msr.ParseZone(*tz)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse time zone: %w", err)
+			}
+			mopts = append(mopts, msr.WithLocation(loc))
+		}
+		return mopts, nil
 	}
+}
 
-	o, err := os.Create(oname)
-	if err != nil {
-		return fmt.Errorf("could not create output file: %w", err)
+// csvFlags registers the flags describing the dialect and selecting the
+// columns of plain CSV files on the provided flag set, and returns the
+// function building the corresponding load options.
+func csvFlags(fset *flag.FlagSet) func() ([]fouracc.LoadOption, error) {
+	var (
+		col      = fset.String("col", "", "amplitude column (name or 0-based index) of plain CSV files (empty for the first non-time column)")
+		tcol     = fset.String("time-col", "", "time column (name or 0-based index) of plain CSV files (empty for automatic, none to disable)")
+		sep      = fset.String("csv-sep", "", "field separator of plain CSV files (e.g. ';' or tab; empty to sniff the dialect)")
+		comment  = fset.String("csv-comment", "", "prefix of comment lines of plain CSV files (with -csv-sep)")
+		decimal  = fset.String("csv-decimal", ".", "decimal separator of plain CSV files (with -csv-sep)")
+		header   = fset.Int("csv-header", -1, "number of header rows of plain CSV files (-1 for automatic; with -csv-sep)")
+		quotes   = fset.Bool("csv-quotes", true, "whether fields of plain CSV files may be quoted (with -csv-sep)")
+		blank    = fset.Bool("csv-skip-blank", true, "whether to skip blank lines of plain CSV files (with -csv-sep)")
+		trailing = fset.Bool("csv-skip-trailing", false, "whether to drop empty trailing fields of plain CSV files (with -csv-sep)")
+	)
+	return func() ([]fouracc.LoadOption, error) {
+		lopts := analysis.LoadOptions(*col, *tcol)
+		if *sep == "" {
+			return lopts, nil
+		}
+		d, err := analysis.Dialect(*sep, *decimal)
+		if err != nil {
+			return nil, err
+		}
+		d.Comment = *comment
+		d.Header = *header
+		d.Quotes = *quotes
+		d.SkipBlank = *blank
+		d.SkipTrailing = *trailing
+		return append(lopts, fouracc.WithDialect(d)), nil
 	}
-	defer o.Close()
-	_, err = c.WriteTo(o)
+}
+
+// errVCFailed reports that the data do not comply with the target VC curve.
+var errVCFailed = errors.New("vibration criteria not met")
+
+// prefixes are the prefixes of the output files of each kind of analysis.
+var prefixes = map[string]string{
+	analysis.KindSpectrogram: "out",
+	analysis.KindModes:       "out-modes",
+	analysis.KindHarmonics:   "out-harm",
+	analysis.KindEnvelope:    "out-env",
+	analysis.KindOctave:      "out-oct",
+	analysis.KindCumRMS:      "out-cum",
+	analysis.KindWeighted:    "out-wrms",
+	analysis.KindAnomaly:     "out-anomaly",
+	analysis.KindSRS:         "out-srs",
+	analysis.KindVC:          "out-vc",
+}
+
+// process runs the analyses selected by opts on the time series of the
+// axis of the named file, and writes their plots and data tables.
+// It returns errVCFailed if the data do not comply with the target VC curve.
+func process(fname, axis string, opts analysis.Options, xs, ys []float64, freq float64) error {
+	outs, err := analysis.Run(fname, axis, opts, xs, ys, freq)
 	if err != nil {
-		return fmt.Errorf("could not create output plot: %w", err)
+		return err
 	}
-	err = o.Close()
-	if err != nil {
-		return fmt.Errorf("could not close output file: %w", err)
+
+	pass := true
+	for _, out := range outs {
+		prefix := prefixes[out.Kind]
+		if out.PNG != nil {
+			err = writePNG(oname(prefix, axis, ".png"), out.PNG)
+			if err != nil {
+				return err
+			}
+		}
+		if out.Cols != nil {
+			err = analysis.WriteCSV(oname(prefix, axis, ".csv"), out.Header, out.Cols...)
+			if err != nil {
+				return err
+			}
+		}
+		if out.VC != nil && !out.VC.Pass() {
+			pass = false
+		}
+	}
+	if !pass {
+		return errVCFailed
 	}
+	return nil
+}
+
+// oname returns the name of an output file for the provided axis.
+func oname(prefix, title, ext string) string {
+	if title == "" {
+		return prefix + ext
+	}
+	return prefix + "-" + title + ext
+}
 
+// writePNG writes the provided PNG image to the named file.
+func writePNG(oname string, img []byte) error {
+	err := os.WriteFile(oname, img, 0644)
+	if err != nil {
+		return fmt.Errorf("could not create output plot: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,116 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import "math"
+
+// EnergyLevels are the fractions of the total mean square located on
+// cumulative RMS curves.
+var EnergyLevels = []float64{0.50, 0.90, 0.99}
+
+// CumRMS holds the cumulative RMS of a time series as a function of frequency.
+type CumRMS struct {
+	Freqs []float64 // frequencies of the bins
+	PSD   []float64 // one-sided power spectral density
+	Up    []float64 // RMS of the bins up to each frequency
+	Down  []float64 // RMS of the bins from each frequency up to Nyquist
+	RMS   float64   // overall RMS, without the DC component
+
+	Levels    []float64 // fractions of the total mean square, see EnergyLevels
+	UpFreqs   []float64 // frequencies where Up reaches each level
+	DownFreqs []float64 // frequencies where Down reaches each level
+
+	Name  string
+	Scale float64 // Frequency scale
+}
+
+// CumulativeRMS integrates the power spectral density of ys from low to
+// high frequencies, and the reverse, and locates the frequencies where each
+// of the EnergyLevels of the total mean square is reached.
+// Frequencies are expressed in units of freq, or in cycles per sample if
+// freq is not positive.
+func CumulativeRMS(fname string, ys []float64, freq float64) CumRMS {
+	scale := 1.0
+	if freq > 0 {
+		scale = freq
+	}
+	fs, ms := MeanSquare(ys, freq)
+	cum := CumRMS{
+		Freqs:     fs,
+		PSD:       make([]float64, len(ms)),
+		Up:        make([]float64, len(ms)),
+		Down:      make([]float64, len(ms)),
+		Levels:    EnergyLevels,
+		UpFreqs:   make([]float64, len(EnergyLevels)),
+		DownFreqs: make([]float64, len(EnergyLevels)),
+		Name:      fname,
+		Scale:     freq,
+	}
+	if len(ms) == 0 {
+		for i := range cum.Levels {
+			cum.UpFreqs[i] = math.NaN()
+			cum.DownFreqs[i] = math.NaN()
+		}
+		return cum
+	}
+
+	var (
+		df  = scale / float64(len(ys))
+		up  = make([]float64, len(ms))
+		dn  = make([]float64, len(ms))
+		sum = 0.0
+	)
+	for i, v := range ms {
+		cum.PSD[i] = v / df
+		sum += v
+		up[i] = sum
+	}
+	sum = 0
+	for i := len(ms) - 1; i >= 0; i-- {
+		sum += ms[i]
+		dn[i] = sum
+	}
+	total := up[len(up)-1]
+	for i := range ms {
+		cum.Up[i] = math.Sqrt(up[i])
+		cum.Down[i] = math.Sqrt(dn[i])
+	}
+	cum.RMS = math.Sqrt(total)
+
+	for j, lvl := range cum.Levels {
+		cum.UpFreqs[j] = math.NaN()
+		cum.DownFreqs[j] = math.NaN()
+		if total <= 0 {
+			continue
+		}
+		e := lvl * total
+		for i, v := range up {
+			if v < e {
+				continue
+			}
+			cum.UpFreqs[j] = fs[i]
+			if i > 0 {
+				// interpolate within the bin, linearly in energy.
+				f := (e - up[i-1]) / (v - up[i-1])
+				cum.UpFreqs[j] = fs[i-1] + f*(fs[i]-fs[i-1])
+			}
+			break
+		}
+		for i := len(dn) - 1; i >= 0; i-- {
+			v := dn[i]
+			if v < e {
+				continue
+			}
+			cum.DownFreqs[j] = fs[i]
+			if i < len(dn)-1 {
+				f := (e - dn[i+1]) / (v - dn[i+1])
+				cum.DownFreqs[j] = fs[i+1] + f*(fs[i]-fs[i+1])
+			}
+			break
+		}
+	}
+
+	return cum
+}
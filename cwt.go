@@ -0,0 +1,275 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sort"
+	"strings"
+
+	"gonum.org/v1/gonum/dsp/fourier"
+)
+
+// Wavelet is a mother wavelet for the continuous wavelet transform.
+//
+// Wavelets are defined in Fourier space, following
+// C. Torrence and G. P. Compo, "A Practical Guide to Wavelet Analysis",
+// Bull. Amer. Meteor. Soc., 79, 61-78 (1998).
+type Wavelet int
+
+const (
+	Morlet     Wavelet = iota // Morlet wavelet, with ω0=6
+	MexicanHat                // Mexican hat wavelet (DOG, m=2)
+	Paul                      // Paul wavelet, with m=4
+)
+
+const (
+	morletW0 = 6.0
+	paulM    = 4
+	dogM     = 2
+)
+
+// ParseWavelet returns the wavelet named after the provided name.
+func ParseWavelet(name string) (Wavelet, error) {
+	switch strings.ToLower(name) {
+	case "morlet":
+		return Morlet, nil
+	case "mexhat", "mexican-hat", "dog":
+		return MexicanHat, nil
+	case "paul":
+		return Paul, nil
+	}
+	return 0, fmt.Errorf("fouracc: unknown wavelet %q", name)
+}
+
+func (w Wavelet) String() string {
+	switch w {
+	case Morlet:
+		return "morlet"
+	case MexicanHat:
+		return "mexhat"
+	case Paul:
+		return "paul"
+	}
+	return fmt.Sprintf("Wavelet(%d)", int(w))
+}
+
+// daughter returns the Fourier transform of the wavelet at scale s,
+// evaluated at the angular frequency omega, normalized to unit energy for
+// a time step dt.
+func (w Wavelet) daughter(s, omega, dt float64) complex128 {
+	var (
+		norm = math.Sqrt(2 * math.Pi * s / dt)
+		sw   = s * omega
+	)
+	switch w {
+	case Morlet:
+		if omega <= 0 {
+			return 0
+		}
+		v := math.Pow(math.Pi, -0.25) * math.Exp(-0.5*(sw-morletW0)*(sw-morletW0))
+		return complex(norm*v, 0)
+
+	case MexicanHat:
+		const m = dogM
+		v := math.Pow(sw, m) * math.Exp(-0.5*sw*sw) / math.Sqrt(math.Gamma(m+0.5))
+		// -i^m, with m=2.
+		return complex(norm*v, 0)
+
+	case Paul:
+		if omega <= 0 {
+			return 0
+		}
+		const m = paulM
+		v := math.Pow(2, m) / math.Sqrt(m*fact(2*m-1)) * math.Pow(sw, m) * math.Exp(-sw)
+		return complex(norm*v, 0)
+	}
+	panic(fmt.Errorf("fouracc: unknown wavelet %d", int(w)))
+}
+
+// fourierFactor returns the ratio between the equivalent Fourier period
+// and the wavelet scale.
+func (w Wavelet) fourierFactor() float64 {
+	switch w {
+	case Morlet:
+		return 4 * math.Pi / (morletW0 + math.Sqrt(2+morletW0*morletW0))
+	case MexicanHat:
+		return 2 * math.Pi / math.Sqrt(dogM+0.5)
+	case Paul:
+		return 4 * math.Pi / (2*paulM + 1)
+	}
+	panic(fmt.Errorf("fouracc: unknown wavelet %d", int(w)))
+}
+
+// efolding returns the ratio between the e-folding time of the wavelet
+// power at an edge discontinuity and the wavelet scale.
+func (w Wavelet) efolding() float64 {
+	switch w {
+	case Morlet, MexicanHat:
+		return math.Sqrt2
+	case Paul:
+		return 1 / math.Sqrt2
+	}
+	panic(fmt.Errorf("fouracc: unknown wavelet %d", int(w)))
+}
+
+func fact(n int) float64 {
+	v := 1.0
+	for i := 2; i <= n; i++ {
+		v *= float64(i)
+	}
+	return v
+}
+
+// CWT holds the result of a continuous wavelet transform.
+type CWT struct {
+	Data struct {
+		X []float64
+		Y []float64
+	}
+	Ts     []float64   // time series
+	Scales []float64   // wavelet scales, by decreasing values
+	Freqs  []float64   // Fourier frequencies of the wavelet scales, by increasing values
+	Coeffs [][]float64 // wavelet power, indexed by time and scale
+	COI    []float64   // cone of influence: lowest reliable frequency, per time
+
+	Name    string
+	Wavelet Wavelet
+	Scale   float64 // Frequency scale
+}
+
+// GeomScales returns n geometrically spaced wavelet scales from lo to hi.
+func GeomScales(lo, hi float64, n int) []float64 {
+	switch {
+	case n <= 0:
+		return nil
+	case n == 1:
+		return []float64{lo}
+	}
+	var (
+		scales = make([]float64, n)
+		ratio  = math.Pow(hi/lo, 1/float64(n-1))
+	)
+	for i := range scales {
+		scales[i] = lo * math.Pow(ratio, float64(i))
+	}
+	return scales
+}
+
+// WaveletTransform computes the continuous wavelet transform of ys, for
+// the provided wavelet and scales.
+// Scales are expressed in units of 1/freq, or in samples if freq is not
+// positive.
+func WaveletTransform(fname string, wavelet Wavelet, scales, xs, ys []float64, freq float64) CWT {
+	dt := 1.0
+	if freq > 0 {
+		dt = 1 / freq
+	}
+
+	cwt := CWT{
+		Ts:      xs,
+		Name:    fname,
+		Wavelet: wavelet,
+		Scale:   freq,
+	}
+	cwt.Data.X = xs
+	cwt.Data.Y = ys
+
+	n := len(ys)
+	if n == 0 || len(scales) == 0 {
+		return cwt
+	}
+
+	// sort scales by decreasing values, so frequencies are increasing.
+	cwt.Scales = append([]float64(nil), scales...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(cwt.Scales)))
+
+	// zero-pad to the next power of 2 to limit wrap-around effects.
+	npad := 1
+	for npad < n {
+		npad <<= 1
+	}
+
+	var (
+		fft  = fourier.NewCmplxFFT(npad)
+		seq  = make([]complex128, npad)
+		wrk  = make([]complex128, npad)
+		mean = 0.0
+	)
+	for _, v := range ys {
+		mean += v
+	}
+	mean /= float64(n)
+	for i, v := range ys {
+		seq[i] = complex(v-mean, 0)
+	}
+	coeffs := fft.Coefficients(nil, seq)
+
+	omegas := make([]float64, npad)
+	for k := range omegas {
+		omegas[k] = 2 * math.Pi * float64(k) / (float64(npad) * dt)
+		if k > npad/2 {
+			omegas[k] -= 2 * math.Pi / dt
+		}
+	}
+
+	cwt.Freqs = make([]float64, len(cwt.Scales))
+	cwt.Coeffs = make([][]float64, n)
+	for i := range cwt.Coeffs {
+		cwt.Coeffs[i] = make([]float64, len(cwt.Scales))
+	}
+
+	norm := complex(float64(npad), 0)
+	for j, s := range cwt.Scales {
+		cwt.Freqs[j] = 1 / (wavelet.fourierFactor() * s)
+		for k, c := range coeffs {
+			wrk[k] = c * cmplx.Conj(wavelet.daughter(s, omegas[k], dt))
+		}
+		ws := fft.Sequence(wrk, wrk)
+		for i := 0; i < n; i++ {
+			v := ws[i] / norm
+			cwt.Coeffs[i][j] = real(v)*real(v) + imag(v)*imag(v)
+		}
+	}
+
+	cwt.COI = make([]float64, n)
+	for i := range cwt.COI {
+		d := float64(i) * dt
+		if j := n - 1 - i; j < i {
+			d = float64(j) * dt
+		}
+		cwt.COI[i] = wavelet.efolding() / (wavelet.fourierFactor() * d)
+	}
+
+	return cwt
+}
+
+// Masked returns whether the (c,r) cell is inside the cone of influence,
+// where edge effects become important.
+func (cwt CWT) Masked(c, r int) bool {
+	return cwt.Freqs[r] < cwt.COI[c]
+}
+
+func (cwt CWT) Dims() (c, r int) {
+	if len(cwt.Coeffs) == 0 {
+		return 0, 0
+	}
+	return len(cwt.Coeffs), len(cwt.Coeffs[0])
+}
+
+func (cwt CWT) Z(c, r int) float64 { return cwt.Coeffs[c][r] }
+func (cwt CWT) X(c int) float64    { return cwt.Ts[c] }
+func (cwt CWT) Y(r int) float64    { return cwt.Freqs[r] }
+
+func (cwt CWT) Title() string {
+	if cwt.Scale > 0 {
+		return fmt.Sprintf("%s -- cwt=%v (freq=%v Hz)", cwt.Name, cwt.Wavelet, cwt.Scale)
+	}
+	return fmt.Sprintf("%s -- cwt=%v", cwt.Name, cwt.Wavelet)
+}
+
+func (cwt CWT) Series() (xs, ys []float64) { return cwt.Data.X, cwt.Data.Y }
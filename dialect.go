@@ -0,0 +1,274 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect describes the format of a CSV file.
+type Dialect struct {
+	Comma   rune   // field separator
+	Comment string // prefix of comment lines (empty for none)
+	Decimal rune   // decimal separator of numbers ('.' or ',')
+
+	// Header is the number of header rows, the last one naming the columns.
+	// A negative value detects a single header row from its content.
+	Header int
+
+	Quotes       bool // whether fields may be enclosed in double quotes
+	SkipBlank    bool // whether to skip blank lines
+	SkipTrailing bool // whether to drop empty fields at the end of rows
+}
+
+// DefaultDialect is the dialect of comma-separated files, with an optional
+// header row.
+var DefaultDialect = Dialect{
+	Comma:     ',',
+	Decimal:   '.',
+	Header:    -1,
+	Quotes:    true,
+	SkipBlank: true,
+}
+
+// sniffSize is the number of bytes inspected to sniff the dialect of a file.
+const sniffSize = 8 << 10
+
+var (
+	sniffCommas   = []rune{',', ';', '\t', '|'}
+	sniffComments = []string{"#", "//", "%", "!"}
+)
+
+// SniffDialect infers the dialect of a CSV file from a sample of its first
+// lines.
+// The separator and decimal separator are the ones under which the lines
+// split into the most consistent number of fields parsing as numbers or
+// timestamps.
+func SniffDialect(sample []byte) Dialect {
+	var (
+		d     = DefaultDialect
+		txt   = string(sample)
+		lines = strings.Split(strings.ReplaceAll(txt, "\r\n", "\n"), "\n")
+	)
+	if len(sample) >= sniffSize && len(lines) > 1 {
+		// drop the last, possibly partial, line.
+		lines = lines[:len(lines)-1]
+	}
+
+	var rows []string
+	for _, line := range lines {
+		s := strings.TrimSpace(line)
+		if s == "" {
+			continue
+		}
+		if d.Comment == "" && len(rows) == 0 {
+			for _, pre := range sniffComments {
+				if strings.HasPrefix(s, pre) {
+					d.Comment = pre
+					break
+				}
+			}
+		}
+		if d.Comment != "" && strings.HasPrefix(s, d.Comment) {
+			continue
+		}
+		rows = append(rows, line)
+	}
+	if len(rows) == 0 {
+		return d
+	}
+
+	best := -1.0
+	for _, comma := range sniffCommas {
+		for _, dec := range []rune{'.', ','} {
+			if dec == comma {
+				continue
+			}
+			cand := d
+			cand.Comma = comma
+			cand.Decimal = dec
+			if score := cand.score(rows); score > best {
+				best = score
+				d = cand
+			}
+		}
+	}
+
+	// leading rows which do not parse as data are header rows.
+	d.Header = 0
+	for _, row := range rows {
+		if !d.isHeader(d.split(row)) {
+			break
+		}
+		d.Header++
+	}
+	if d.Header == len(rows) {
+		// no data row in the sample: detect the header while loading.
+		d.Header = -1
+		return d
+	}
+
+	trailing := 0
+	for _, row := range rows[d.Header:] {
+		if strings.HasSuffix(strings.TrimSpace(row), string(d.Comma)) {
+			trailing++
+		}
+	}
+	d.SkipTrailing = trailing > 0 && trailing == len(rows)-d.Header
+
+	return d
+}
+
+// score rates how well the dialect fits the provided rows: the fraction of
+// data fields parsing as numbers or timestamps, weighted by the fraction
+// of rows with the most common number of fields and by that number.
+func (d Dialect) score(rows []string) float64 {
+	var (
+		counts = make(map[int]int)
+		fields = 0
+		valid  = 0
+	)
+	for _, row := range rows {
+		rec := d.split(row)
+		if d.isHeader(rec) {
+			continue
+		}
+		counts[len(rec)]++
+		for _, v := range rec {
+			fields++
+			if d.isValue(v) {
+				valid++
+			}
+		}
+	}
+	if fields == 0 {
+		return 0
+	}
+	n, mode := 0, 0
+	for k, c := range counts {
+		if c > mode || (c == mode && k > n) {
+			n, mode = k, c
+		}
+	}
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	var (
+		parsed     = float64(valid) / float64(fields)
+		consistent = float64(mode) / float64(total)
+	)
+	// prefer more columns, all other things being equal.
+	return parsed*consistent + 1e-3*float64(n)
+}
+
+// split splits a line into its (space-trimmed) fields.
+func (d Dialect) split(line string) []string {
+	line = strings.TrimRight(line, "\r\n")
+	var rec []string
+	if !d.Quotes || !strings.ContainsRune(line, '"') {
+		rec = strings.Split(line, string(d.Comma))
+	} else {
+		var (
+			cur    strings.Builder
+			quoted = false
+			rs     = []rune(line)
+		)
+		for i := 0; i < len(rs); i++ {
+			switch c := rs[i]; {
+			case c == '"' && quoted && i+1 < len(rs) && rs[i+1] == '"':
+				cur.WriteRune('"')
+				i++
+			case c == '"':
+				quoted = !quoted
+			case c == d.Comma && !quoted:
+				rec = append(rec, cur.String())
+				cur.Reset()
+			default:
+				cur.WriteRune(c)
+			}
+		}
+		rec = append(rec, cur.String())
+	}
+	for i, v := range rec {
+		rec[i] = strings.TrimSpace(v)
+	}
+	if d.SkipTrailing {
+		for len(rec) > 1 && rec[len(rec)-1] == "" {
+			rec = rec[:len(rec)-1]
+		}
+	}
+	return rec
+}
+
+// number parses a number with the decimal separator of the dialect.
+func (d Dialect) number(v string) (float64, error) {
+	if d.Decimal == ',' {
+		v = strings.Replace(v, ",", ".", 1)
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// isValue returns whether the field v is a number or a timestamp.
+func (d Dialect) isValue(v string) bool {
+	if v == "" {
+		return false
+	}
+	if _, err := d.number(v); err == nil {
+		return true
+	}
+	_, err := time.Parse(time.RFC3339Nano, v)
+	return err == nil
+}
+
+// isHeader returns whether the record is a header row, i.e. holds a
+// non-empty field which is neither a number nor a timestamp.
+func (d Dialect) isHeader(rec []string) bool {
+	for _, v := range rec {
+		if v == "" {
+			continue
+		}
+		if !d.isValue(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseComma parses a field separator: a single character, or one of
+// "tab", "comma", "semicolon", "pipe" and "space".
+func ParseComma(v string) (rune, error) {
+	switch strings.ToLower(v) {
+	case "tab", `\t`:
+		return '\t', nil
+	case "comma":
+		return ',', nil
+	case "semicolon":
+		return ';', nil
+	case "pipe":
+		return '|', nil
+	case "space":
+		return ' ', nil
+	}
+	rs := []rune(v)
+	if len(rs) != 1 || rs[0] == '"' || rs[0] == '\r' || rs[0] == '\n' {
+		return 0, fmt.Errorf("fouracc: invalid field separator %q", v)
+	}
+	return rs[0], nil
+}
+
+func (d Dialect) String() string {
+	comma := string(d.Comma)
+	if d.Comma == '\t' {
+		comma = "tab"
+	}
+	return fmt.Sprintf(
+		"sep=%q decimal=%q comment=%q header=%d quotes=%v skip-blank=%v skip-trailing=%v",
+		comma, string(d.Decimal), d.Comment, d.Header, d.Quotes, d.SkipBlank, d.SkipTrailing,
+	)
+}
@@ -0,0 +1,178 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Diff holds the comparison of two FFT results, e.g. before and after
+// a mechanical modification.
+// Differences are expressed in dB, positive when B is above A.
+type Diff struct {
+	Freqs  []float64   // frequencies of the bins
+	Ts     []float64   // chunked time series of A
+	Coeffs [][]float64 // amplitude difference (dB), per chunk
+
+	MeanA    []float64 // mean amplitude spectrum of A over the paired chunks
+	MeanB    []float64 // mean amplitude spectrum of B over the paired chunks
+	MeanDiff []float64 // difference of the mean spectra (dB)
+
+	NameA, NameB string
+	Relative     bool // whether chunks were paired on their relative time
+	Pairs        int  // number of paired chunks
+	Chunks       int
+	Scale        float64 // Frequency scale
+}
+
+// Difference compares the FFT results a and b, computed with the same chunk
+// size and frequency scale (within 1%).
+// The spectra of b are interpolated on the frequency bins of a.
+//
+// Chunks are paired in order, or, if relative is true, on their start time
+// relative to the start of each time series, within half a chunk.
+// Chunks of a without a counterpart in b are set to NaN.
+func Difference(a, b FFT, relative bool) (Diff, error) {
+	if a.Chunks != b.Chunks || !sameScale(a.Scale, b.Scale) {
+		return Diff{}, fmt.Errorf(
+			"fouracc: mismatched spectra (chunks=%d, freq=%v) and (chunks=%d, freq=%v)",
+			a.Chunks, a.Scale, b.Chunks, b.Scale,
+		)
+	}
+	if len(a.Coeffs) == 0 || len(b.Coeffs) == 0 {
+		return Diff{}, fmt.Errorf("fouracc: no spectrum to compare")
+	}
+
+	var (
+		n = len(a.Freqs) - 1 // coefficients do not include the DC bin.
+		d = Diff{
+			Freqs:    a.Freqs[1:],
+			Ts:       a.Ts,
+			Coeffs:   make([][]float64, len(a.Coeffs)),
+			MeanA:    make([]float64, n),
+			MeanB:    make([]float64, n),
+			MeanDiff: make([]float64, n),
+			NameA:    a.Name,
+			NameB:    b.Name,
+			Relative: relative,
+			Chunks:   a.Chunks,
+			Scale:    a.Scale,
+		}
+		bfreqs = b.Freqs[1:]
+		pairs  = pairChunks(a.Ts, b.Ts, relative)
+		counts = make([]int, n)
+	)
+	if relative {
+		d.Ts = make([]float64, len(a.Ts))
+		for i, t := range a.Ts {
+			d.Ts[i] = t - a.Ts[0]
+		}
+	}
+
+	for c, cs := range a.Coeffs {
+		d.Coeffs[c] = make([]float64, n)
+		j := pairs[c]
+		if j < 0 {
+			for r := range d.Coeffs[c] {
+				d.Coeffs[c][r] = math.NaN()
+			}
+			continue
+		}
+		d.Pairs++
+		for r, va := range cs {
+			vb := interp(bfreqs, b.Coeffs[j], d.Freqs[r])
+			d.Coeffs[c][r] = 20 * math.Log10(vb/va)
+			if math.IsNaN(d.Coeffs[c][r]) {
+				continue
+			}
+			d.MeanA[r] += va
+			d.MeanB[r] += vb
+			counts[r]++
+		}
+	}
+
+	for r, n := range counts {
+		if n == 0 {
+			d.MeanA[r] = math.NaN()
+			d.MeanB[r] = math.NaN()
+			d.MeanDiff[r] = math.NaN()
+			continue
+		}
+		d.MeanA[r] /= float64(n)
+		d.MeanB[r] /= float64(n)
+		d.MeanDiff[r] = 20 * math.Log10(d.MeanB[r]/d.MeanA[r])
+	}
+
+	return d, nil
+}
+
+// pairChunks returns, for each chunk of a, the index of the chunk of b it
+// is compared with, or -1.
+func pairChunks(ta, tb []float64, relative bool) []int {
+	pairs := make([]int, len(ta))
+	if !relative {
+		for i := range pairs {
+			pairs[i] = -1
+			if i < len(tb) {
+				pairs[i] = i
+			}
+		}
+		return pairs
+	}
+
+	tol := math.Inf(+1)
+	switch {
+	case len(ta) > 1:
+		tol = 0.5 * (ta[1] - ta[0])
+	case len(tb) > 1:
+		tol = 0.5 * (tb[1] - tb[0])
+	}
+	for i, t := range ta {
+		var (
+			rel = t - ta[0]
+			j   = sort.Search(len(tb), func(j int) bool { return tb[j]-tb[0] >= rel })
+		)
+		best := -1
+		for _, k := range []int{j - 1, j} {
+			if k < 0 || k >= len(tb) {
+				continue
+			}
+			dt := math.Abs(tb[k] - tb[0] - rel)
+			if dt <= tol && (best < 0 || dt < math.Abs(tb[best]-tb[0]-rel)) {
+				best = k
+			}
+		}
+		pairs[i] = best
+	}
+	return pairs
+}
+
+// interp returns the linear interpolation at x of the ys values sampled
+// at the increasing xs abscissae, or NaN outside of their range.
+func interp(xs, ys []float64, x float64) float64 {
+	i := sort.SearchFloat64s(xs, x)
+	switch {
+	case i < len(xs) && xs[i] == x:
+		return ys[i]
+	case i == 0 || i == len(xs):
+		return math.NaN()
+	}
+	f := (x - xs[i-1]) / (xs[i] - xs[i-1])
+	return ys[i-1] + f*(ys[i]-ys[i-1])
+}
+
+func (d Diff) Dims() (c, r int)   { return len(d.Coeffs), len(d.Freqs) }
+func (d Diff) Z(c, r int) float64 { return d.Coeffs[c][r] }
+func (d Diff) X(c int) float64    { return d.Ts[c] }
+func (d Diff) Y(r int) float64    { return d.Freqs[r] }
+
+func (d Diff) Title() string {
+	if d.Scale > 0 {
+		return fmt.Sprintf("%s vs %s -- chunks=%d (freq=%v Hz)", d.NameB, d.NameA, d.Chunks, d.Scale)
+	}
+	return fmt.Sprintf("%s vs %s -- chunks=%d", d.NameB, d.NameA, d.Chunks)
+}
@@ -0,0 +1,193 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Ensemble holds the statistics of the spectra of repeated recordings of the
+// same measurement, analyzed with identical parameters.
+//
+// The ensemble-averaged spectrum is the mean, over recordings, of the mean
+// spectrum of each recording, with the variance between recordings and the
+// Student's t confidence band of the mean.
+// The spectrogram statistics are computed across recordings for each chunk,
+// with chunks aligned on their time relative to the start of each recording.
+type Ensemble struct {
+	Freqs []float64 // frequencies of the bins
+	Mean  []float64 // ensemble-averaged amplitude spectrum
+	Var   []float64 // variance of the mean spectra of the recordings
+	Lower []float64 // lower bound of the confidence band of Mean
+	Upper []float64 // upper bound of the confidence band of Mean
+
+	Ts     []float64   // start of the chunks, relative to the start of the recordings
+	Coeffs [][]float64 // mean amplitude across recordings, per chunk
+	Vars   [][]float64 // variance of the amplitude across recordings, per chunk
+	Counts []int       // number of recordings covering each chunk
+
+	Names   []string // names of the recordings
+	Count   int      // number of recordings
+	Spectra int      // number of complete chunk spectra
+	Level   float64  // confidence level of the band
+	Chunks  int
+	Scale   float64 // Frequency scale
+}
+
+// EnsembleAverage computes the ensemble statistics of the provided FFT
+// results, with a confidence band at the provided level (e.g. 0.95).
+// All FFT results must share the same chunk size and frequency scale (within 1%):
+// see Resample to bring recordings to a common sampling frequency.
+// Chunks with missing (NaN) coefficients are ignored.
+//
+// Variances and confidence bands are NaN when fewer than 2 recordings
+// contribute.
+func EnsembleAverage(ffts []FFT, level float64) (Ensemble, error) {
+	if len(ffts) == 0 {
+		return Ensemble{}, fmt.Errorf("fouracc: no recording to average")
+	}
+	if level <= 0 || level >= 1 {
+		return Ensemble{}, fmt.Errorf("fouracc: invalid confidence level %v", level)
+	}
+
+	ref := ffts[0]
+	for _, fft := range ffts[1:] {
+		if fft.Chunks != ref.Chunks || !sameScale(fft.Scale, ref.Scale) || len(fft.Freqs) != len(ref.Freqs) {
+			return Ensemble{}, fmt.Errorf(
+				"fouracc: mismatched recordings %q (chunks=%d, freq=%v) and %q (chunks=%d, freq=%v)",
+				ref.Name, ref.Chunks, ref.Scale, fft.Name, fft.Chunks, fft.Scale,
+			)
+		}
+	}
+
+	var (
+		n   = len(ref.Freqs) - 1 // coefficients do not include the DC bin.
+		ens = Ensemble{
+			Freqs:  ref.Freqs[1:],
+			Mean:   make([]float64, n),
+			Var:    make([]float64, n),
+			Lower:  make([]float64, n),
+			Upper:  make([]float64, n),
+			Names:  make([]string, len(ffts)),
+			Count:  len(ffts),
+			Level:  level,
+			Chunks: ref.Chunks,
+			Scale:  ref.Scale,
+		}
+		means = make([][]float64, len(ffts)) // mean spectrum of each recording
+		nts   = 0
+	)
+
+	for i, fft := range ffts {
+		ens.Names[i] = fft.Name
+		if len(fft.Ts) > nts {
+			nts = len(fft.Ts)
+			ens.Ts = make([]float64, nts)
+			for j, t := range fft.Ts {
+				ens.Ts[j] = t - fft.Ts[0]
+			}
+		}
+
+		mean := make([]float64, n)
+		count := 0
+	chunks:
+		for _, cs := range fft.Coeffs {
+			for _, c := range cs {
+				if math.IsNaN(c) {
+					continue chunks
+				}
+			}
+			for r, c := range cs {
+				mean[r] += c
+			}
+			count++
+		}
+		if count == 0 {
+			return Ensemble{}, fmt.Errorf("fouracc: no complete chunk in recording %q", fft.Name)
+		}
+		for r := range mean {
+			mean[r] /= float64(count)
+		}
+		means[i] = mean
+		ens.Spectra += count
+	}
+
+	t := tQuantile(level, len(ffts))
+	vs := make([]float64, len(ffts))
+	for r := range ens.Mean {
+		for i, mean := range means {
+			vs[i] = mean[r]
+		}
+		mean, v := meanVar(vs)
+		ens.Mean[r] = mean
+		ens.Var[r] = v
+		hw := t * math.Sqrt(v/float64(len(vs)))
+		ens.Lower[r] = mean - hw
+		ens.Upper[r] = mean + hw
+	}
+
+	ens.Coeffs = make([][]float64, nts)
+	ens.Vars = make([][]float64, nts)
+	ens.Counts = make([]int, nts)
+	for c := range ens.Coeffs {
+		ens.Coeffs[c] = make([]float64, n)
+		ens.Vars[c] = make([]float64, n)
+		for r := 0; r < n; r++ {
+			vs = vs[:0]
+			for _, fft := range ffts {
+				if c >= len(fft.Coeffs) || math.IsNaN(fft.Coeffs[c][r]) {
+					continue
+				}
+				vs = append(vs, fft.Coeffs[c][r])
+			}
+			if r == 0 {
+				ens.Counts[c] = len(vs)
+			}
+			if len(vs) == 0 {
+				ens.Coeffs[c][r] = math.NaN()
+				ens.Vars[c][r] = math.NaN()
+				continue
+			}
+			ens.Coeffs[c][r], ens.Vars[c][r] = meanVar(vs)
+		}
+	}
+
+	return ens, nil
+}
+
+// meanVar returns the mean and the unbiased variance of the values, or NaN
+// for the variance of fewer than 2 values.
+func meanVar(vs []float64) (mean, v float64) {
+	mean, std := meanStd(vs)
+	if len(vs) < 2 {
+		return mean, math.NaN()
+	}
+	return mean, std * std
+}
+
+// tQuantile returns the two-sided Student's t factor of the confidence
+// interval of the mean of n values at the provided level, or NaN if n < 2.
+func tQuantile(level float64, n int) float64 {
+	if n < 2 {
+		return math.NaN()
+	}
+	dist := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: float64(n - 1)}
+	return dist.Quantile(0.5 * (1 + level))
+}
+
+func (ens Ensemble) Dims() (c, r int)   { return len(ens.Coeffs), len(ens.Freqs) }
+func (ens Ensemble) Z(c, r int) float64 { return ens.Coeffs[c][r] }
+func (ens Ensemble) X(c int) float64    { return ens.Ts[c] }
+func (ens Ensemble) Y(r int) float64    { return ens.Freqs[r] }
+
+func (ens Ensemble) Title() string {
+	if ens.Scale > 0 {
+		return fmt.Sprintf("ensemble of %d recording(s) -- chunks=%d (freq=%v Hz)", ens.Count, ens.Chunks, ens.Scale)
+	}
+	return fmt.Sprintf("ensemble of %d recording(s) -- chunks=%d", ens.Count, ens.Chunks)
+}
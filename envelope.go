@@ -0,0 +1,135 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"math"
+	"math/cmplx"
+
+	"gonum.org/v1/gonum/dsp/fourier"
+)
+
+// Envelope holds the result of an envelope (demodulation) analysis.
+type Envelope struct {
+	Ts       []float64 // time series
+	Signal   []float64 // band-passed signal
+	Env      []float64 // envelope of the band-passed signal
+	Freqs    []float64 // frequencies of the envelope spectrum
+	Spectrum []float64 // amplitudes of the envelope spectrum
+
+	Name  string
+	Lo    float64 // low edge of the carrier band
+	Hi    float64 // high edge of the carrier band
+	Scale float64 // Frequency scale
+}
+
+// EnvelopeSpectrum band-passes ys around the [lo,hi] carrier band, takes its
+// analytic signal and computes the amplitude spectrum of the resulting envelope.
+// lo and hi are expressed in the same units as freq.
+// A non-positive hi selects all frequencies up to Nyquist.
+func EnvelopeSpectrum(fname string, xs, ys []float64, freq, lo, hi float64) Envelope {
+	scale := 1.0
+	if freq > 0 {
+		scale = freq
+	}
+	env := Envelope{
+		Ts:    xs,
+		Name:  fname,
+		Lo:    lo,
+		Hi:    hi,
+		Scale: freq,
+	}
+	if len(ys) == 0 {
+		return env
+	}
+
+	env.Signal = BandPass(ys, freq, lo, hi)
+	as := Hilbert(env.Signal)
+	env.Env = make([]float64, len(as))
+	mean := 0.0
+	for i, v := range as {
+		env.Env[i] = cmplx.Abs(v)
+		mean += env.Env[i]
+	}
+	mean /= float64(len(as))
+
+	var (
+		n   = len(env.Env)
+		fft = fourier.NewFFT(n)
+		wrk = make([]float64, n)
+	)
+	for i, v := range env.Env {
+		wrk[i] = v - mean
+	}
+	cs := fft.Coefficients(nil, wrk)
+	env.Freqs = make([]float64, len(cs))
+	env.Spectrum = make([]float64, len(cs))
+	for i, c := range cs {
+		env.Freqs[i] = fft.Freq(i) * scale
+		env.Spectrum[i] = 2 * cmplx.Abs(c) / float64(n)
+	}
+
+	return env
+}
+
+// BandPass returns ys filtered in the frequency domain, keeping only
+// the frequencies within [lo,hi].
+// A non-positive hi selects all frequencies up to Nyquist.
+func BandPass(ys []float64, freq, lo, hi float64) []float64 {
+	scale := 1.0
+	if freq > 0 {
+		scale = freq
+	}
+	if hi <= 0 {
+		hi = math.Inf(+1)
+	}
+
+	n := len(ys)
+	if n == 0 {
+		return nil
+	}
+	fft := fourier.NewFFT(n)
+	cs := fft.Coefficients(nil, ys)
+	for i := range cs {
+		f := fft.Freq(i) * scale
+		if f < lo || hi < f {
+			cs[i] = 0
+		}
+	}
+	out := fft.Sequence(nil, cs)
+	for i := range out {
+		out[i] /= float64(n)
+	}
+	return out
+}
+
+// Hilbert returns the analytic signal of ys.
+// The imaginary part of the analytic signal is the Hilbert transform of ys.
+func Hilbert(ys []float64) []complex128 {
+	n := len(ys)
+	if n == 0 {
+		return nil
+	}
+
+	fft := fourier.NewCmplxFFT(n)
+	seq := make([]complex128, n)
+	for i, v := range ys {
+		seq[i] = complex(v, 0)
+	}
+	cs := fft.Coefficients(nil, seq)
+	// keep DC and Nyquist, double positive and drop negative frequencies.
+	for i := 1; i < (n+1)/2; i++ {
+		cs[i] *= 2
+	}
+	for i := n/2 + 1; i < n; i++ {
+		cs[i] = 0
+	}
+	out := fft.Sequence(seq, cs)
+	norm := complex(float64(n), 0)
+	for i := range out {
+		out[i] /= norm
+	}
+	return out
+}
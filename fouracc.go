@@ -6,12 +6,25 @@
 package fouracc
 
 import (
+	"fmt"
 	"math"
 	"math/cmplx"
 
 	"gonum.org/v1/gonum/dsp/fourier"
+	"gonum.org/v1/plot/plotter"
 )
 
+// Spectrogram is a time-frequency representation of a time series.
+type Spectrogram interface {
+	plotter.GridXYZ
+
+	// Title returns a description of the analysis.
+	Title() string
+
+	// Series returns the analyzed time series.
+	Series() (xs, ys []float64)
+}
+
 type FFT struct {
 	Data struct {
 		X []float64
@@ -82,4 +95,13 @@ func ChunkedFFT(fname string, chunksz int, xs, ys []float64, freq float64) FFT {
 func (fft FFT) Dims() (c, r int)   { return len(fft.Coeffs), len(fft.Coeffs[0]) }
 func (fft FFT) Z(c, r int) float64 { return fft.Coeffs[c][r] }
 func (fft FFT) X(c int) float64    { return fft.Ts[c] }
-func (fft FFT) Y(r int) float64    { return fft.Freqs[r] }
+func (fft FFT) Y(r int) float64    { return fft.Freqs[r+1] } // coefficients do not include the DC bin.
+
+func (fft FFT) Title() string {
+	if fft.Scale > 0 {
+		return fmt.Sprintf("%s -- chunks=%d (freq=%v Hz)", fft.Name, fft.Chunks, fft.Scale)
+	}
+	return fmt.Sprintf("%s -- chunks=%d", fft.Name, fft.Chunks)
+}
+
+func (fft FFT) Series() (xs, ys []float64) { return fft.Data.X, fft.Data.Y }
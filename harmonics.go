@@ -0,0 +1,276 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+// harmonicSNR is the ratio of the amplitude of a spectral peak to the
+// median amplitude of its chunk, above which it may belong to a family.
+const harmonicSNR = 4
+
+// Family is a harmonic series of spectral peaks, e.g. from a rotating
+// machine, tracked over time.
+type Family struct {
+	ID     int
+	Ts     []float64 // start of the chunks where the family was detected
+	F0     []float64 // fundamental frequency, per detection
+	Orders [][]int   // harmonic numbers of the detected peaks, per detection
+
+	Mean     float64 // mean fundamental frequency
+	Min, Max float64 // range of the fundamental frequency
+}
+
+// MaxOrder returns the highest harmonic number detected in the family.
+func (fam Family) MaxOrder() int {
+	max := 0
+	for _, ns := range fam.Orders {
+		for _, n := range ns {
+			if n > max {
+				max = n
+			}
+		}
+	}
+	return max
+}
+
+// Label returns the label of the family on plots.
+func (fam Family) Label() string {
+	return fmt.Sprintf("H%d: %.4g", fam.ID, fam.Mean)
+}
+
+// Harmonics holds the harmonic families of a spectrogram.
+type Harmonics struct {
+	Families []Family
+
+	Tol    float64 // relative frequency tolerance
+	Min    int     // minimum number of peaks of a family
+	Chunks int     // number of chunks of the spectrogram
+}
+
+// HarmonicFamilies groups the spectral peaks of each chunk of the
+// spectrogram into harmonic families, whose peaks lie within the relative
+// tolerance tol (or half a frequency bin) of integer multiples of their
+// fundamental frequency.
+// A family has at least min peaks, without two consecutive missing
+// harmonics.
+// Families of consecutive chunks whose fundamentals agree within tol are
+// tracked as a single family.
+func HarmonicFamilies(spec plotter.GridXYZ, tol float64, min int) Harmonics {
+	if min < 2 {
+		min = 2
+	}
+	var (
+		cols, _ = spec.Dims()
+		out     = Harmonics{Tol: tol, Min: min, Chunks: cols}
+		tracks  []*Family
+		last    []int // last chunk of each track
+	)
+
+	for c := 0; c < cols; c++ {
+		fams := harmonicGroups(spectralPeaks(spec, c), tol, min)
+		used := make([]bool, len(tracks))
+		for _, fam := range fams {
+			best := -1
+			for i, trk := range tracks {
+				if used[i] || c-last[i] > 2 {
+					continue
+				}
+				prev := trk.F0[len(trk.F0)-1]
+				if math.Abs(fam.f0-prev) > math.Max(tol*prev, fam.hb) {
+					continue
+				}
+				if best < 0 || math.Abs(fam.f0-prev) < math.Abs(fam.f0-tracks[best].F0[len(tracks[best].F0)-1]) {
+					best = i
+				}
+			}
+			if best < 0 {
+				tracks = append(tracks, &Family{ID: len(tracks) + 1})
+				last = append(last, c)
+				used = append(used, false)
+				best = len(tracks) - 1
+			}
+			trk := tracks[best]
+			trk.Ts = append(trk.Ts, spec.X(c))
+			trk.F0 = append(trk.F0, fam.f0)
+			trk.Orders = append(trk.Orders, fam.orders)
+			last[best] = c
+			used[best] = true
+		}
+	}
+
+	for _, trk := range tracks {
+		trk.Min = math.Inf(+1)
+		trk.Max = math.Inf(-1)
+		for _, f := range trk.F0 {
+			trk.Mean += f
+			trk.Min = math.Min(trk.Min, f)
+			trk.Max = math.Max(trk.Max, f)
+		}
+		trk.Mean /= float64(len(trk.F0))
+		out.Families = append(out.Families, *trk)
+	}
+	return out
+}
+
+// spectralPeak is a local maximum of a chunk of a spectrogram.
+type spectralPeak struct {
+	freq float64 // interpolated frequency
+	amp  float64
+	hb   float64 // half the local frequency bin width
+}
+
+// spectralPeaks returns the local maxima of the c-th chunk of the
+// spectrogram above harmonicSNR times its median amplitude, by increasing
+// frequency.
+func spectralPeaks(spec plotter.GridXYZ, c int) []spectralPeak {
+	_, rows := spec.Dims()
+	vs := make([]float64, 0, rows)
+	for r := 0; r < rows; r++ {
+		if v := spec.Z(c, r); !math.IsNaN(v) {
+			vs = append(vs, v)
+		}
+	}
+	if len(vs) < 3 {
+		return nil
+	}
+	sort.Float64s(vs)
+	thr := harmonicSNR * percentile(vs, 50)
+
+	var peaks []spectralPeak
+	for r := 1; r < rows-1; r++ {
+		var (
+			z0 = spec.Z(c, r-1)
+			z1 = spec.Z(c, r)
+			z2 = spec.Z(c, r+1)
+		)
+		if !(z1 > thr && z1 > z0 && z1 >= z2) {
+			continue
+		}
+		var (
+			y0, y1, y2 = spec.Y(r - 1), spec.Y(r), spec.Y(r + 1)
+			pk         = spectralPeak{freq: y1, amp: z1, hb: 0.25 * (y2 - y0)}
+		)
+		// vertex of the parabola through the 3 bins.
+		num := (y1-y0)*(y1-y0)*(z1-z2) - (y1-y2)*(y1-y2)*(z1-z0)
+		den := (y1-y0)*(z1-z2) - (y1-y2)*(z1-z0)
+		if den != 0 {
+			if f := y1 - 0.5*num/den; y0 < f && f < y2 {
+				pk.freq = f
+			}
+		}
+		peaks = append(peaks, pk)
+	}
+	return peaks
+}
+
+// harmonicGroup is a harmonic family within a chunk.
+type harmonicGroup struct {
+	f0     float64 // fundamental frequency
+	hb     float64 // half the frequency bin width at the fundamental
+	orders []int
+}
+
+// harmonicGroups greedily groups the peaks into harmonic families, the
+// family with the most peaks first.
+func harmonicGroups(peaks []spectralPeak, tol float64, min int) []harmonicGroup {
+	var (
+		groups []harmonicGroup
+		free   = make([]bool, len(peaks))
+	)
+	for i := range free {
+		free[i] = true
+	}
+
+	// members returns the free peaks matching the harmonics of f0,
+	// indexed by harmonic number.
+	members := func(f0, hb float64) map[int]int {
+		ms := make(map[int]int)
+		for i, pk := range peaks {
+			if !free[i] {
+				continue
+			}
+			n := int(math.Round(pk.freq / f0))
+			if n < 1 {
+				continue
+			}
+			d := math.Abs(pk.freq - float64(n)*f0)
+			if d > math.Max(tol*pk.freq, hb) {
+				continue
+			}
+			if j, dup := ms[n]; dup && math.Abs(peaks[j].freq-float64(n)*f0) <= d {
+				continue
+			}
+			ms[n] = i
+		}
+		return ms
+	}
+
+	for {
+		var (
+			best  map[int]int
+			bestI int
+			bestA float64
+		)
+		for i, pk := range peaks {
+			if !free[i] {
+				continue
+			}
+			ms := members(pk.freq, pk.hb)
+			// drop the harmonics past two consecutive missing ones.
+			for n, miss := 1, 0; len(ms) > 0 && n <= len(peaks)+2; n++ {
+				if _, ok := ms[n]; ok {
+					miss = 0
+					continue
+				}
+				miss++
+				if miss == 2 {
+					for k := range ms {
+						if k > n {
+							delete(ms, k)
+						}
+					}
+					break
+				}
+			}
+			amp := 0.0
+			for _, j := range ms {
+				amp += peaks[j].amp
+			}
+			if len(ms) < min {
+				continue
+			}
+			if len(ms) > len(best) || (len(ms) == len(best) && amp > bestA) {
+				best, bestI, bestA = ms, i, amp
+			}
+		}
+		if best == nil {
+			break
+		}
+
+		// least-squares fundamental through the origin.
+		var (
+			grp    = harmonicGroup{hb: peaks[bestI].hb}
+			sn, sf float64
+		)
+		for n, j := range best {
+			sn += float64(n * n)
+			sf += float64(n) * peaks[j].freq
+			grp.orders = append(grp.orders, n)
+			free[j] = false
+		}
+		grp.f0 = sf / sn
+		sort.Ints(grp.orders)
+		groups = append(groups, grp)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].f0 < groups[j].f0 })
+	return groups
+}
@@ -0,0 +1,545 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package analysis runs the analyses of the fouracc commands on a time
+// series, and renders their plots and data tables.
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/lsst-lpc/fouracc"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+)
+
+// Kinds of analysis outputs.
+const (
+	KindSpectrogram = ""
+	KindModes       = "modes"
+	KindHarmonics   = "harmonics"
+	KindEnvelope    = "envelope"
+	KindOctave      = "octave"
+	KindCumRMS      = "cumrms"
+	KindWeighted    = "wrms"
+	KindAnomaly     = "anomaly"
+	KindSRS         = "srs"
+	KindVC          = "vc"
+)
+
+// Options holds the configuration of the analyses to run on each data set.
+type Options struct {
+	ChunkSize int
+	Method    string // time-frequency analysis method (stft, cwt, mtm, lomb)
+
+	Wavelet    fouracc.Wavelet // mother wavelet of the CWT
+	NScales    int             // number of CWT scales
+	SMin, SMax float64         // range of the CWT scales (0 for automatic)
+
+	NW     float64 // time-bandwidth product of the multitaper analysis
+	Tapers int     // number of DPSS tapers (0 for 2*nw-1)
+
+	TScale float64 // number of time units per second of the time series (lomb)
+
+	Octave int // bandwidth designator of the fractional-octave analysis (0 to disable)
+
+	Modes       int                 // number of spectral peaks of the modal analysis (0 to disable)
+	ModalMethod fouracc.ModalMethod // modal parameters annotated on the spectrogram
+
+	HarmTol float64 // relative frequency tolerance of the harmonic families (0 to disable)
+	HarmMin int     // minimum number of peaks of a harmonic family
+
+	CumRMS bool // whether to compute the cumulative RMS vs frequency
+
+	VC       bool            // whether to evaluate the vibration criteria
+	VCTarget fouracc.VCClass // VC curve to comply with
+	AccScale float64         // conversion factor of the acceleration data to m/s²
+
+	Weighted  bool              // whether to compute the frequency-weighted RMS
+	Weighting fouracc.Weighting // frequency weighting of the acceleration
+	Tau       float64           // integration time of the running RMS (s)
+
+	Baselines map[string]fouracc.Baseline // reference spectra, per axis ("" for single series)
+	ScoreUnit fouracc.ScoreUnit           // unit of the anomaly threshold
+	Threshold float64                     // anomaly threshold over the baselines
+
+	SRS     bool            // whether to compute the shock response spectrum
+	SRST0   float64         // start of the SRS event window (s)
+	SRST1   float64         // end of the SRS event window (s)
+	SRSQ    float64         // quality factor of the SRS oscillators
+	SRSFmin float64         // lowest SRS natural frequency (0 for automatic)
+	SRSFmax float64         // highest SRS natural frequency (0 for automatic)
+	SRSKind fouracc.SRSKind // response quantity of the SRS
+
+	Env   bool    // whether to run the envelope analysis
+	EnvLo float64 // low edge of the envelope carrier band
+	EnvHi float64 // high edge of the envelope carrier band
+}
+
+// Validate checks the parameters of the analyses selected by opts.
+func (opts Options) Validate() error {
+	if opts.ChunkSize <= 0 {
+		return fmt.Errorf("invalid chunk size %d (want > 0)", opts.ChunkSize)
+	}
+	if opts.SRS && !(opts.SRSQ > 0.5) {
+		return fmt.Errorf("invalid SRS quality factor Q=%v (want Q > 0.5)", opts.SRSQ)
+	}
+	switch opts.Method {
+	case "cwt":
+		switch {
+		case opts.NScales <= 0:
+			return fmt.Errorf("invalid number of CWT scales %d (want > 0)", opts.NScales)
+		case opts.SMin < 0 || opts.SMax < 0:
+			return fmt.Errorf("invalid CWT scales range [%v, %v] (want positive scales)", opts.SMin, opts.SMax)
+		case opts.SMin > 0 && opts.SMax > 0 && opts.SMin >= opts.SMax:
+			return fmt.Errorf("invalid CWT scales range [%v, %v] (want smin < smax)", opts.SMin, opts.SMax)
+		}
+	case "mtm":
+		switch {
+		case opts.NW <= 0:
+			return fmt.Errorf("invalid time-bandwidth product NW=%v (want NW > 0)", opts.NW)
+		case opts.NW >= float64(opts.ChunkSize)/2:
+			return fmt.Errorf("invalid time-bandwidth product NW=%v (want NW < chunk size/2)", opts.NW)
+		case opts.Tapers < 0:
+			return fmt.Errorf("invalid number of tapers %d", opts.Tapers)
+		case opts.Tapers == 0 && int(2*opts.NW)-1 < 1:
+			return fmt.Errorf("no default taper for NW=%v (want NW >= 1 or an explicit number of tapers)", opts.NW)
+		}
+	}
+	return nil
+}
+
+// Output is the result of an analysis of a data set: a plot and a data
+// table.
+type Output struct {
+	Kind string // kind of analysis
+	Axis string // axis of the analyzed data set
+
+	PNG    []byte      // plot (nil if none)
+	Header string      // header of the data table
+	Cols   [][]float64 // columns of the data table (nil if none)
+
+	Spec   fouracc.Spectrogram // time-frequency analysis (KindSpectrogram)
+	CumRMS *fouracc.CumRMS     // cumulative RMS (KindCumRMS)
+	VC     *fouracc.VC         // vibration criteria evaluation (KindVC)
+}
+
+// Name returns the name of the data set of the provided file and axis.
+func Name(fname, axis string) string {
+	if axis == "" {
+		return fname
+	}
+	return fname + " [axis=" + axis + "]"
+}
+
+const (
+	width  = 20 * vg.Centimeter
+	height = 30 * vg.Centimeter // of the time-frequency plots
+	short  = 20 * vg.Centimeter // of the other plots
+)
+
+// Run runs the analyses selected by opts on the time series (xs, ys) of
+// the axis of the named file, sampled at freq.
+// The spectrogram is always the first output.
+func Run(fname, axis string, opts Options, xs, ys []float64, freq float64) ([]Output, error) {
+	name := Name(fname, axis)
+	log.Printf("processing %q: %d sample(s)...", name, len(ys))
+
+	spec, err := Spectrogram(name, opts, xs, ys, freq)
+	if err != nil {
+		return nil, fmt.Errorf("could not run %s analysis: %w", opts.Method, err)
+	}
+	{
+		c, r := spec.Dims()
+		log.Printf("dims [%s]: (c=%d, r=%d)", name, c, r)
+	}
+
+	var (
+		outs  = []Output{{Kind: KindSpectrogram, Axis: axis, Spec: spec}}
+		modes []fouracc.Mode
+	)
+	if opts.Modes > 0 {
+		var out Output
+		modes, out, err = modal(name, axis, opts, ys, freq)
+		if err != nil {
+			return nil, fmt.Errorf("could not extract modal parameters: %w", err)
+		}
+		outs = append(outs, out)
+	}
+
+	img, err := Render(height, func(dc draw.Canvas) error {
+		return fouracc.Plot(dc, spec, modes...)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not plot %s: %w", opts.Method, err)
+	}
+	outs[0].PNG = img
+	outs[0].Header, outs[0].Cols = SpectrogramTable(spec)
+
+	base, hasBase := opts.Baselines[axis]
+	for _, run := range []struct {
+		ok  bool
+		msg string
+		fct func() (Output, error)
+	}{
+		{opts.HarmTol > 0, "detect harmonic families", func() (Output, error) {
+			return harmonics(axis, spec, opts)
+		}},
+		{opts.Env, "run envelope analysis", func() (Output, error) {
+			return envelope(name, axis, opts, xs, ys, freq)
+		}},
+		{opts.Octave > 0, "run octave analysis", func() (Output, error) {
+			return bands(name, axis, opts, xs, ys, freq)
+		}},
+		{opts.CumRMS, "compute cumulative RMS", func() (Output, error) {
+			return cumulative(name, axis, ys, freq)
+		}},
+		{opts.Weighted, "run frequency weighting", func() (Output, error) {
+			return weighted(name, axis, opts, xs, ys, freq)
+		}},
+		{hasBase, "run anomaly detection", func() (Output, error) {
+			fft, ok := spec.(fouracc.FFT)
+			if !ok {
+				fft = fouracc.ChunkedFFT(name, opts.ChunkSize, xs, ys, freq)
+			}
+			return anomalies(axis, base, fft, opts)
+		}},
+		{opts.SRS, "compute shock response spectrum", func() (Output, error) {
+			return shock(name, axis, opts, ys, freq)
+		}},
+		{opts.VC, "evaluate vibration criteria", func() (Output, error) {
+			return criteria(name, axis, opts, xs, ys, freq)
+		}},
+	} {
+		if !run.ok {
+			continue
+		}
+		out, err := run.fct()
+		if err != nil {
+			return nil, fmt.Errorf("could not %s: %w", run.msg, err)
+		}
+		outs = append(outs, out)
+	}
+
+	log.Printf("processing %q... [done]", name)
+	return outs, nil
+}
+
+// Render draws a plot of height h with fct, and returns it encoded as PNG.
+func Render(h vg.Length, fct func(dc draw.Canvas) error) ([]byte, error) {
+	c := vgimg.PngCanvas{Canvas: vgimg.New(width, h)}
+	err := fct(draw.New(c))
+	if err != nil {
+		return nil, err
+	}
+	o := new(bytes.Buffer)
+	_, err = c.WriteTo(o)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode plot: %w", err)
+	}
+	return o.Bytes(), nil
+}
+
+// Spectrogram runs the time-frequency analysis selected by opts.
+func Spectrogram(fname string, opts Options, xs, ys []float64, freq float64) (fouracc.Spectrogram, error) {
+	switch opts.Method {
+	case "cwt":
+		dt := 1.0
+		if freq > 0 {
+			dt = 1 / freq
+		}
+		smin, smax := opts.SMin, opts.SMax
+		if smin <= 0 {
+			smin = 2 * dt
+		}
+		if smax <= 0 {
+			smax = float64(len(ys)) * dt / 4
+		}
+		if smin >= smax {
+			return nil, fmt.Errorf("invalid CWT scales range [%v, %v] (want smin < smax)", smin, smax)
+		}
+		scales := fouracc.GeomScales(smin, smax, opts.NScales)
+		return fouracc.WaveletTransform(fname, opts.Wavelet, scales, xs, ys, freq), nil
+	case "mtm":
+		return fouracc.ChunkedMultitaper(fname, opts.ChunkSize, xs, ys, freq, opts.NW, opts.Tapers)
+	case "lomb":
+		return fouracc.ChunkedLombScargle(fname, opts.ChunkSize, xs, ys, opts.TScale), nil
+	default:
+		return fouracc.ChunkedFFT(fname, opts.ChunkSize, xs, ys, freq), nil
+	}
+}
+
+// maxIssues is the maximum number of families or events displayed.
+const maxIssues = 10
+
+// modal extracts the modal parameters of the highest peaks of the positive
+// power spectrum of ys, with all methods, and returns those of the method
+// selected for the annotation of the spectrogram.
+func modal(name, axis string, opts Options, ys []float64, freq float64) ([]fouracc.Mode, Output, error) {
+	frf, err := fouracc.HalfSpectrum(name, opts.ChunkSize, ys, freq)
+	if err != nil {
+		return nil, Output{}, err
+	}
+
+	var (
+		peaks = frf.Peaks(opts.Modes)
+		modes []fouracc.Mode
+		hdr   = "# peak"
+		cols  = make([][]float64, 1+4*len(fouracc.ModalMethods))
+	)
+	for _, m := range fouracc.ModalMethods {
+		hdr += fmt.Sprintf("\t%[1]v-freq\t%[1]v-freq-err\t%[1]v-damping\t%[1]v-damping-err", m)
+	}
+
+	log.Printf("modes [%s]: %d peak(s), %d average(s)", name, len(peaks), frf.Averages)
+	log.Printf("  %-10s  %-10s  %10s  %10s  %10s  %10s", "peak", "method", "freq", "±", "damping(%)", "±")
+	for _, p := range peaks {
+		cols[0] = append(cols[0], frf.Freqs[p])
+		for i, method := range fouracc.ModalMethods {
+			m, err := frf.Mode(p, method)
+			if err != nil {
+				log.Printf("  %-10.4g  %-10v  %v", frf.Freqs[p], method, err)
+				m = fouracc.Mode{Freq: math.NaN(), FreqErr: math.NaN(), Damping: math.NaN(), DampingErr: math.NaN()}
+			} else {
+				log.Printf(
+					"  %-10.4g  %-10v  %10.4g  %10.2g  %10.3g  %10.2g",
+					frf.Freqs[p], method, m.Freq, m.FreqErr, 100*m.Damping, 100*m.DampingErr,
+				)
+				if method == opts.ModalMethod {
+					modes = append(modes, m)
+				}
+			}
+			cols[1+4*i] = append(cols[1+4*i], m.Freq)
+			cols[2+4*i] = append(cols[2+4*i], m.FreqErr)
+			cols[3+4*i] = append(cols[3+4*i], m.Damping)
+			cols[4+4*i] = append(cols[4+4*i], m.DampingErr)
+		}
+	}
+
+	return modes, Output{Kind: KindModes, Axis: axis, Header: hdr, Cols: cols}, nil
+}
+
+func harmonics(axis string, spec fouracc.Spectrogram, opts Options) (Output, error) {
+	h := fouracc.HarmonicFamilies(spec, opts.HarmTol, opts.HarmMin)
+	log.Printf("harmonics [%s]: %d family(ies)", spec.Title(), len(h.Families))
+	for i, fam := range h.Families {
+		if i == maxIssues {
+			log.Printf("  ... and %d more", len(h.Families)-maxIssues)
+			break
+		}
+		log.Printf(
+			"  H%d: f0=%.4g [%.4g, %.4g], %d/%d chunk(s), orders up to %d",
+			fam.ID, fam.Mean, fam.Min, fam.Max, len(fam.Ts), h.Chunks, fam.MaxOrder(),
+		)
+	}
+
+	img, err := Render(height, func(dc draw.Canvas) error {
+		return fouracc.PlotHarmonics(dc, spec, h)
+	})
+	if err != nil {
+		return Output{}, fmt.Errorf("could not plot harmonic families: %w", err)
+	}
+
+	hdr, cols := HarmonicsTable(h)
+	return Output{Kind: KindHarmonics, Axis: axis, PNG: img, Header: hdr, Cols: cols}, nil
+}
+
+func envelope(name, axis string, opts Options, xs, ys []float64, freq float64) (Output, error) {
+	env := fouracc.EnvelopeSpectrum(name, xs, ys, freq, opts.EnvLo, opts.EnvHi)
+
+	img, err := Render(short, func(dc draw.Canvas) error {
+		return fouracc.PlotEnvelope(dc, env)
+	})
+	if err != nil {
+		return Output{}, fmt.Errorf("could not plot envelope: %w", err)
+	}
+
+	return Output{
+		Kind: KindEnvelope, Axis: axis, PNG: img,
+		Header: "# t\tsignal\tenvelope\tfreq\tspectrum",
+		Cols:   [][]float64{env.Ts, env.Signal, env.Env, env.Freqs, env.Spectrum},
+	}, nil
+}
+
+// octave runs the 1/b octave band analysis selected by opts, for all the
+// bands between the frequency resolution of a chunk and Nyquist.
+func octave(name string, opts Options, xs, ys []float64, freq float64) (fouracc.Octave, error) {
+	if freq <= 0 {
+		return fouracc.Octave{}, fmt.Errorf("octave analysis needs a sampling frequency")
+	}
+	bands, err := fouracc.OctaveBands(opts.Octave, freq/float64(opts.ChunkSize), freq/2)
+	if err != nil {
+		return fouracc.Octave{}, err
+	}
+	return fouracc.ChunkedOctave(name, opts.ChunkSize, xs, ys, freq, bands)
+}
+
+func bands(name, axis string, opts Options, xs, ys []float64, freq float64) (Output, error) {
+	oct, err := octave(name, opts, xs, ys, freq)
+	if err != nil {
+		return Output{}, err
+	}
+
+	img, err := Render(height, func(dc draw.Canvas) error {
+		return fouracc.PlotOctave(dc, oct)
+	})
+	if err != nil {
+		return Output{}, fmt.Errorf("could not plot octave analysis: %w", err)
+	}
+
+	hdr, cols := OctaveTable(oct)
+	return Output{Kind: KindOctave, Axis: axis, PNG: img, Header: hdr, Cols: cols}, nil
+}
+
+func cumulative(name, axis string, ys []float64, freq float64) (Output, error) {
+	cum := fouracc.CumulativeRMS(name, ys, freq)
+	log.Printf("cumrms [%s]: rms=%g", name, cum.RMS)
+	for i, lvl := range cum.Levels {
+		log.Printf(
+			"  %4g%%: low-to-high f=%.4g, high-to-low f=%.4g",
+			100*lvl, cum.UpFreqs[i], cum.DownFreqs[i],
+		)
+	}
+
+	img, err := Render(short, func(dc draw.Canvas) error {
+		return fouracc.PlotCumRMS(dc, cum)
+	})
+	if err != nil {
+		return Output{}, fmt.Errorf("could not plot cumulative RMS: %w", err)
+	}
+
+	return Output{
+		Kind: KindCumRMS, Axis: axis, PNG: img,
+		Header: "# freq\tpsd\tcum-up\tcum-down",
+		Cols:   [][]float64{cum.Freqs, cum.PSD, cum.Up, cum.Down},
+		CumRMS: &cum,
+	}, nil
+}
+
+func weighted(name, axis string, opts Options, xs, ys []float64, freq float64) (Output, error) {
+	wrms, err := fouracc.FrequencyWeighting(name, opts.Weighting, xs, ys, freq, opts.Tau)
+	if err != nil {
+		return Output{}, err
+	}
+	log.Printf(
+		"%v-weighted [%s]: rms=%g (spectral=%g), MTVV=%g, MTVV/rms=%.3g",
+		wrms.Weighting, name, wrms.RMS, wrms.SpectralRMS, wrms.MTVV, wrms.MTVV/wrms.RMS,
+	)
+
+	img, err := Render(short, func(dc draw.Canvas) error {
+		return fouracc.PlotWeighted(dc, wrms)
+	})
+	if err != nil {
+		return Output{}, fmt.Errorf("could not plot weighted acceleration: %w", err)
+	}
+
+	return Output{
+		Kind: KindWeighted, Axis: axis, PNG: img,
+		Header: "# t\tweighted\trunning-rms",
+		Cols:   [][]float64{wrms.Ts, wrms.Weighted, wrms.Running},
+	}, nil
+}
+
+func anomalies(axis string, base fouracc.Baseline, fft fouracc.FFT, opts Options) (Output, error) {
+	an, err := base.Score(fft, opts.ScoreUnit, opts.Threshold)
+	if err != nil {
+		return Output{}, err
+	}
+	log.Printf("anomalies [%s]: %d event(s)", fft.Name, len(an.Events))
+	for i, evt := range an.Events {
+		if i == maxIssues {
+			log.Printf("  ... and %d more", len(an.Events)-maxIssues)
+			break
+		}
+		log.Printf(
+			"  t=[%v, %v] f=[%.4g, %.4g] peak=%.3g%v cells=%d",
+			evt.T0, evt.T1, evt.F0, evt.F1, evt.Peak, an.Unit, evt.Cells,
+		)
+	}
+
+	img, err := Render(height, func(dc draw.Canvas) error {
+		return fouracc.PlotAnomaly(dc, an)
+	})
+	if err != nil {
+		return Output{}, fmt.Errorf("could not plot anomalies: %w", err)
+	}
+
+	hdr, cols := EventsTable(an)
+	return Output{Kind: KindAnomaly, Axis: axis, PNG: img, Header: hdr, Cols: cols}, nil
+}
+
+// srsFreqs returns the natural frequencies of the SRS, for a event window
+// of n samples.
+func srsFreqs(opts Options, n int, freq float64) []float64 {
+	scale := 1.0
+	if freq > 0 {
+		scale = freq
+	}
+	fmin, fmax := opts.SRSFmin, opts.SRSFmax
+	if fmin <= 0 {
+		fmin = scale / float64(n)
+	}
+	if fmax <= 0 {
+		fmax = scale / 4
+	}
+	return fouracc.OctaveFreqs(fmin, fmax, 12)
+}
+
+func shock(name, axis string, opts Options, ys []float64, freq float64) (Output, error) {
+	evt := fouracc.EventWindow(ys, freq, opts.SRST0, opts.SRST1)
+	srs, err := fouracc.ShockResponse(name, evt, freq, opts.SRSQ, srsFreqs(opts, len(evt), freq), opts.SRSKind)
+	if err != nil {
+		return Output{}, err
+	}
+
+	img, err := Render(short, func(dc draw.Canvas) error {
+		return fouracc.PlotSRS(dc, srs)
+	})
+	if err != nil {
+		return Output{}, fmt.Errorf("could not plot SRS: %w", err)
+	}
+
+	return Output{
+		Kind: KindSRS, Axis: axis, PNG: img,
+		Header: "# freq\tmaximax\tprimary+\tprimary-\tresidual+\tresidual-",
+		Cols:   [][]float64{srs.Freqs, srs.Maximax, srs.PrimaryPos, srs.PrimaryNeg, srs.ResidualPos, srs.ResidualNeg},
+	}, nil
+}
+
+// criteria evaluates the vibration criteria selected by opts.
+// Compliance with the target curve is reported by the VC field of the
+// output.
+func criteria(name, axis string, opts Options, xs, ys []float64, freq float64) (Output, error) {
+	vc, err := fouracc.VibrationCriteria(name, opts.ChunkSize, xs, ys, freq, opts.AccScale, opts.VCTarget)
+	if err != nil {
+		return Output{}, err
+	}
+
+	for i, class := range vc.Class {
+		var bands []string
+		for _, j := range vc.Exceeding[i] {
+			bands = append(bands, fmt.Sprintf("%.3g", vc.Bands.Centers[j]))
+		}
+		msg := "ok"
+		if len(bands) > 0 {
+			msg = "exceeding bands (Hz): " + strings.Join(bands, ", ")
+		}
+		log.Printf("vc [%s] t=%v: %v (%s)", name, vc.Ts[i], class, msg)
+	}
+
+	img, err := Render(short, func(dc draw.Canvas) error {
+		return fouracc.PlotVC(dc, vc)
+	})
+	if err != nil {
+		return Output{}, fmt.Errorf("could not plot vibration criteria: %w", err)
+	}
+
+	hdr, cols := VCTable(vc)
+	return Output{Kind: KindVC, Axis: axis, PNG: img, Header: hdr, Cols: cols, VC: &vc}, nil
+}
@@ -0,0 +1,306 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/lsst-lpc/fouracc"
+	"github.com/lsst-lpc/fouracc/msr"
+)
+
+// IsMSR returns whether the provided file header is the one of a MSR file.
+func IsMSR(head []byte) bool {
+	return strings.HasPrefix(string(head), "*CREATOR")
+}
+
+// ReadMSR parses the named MSR stream, rejecting files that are not MSR
+// files.
+func ReadMSR(fname string, r io.Reader, opts ...msr.Option) (msr.File, error) {
+	f, err := msr.Parse(r, opts...)
+	if err != nil {
+		return f, fmt.Errorf("could not parse MSR file %q: %w", fname, err)
+	}
+	if len(f.Cols) == 0 {
+		return f, fmt.Errorf("%q is not a MSR file", fname)
+	}
+	return f, nil
+}
+
+// MergedName returns the name of the data set read from the named files:
+// the base name of a single file, or the base names of several files
+// without their extension, joined by '+' and followed by the extension of
+// the first one (e.g. "a+b.csv").
+func MergedName(fnames []string) string {
+	if len(fnames) == 1 {
+		return filepath.Base(fnames[0])
+	}
+	names := make([]string, len(fnames))
+	for i, fname := range fnames {
+		base := filepath.Base(fname)
+		names[i] = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	return strings.Join(names, "+") + filepath.Ext(fnames[0])
+}
+
+// Series is a named time series of a data file.
+type Series struct {
+	Name string
+	Xs   []float64
+	Ys   []float64
+}
+
+// ReadSeries reads the channels of a MSR file selected by sel (see
+// SelectChannels), parsed with the provided MSR options, or the time series
+// of a plain CSV file.
+// The sampling frequency is the nominal rate of MSR files (see
+// msr.File.Rate), and is negative for plain CSV files without time column.
+func ReadSeries(r io.ReadSeeker, sel string, mopts []msr.Option, lopts ...fouracc.LoadOption) ([]Series, float64, error) {
+	var head [64]byte
+	_, err := io.ReadFull(r, head[:])
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not read CSV header: %w", err)
+	}
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not rewind CSV file: %w", err)
+	}
+
+	if !IsMSR(head[:]) {
+		data, err := fouracc.LoadSeries(r, lopts...)
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not load CSV file: %w", err)
+		}
+		return []Series{{"", data.Xs, data.Ys}}, data.Freq, nil
+	}
+
+	f, err := msr.Parse(r, mopts...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not parse MSR file: %w", err)
+	}
+	rate, err := f.Rate()
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not estimate sampling rate: %w", err)
+	}
+	names, err := SelectChannels(f, sel)
+	if err != nil {
+		return nil, 0, err
+	}
+	var (
+		xs  = f.Axis()
+		set = make([]Series, len(names))
+	)
+	for i, name := range names {
+		ys, _, err := f.Channel(name)
+		if err != nil {
+			return nil, 0, err
+		}
+		set[i] = Series{AxisName(name), xs, ys}
+	}
+	return set, rate.Nominal, nil
+}
+
+// Baselines builds the reference spectra of each axis of the provided
+// reference data sets.
+func Baselines(refs [][]Series, freqs []float64, chunksz int, kind fouracc.BaselineKind, pct float64) (map[string]fouracc.Baseline, error) {
+	ffts := make(map[string][]fouracc.FFT)
+	for i, ref := range refs {
+		for _, s := range ref {
+			ffts[s.Name] = append(ffts[s.Name], fouracc.ChunkedFFT(s.Name, chunksz, s.Xs, s.Ys, freqs[i]))
+		}
+	}
+	out := make(map[string]fouracc.Baseline, len(ffts))
+	for name, vs := range ffts {
+		base, err := fouracc.NewBaseline(vs, kind, pct)
+		if err != nil {
+			return nil, fmt.Errorf("could not build baseline of axis %q: %w", name, err)
+		}
+		out[name] = base
+	}
+	return out, nil
+}
+
+// AccUnit returns the unit of the acceleration channels of the MSR file.
+func AccUnit(f msr.File) string {
+	for _, col := range f.Channels() {
+		if IsAcc(col.Name) {
+			return col.Unit
+		}
+	}
+	return ""
+}
+
+// SelectChannels returns the names of the MSR channels selected by the
+// comma-separated list sel: the acceleration channels if sel is empty (or
+// all the data channels if there is none), or all the data channels for
+// "all".
+func SelectChannels(f msr.File, sel string) ([]string, error) {
+	var names []string
+	switch sel = strings.TrimSpace(sel); sel {
+	case "", "all":
+		for _, col := range f.Channels() {
+			if sel == "" && !IsAcc(col.Name) {
+				continue
+			}
+			names = append(names, col.Name)
+		}
+		if len(names) == 0 && sel == "" {
+			return SelectChannels(f, "all")
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no data channel in MSR file")
+		}
+		return names, nil
+	}
+
+	for _, name := range strings.Split(sel, ",") {
+		_, col, err := f.Channel(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, col.Name)
+	}
+	return names, nil
+}
+
+// IsAcc returns whether the named MSR channel is an acceleration channel,
+// e.g. "ACC x".
+func IsAcc(name string) bool {
+	return len(name) > 4 && strings.EqualFold(name[:4], "ACC ")
+}
+
+// AxisName returns the short name of a MSR channel, naming its outputs:
+// the axis of acceleration channels (e.g. "x" for "ACC x"), or the
+// lower-cased channel name with runs of non-alphanumeric characters
+// replaced by '-' (e.g. "rel-humidity" for "Rel. Humidity").
+func AxisName(name string) string {
+	if IsAcc(name) {
+		name = name[4:]
+	}
+	var (
+		o   strings.Builder
+		sep = false
+	)
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			sep = o.Len() > 0
+			continue
+		}
+		if sep {
+			o.WriteByte('-')
+			sep = false
+		}
+		o.WriteRune(r)
+	}
+	return o.String()
+}
+
+// AccScale returns the conversion factor to m/s² of the acceleration
+// data, from the user provided unit or else from the file unit.
+// Data are assumed to be expressed in g when no unit is known.
+func AccScale(user, file string) (float64, error) {
+	unit := user
+	if unit == "" {
+		unit = file
+	}
+	if unit == "" {
+		unit = "g"
+	}
+	v, err := fouracc.AccScale(unit)
+	if err != nil {
+		return 0, fmt.Errorf("could not infer acceleration scale: %w", err)
+	}
+	return v, nil
+}
+
+// ParseBand parses a frequency band of the form "lo:hi".
+// An empty hi selects all frequencies up to Nyquist.
+func ParseBand(v string) (lo, hi float64, err error) {
+	i := strings.Index(v, ":")
+	if i < 0 {
+		return 0, 0, fmt.Errorf("invalid band %q (want lo:hi)", v)
+	}
+	if s := strings.TrimSpace(v[:i]); s != "" {
+		lo, err = strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("could not parse band low edge %q: %w", s, err)
+		}
+	}
+	if s := strings.TrimSpace(v[i+1:]); s != "" {
+		hi, err = strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("could not parse band high edge %q: %w", s, err)
+		}
+	}
+	if hi > 0 && hi <= lo {
+		return 0, 0, fmt.Errorf("invalid band %q (hi <= lo)", v)
+	}
+	return lo, hi, nil
+}
+
+// Range returns the [beg,end) range of the data to analyze, out of n
+// samples.
+// An end of -1 selects all the samples after beg.
+func Range(n, beg, end int) (int, int, error) {
+	if end == -1 {
+		end = n
+	}
+	switch {
+	case end > n:
+		return beg, end, fmt.Errorf("invalid data range (end=%d > len=%d)", end, n)
+	case beg > end:
+		return beg, end, fmt.Errorf("invalid data range (beg=%d > end=%d)", beg, end)
+	case beg > n:
+		return beg, end, fmt.Errorf("invalid data range (beg=%d > len=%d)", end, n)
+	}
+	return beg, end, nil
+}
+
+// LoadOptions returns the load options selecting the amplitude and time
+// columns of plain CSV files.
+// An empty tcol selects the time column automatically, "none" disables it.
+func LoadOptions(col, tcol string) []fouracc.LoadOption {
+	var lopts []fouracc.LoadOption
+	if col != "" {
+		lopts = append(lopts, fouracc.WithColumn(col))
+	}
+	switch tcol {
+	case "":
+		// automatic.
+	case "none":
+		lopts = append(lopts, fouracc.WithTimeColumn(""))
+	default:
+		lopts = append(lopts, fouracc.WithTimeColumn(tcol))
+	}
+	return lopts
+}
+
+// Dialect returns the CSV dialect with the provided field and decimal
+// separators.
+// An empty decimal separator selects the default one.
+func Dialect(sep, decimal string) (fouracc.Dialect, error) {
+	var (
+		d   = fouracc.DefaultDialect
+		err error
+	)
+	d.Comma, err = fouracc.ParseComma(sep)
+	if err != nil {
+		return d, err
+	}
+	switch decimal {
+	case "":
+		// default.
+	case ".", ",":
+		d.Decimal = rune(decimal[0])
+	default:
+		return d, fmt.Errorf("invalid decimal separator %q", decimal)
+	}
+	return d, nil
+}
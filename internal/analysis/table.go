@@ -0,0 +1,161 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/lsst-lpc/fouracc"
+	"go-hep.org/x/hep/csvutil"
+)
+
+// SpectrogramTable returns the columns of the table of a time-frequency
+// analysis: one row per chunk, with the coefficient of each frequency.
+// Multitaper analyses are tabulated with MultitaperTable.
+func SpectrogramTable(spec fouracc.Spectrogram) (string, [][]float64) {
+	if mtm, ok := spec.(fouracc.Multitaper); ok {
+		return MultitaperTable(mtm)
+	}
+	c, r := spec.Dims()
+	cols := make([][]float64, r)
+	for j := range cols {
+		cols[j] = make([]float64, c)
+		for i := range cols[j] {
+			cols[j][i] = spec.Z(i, j)
+		}
+	}
+	return "", cols
+}
+
+// MultitaperTable returns the header and columns of the table of a
+// multitaper analysis: one row per chunk and frequency, with the power
+// spectral density and the F-test statistic and p-value of a line component.
+func MultitaperTable(mtm fouracc.Multitaper) (string, [][]float64) {
+	cols := make([][]float64, 5)
+	for i, t := range mtm.Ts {
+		for j, f := range mtm.Freqs {
+			cols[0] = append(cols[0], t)
+			cols[1] = append(cols[1], f)
+			cols[2] = append(cols[2], mtm.Coeffs[i][j])
+			cols[3] = append(cols[3], mtm.FTest[i][j])
+			cols[4] = append(cols[4], mtm.PValue[i][j])
+		}
+	}
+	return "# t\tfreq\tpsd\tf-test\tp-value", cols
+}
+
+// OctaveTable returns the header and columns of the table of a 1/b octave
+// band analysis: one row per band, with the band RMS over the whole time
+// series followed by the band RMS of each chunk.
+func OctaveTable(oct fouracc.Octave) (string, [][]float64) {
+	var (
+		hdr  = "# center\tlo\thi\trms"
+		cols = [][]float64{oct.Bands.Centers, oct.Bands.Lo, oct.Bands.Hi, oct.RMS}
+	)
+	for i, t := range oct.Ts {
+		hdr += fmt.Sprintf("\tt=%v", t)
+		col := make([]float64, len(oct.Coeffs[i]))
+		copy(col, oct.Coeffs[i])
+		cols = append(cols, col)
+	}
+	return hdr, cols
+}
+
+// VCTable returns the header and columns of the table of a vibration
+// criteria evaluation: one row per 1/3 octave band, with the target limit,
+// the maximum velocity and the velocity of each chunk.
+func VCTable(vc fouracc.VC) (string, [][]float64) {
+	var (
+		hdr   = "# center\tlimit\tmax"
+		limit = make([]float64, len(vc.Bands.Centers))
+		cols  = [][]float64{vc.Bands.Centers, limit, vc.Max}
+	)
+	for i, f := range vc.Bands.Centers {
+		limit[i] = vc.Target.Limit(f)
+	}
+	for i, t := range vc.Ts {
+		hdr += fmt.Sprintf("\tt=%v", t)
+		cols = append(cols, vc.Velocity[i])
+	}
+	return hdr, cols
+}
+
+// HarmonicsTable returns the header and columns of the table of the
+// harmonic families: one row per family and detection.
+func HarmonicsTable(h fouracc.Harmonics) (string, [][]float64) {
+	cols := make([][]float64, 4)
+	for _, fam := range h.Families {
+		for i, t := range fam.Ts {
+			cols[0] = append(cols[0], float64(fam.ID))
+			cols[1] = append(cols[1], t)
+			cols[2] = append(cols[2], fam.F0[i])
+			cols[3] = append(cols[3], float64(len(fam.Orders[i])))
+		}
+	}
+	return "# family\tt\tf0\tpeaks", cols
+}
+
+// EventsTable returns the header and columns of the list of anomalous events.
+func EventsTable(an fouracc.Anomaly) (string, [][]float64) {
+	cols := make([][]float64, 6)
+	for _, evt := range an.Events {
+		cols[0] = append(cols[0], evt.T0)
+		cols[1] = append(cols[1], evt.T1)
+		cols[2] = append(cols[2], evt.F0)
+		cols[3] = append(cols[3], evt.F1)
+		cols[4] = append(cols[4], evt.Peak)
+		cols[5] = append(cols[5], float64(evt.Cells))
+	}
+	return "# t0\tt1\tf0\tf1\tpeak(" + an.Unit.String() + ")\tcells", cols
+}
+
+// WriteCSV writes the provided columns as a tab-separated table to the
+// named file.
+// Columns shorter than the longest one are padded with empty cells.
+func WriteCSV(oname, hdr string, cols ...[]float64) error {
+	tbl, err := csvutil.Create(oname)
+	if err != nil {
+		return fmt.Errorf("could not create output data file %q: %w", oname, err)
+	}
+	defer tbl.Close()
+
+	tbl.Writer.Comma = '\t'
+
+	if hdr != "" {
+		err = tbl.WriteHeader(hdr)
+		if err != nil {
+			return fmt.Errorf("could not write header of output data file %q: %w", oname, err)
+		}
+	}
+
+	n := 0
+	for _, col := range cols {
+		if len(col) > n {
+			n = len(col)
+		}
+	}
+
+	args := make([]interface{}, len(cols))
+	for i := 0; i < n; i++ {
+		for j, col := range cols {
+			switch {
+			case i < len(col):
+				args[j] = col[i]
+			default:
+				args[j] = ""
+			}
+		}
+		err = tbl.WriteRow(args...)
+		if err != nil {
+			return fmt.Errorf("could not write row %d of output data file %q: %w", i, oname, err)
+		}
+	}
+
+	err = tbl.Close()
+	if err != nil {
+		return fmt.Errorf("could not close output data file %q: %w", oname, err)
+	}
+	return nil
+}
@@ -6,44 +6,296 @@ package fouracc
 
 import (
 	"bufio"
-	"encoding/csv"
 	"fmt"
 	"io"
-
-	"go-hep.org/x/hep/csvutil"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Series is a time series read from a CSV file.
+type Series struct {
+	Xs []float64 // time (s) of the samples, or their row index without time column
+	Ys []float64 // amplitudes; empty cells are NaN
+
+	Column string    // name of the amplitude column, if the file has a header
+	Time   string    // name of the time column, if any
+	Start  time.Time // timestamp of the first sample, for RFC3339 time columns
+	Freq   float64   // sampling frequency (Hz) inferred from the time column, or -1
+
+	Dialect Dialect // dialect of the CSV file
+}
+
+// LoadOption configures how LoadSeries reads a CSV file.
+type LoadOption func(cfg *loadConfig)
+
+type loadConfig struct {
+	col     string // amplitude column
+	time    string // time column
+	timeSet bool   // whether the time column was explicitly selected
+
+	dialect *Dialect // dialect of the file (nil to sniff it)
+}
+
+// WithDialect sets the dialect of the CSV file.
+// By default, the dialect is sniffed from the first kilobytes of the file.
+func WithDialect(d Dialect) LoadOption {
+	return func(cfg *loadConfig) {
+		cfg.dialect = &d
+	}
+}
+
+// WithColumn selects the amplitude column, by header name or by 0-based index.
+// By default, the first column which is not the time column is used.
+func WithColumn(col string) LoadOption {
+	return func(cfg *loadConfig) {
+		cfg.col = col
+	}
+}
+
+// WithTimeColumn selects the time column, by header name or by 0-based index.
+// An empty col disables the time column: xs are then the row indices.
+//
+// By default, the time column is the one named t, time*, timestamp, date or
+// datetime in the header or, for files without header and with 2 or more
+// columns, the first column.
+func WithTimeColumn(col string) LoadOption {
+	return func(cfg *loadConfig) {
+		cfg.time = col
+		cfg.timeSet = true
+	}
+}
+
 // Load reads data from the provided io.Reader.
-// Load expects 1 or 2 columns of the form ([time series], amplitudes).
+// Load expects 1 or 2 columns of the form ([time series], amplitudes), and
+// returns the times (or the row indices) and the amplitudes of the samples.
+//
+// Load is LoadSeries with the default options.
 func Load(r io.Reader) (xs, ys []float64, err error) {
-	tbl := &csvutil.Table{
-		Reader: csv.NewReader(bufio.NewReader(r)),
+	data, err := LoadSeries(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data.Xs, data.Ys, nil
+}
+
+// LoadSeries reads a time series from the provided io.Reader.
+//
+// LoadSeries expects a CSV file of 1 or more columns, with optional header
+// rows, the last one naming the columns, of the form
+// ([time series], amplitudes, ...).
+// Times are expressed in seconds or as RFC3339 timestamps, converted to
+// seconds since the first sample.
+// The sampling frequency is inferred from the median interval between
+// timestamps.
+func LoadSeries(r io.Reader, opts ...LoadOption) (Series, error) {
+	var cfg loadConfig
+	for _, opt := range opts {
+		opt(&cfg)
 	}
-	defer tbl.Close()
 
-	rows, err := tbl.ReadRows(0, -1)
-	if err != nil {
-		return nil, nil, fmt.Errorf("fouracc: could not read rows: %w", err)
+	br := bufio.NewReaderSize(r, sniffSize)
+	d := DefaultDialect
+	switch cfg.dialect {
+	case nil:
+		sample, err := br.Peek(sniffSize)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return Series{}, fmt.Errorf("fouracc: could not sniff CSV dialect: %w", err)
+		}
+		d = SniffDialect(sample)
+	default:
+		d = *cfg.dialect
+		if d.Comma == 0 {
+			d.Comma = DefaultDialect.Comma
+		}
+		if d.Decimal == 0 {
+			d.Decimal = DefaultDialect.Decimal
+		}
+	}
+	if d.Decimal != '.' && d.Decimal != ',' {
+		return Series{}, fmt.Errorf("fouracc: invalid decimal separator %q", d.Decimal)
+	}
+	if d.Decimal == d.Comma {
+		return Series{}, fmt.Errorf("fouracc: decimal separator and field separator are both %q", d.Comma)
 	}
-	defer rows.Close()
 
-	id := 0
-	for rows.Next() {
-		var v float64
-		err = rows.Scan(&v)
+	var (
+		out   = Series{Freq: -1, Dialect: d}
+		hdr   []string
+		ycol  = -1
+		tcol  = -1
+		rfc   = false // whether timestamps are RFC3339 timestamps
+		t0    time.Time
+		row   = 0
+		skip  = d.Header // remaining header rows
+		first = true
+	)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return Series{}, fmt.Errorf("fouracc: could not read row %d: %w", row, err)
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+		row++
+
+		s := strings.TrimSpace(line)
+		switch {
+		case d.Comment != "" && strings.HasPrefix(s, d.Comment):
+			continue
+		case s == "" && (d.SkipBlank || first):
+			continue
+		}
+		rec := d.split(line)
+
+		if skip > 0 {
+			skip--
+			if skip == 0 {
+				hdr = rec
+			}
+			continue
+		}
+
+		if first {
+			first = false
+			if d.Header < 0 && d.isHeader(rec) {
+				hdr = rec
+			}
+			tcol, ycol, err = cfg.columns(hdr, len(rec))
+			if err != nil {
+				return Series{}, err
+			}
+			if hdr != nil {
+				if ycol < len(hdr) {
+					out.Column = hdr[ycol]
+				}
+				if tcol >= 0 && tcol < len(hdr) {
+					out.Time = hdr[tcol]
+				}
+				if d.Header < 0 {
+					continue
+				}
+			}
+		}
+
+		if ycol >= len(rec) || tcol >= len(rec) {
+			return Series{}, fmt.Errorf("fouracc: missing column in row %d", row)
+		}
+
+		y := math.NaN()
+		if v := rec[ycol]; v != "" {
+			y, err = d.number(v)
+			if err != nil {
+				return Series{}, fmt.Errorf("fouracc: could not parse amplitude in row %d: %w", row, err)
+			}
+		}
+
+		x := float64(len(out.Ys))
+		if tcol >= 0 {
+			v := rec[tcol]
+			if len(out.Ys) == 0 {
+				_, err := d.number(v)
+				rfc = err != nil
+			}
+			switch {
+			case rfc:
+				t, err := time.Parse(time.RFC3339Nano, v)
+				if err != nil {
+					return Series{}, fmt.Errorf("fouracc: could not parse timestamp in row %d: %w", row, err)
+				}
+				if len(out.Ys) == 0 {
+					t0 = t
+					out.Start = t
+				}
+				x = t.Sub(t0).Seconds()
+			default:
+				x, err = d.number(v)
+				if err != nil {
+					return Series{}, fmt.Errorf("fouracc: could not parse time in row %d: %w", row, err)
+				}
+			}
+		}
+
+		out.Xs = append(out.Xs, x)
+		out.Ys = append(out.Ys, y)
+	}
+
+	if tcol >= 0 && len(out.Xs) > 1 {
+		dt := medianStep(out.Xs)
+		if !(dt > 0) {
+			return Series{}, fmt.Errorf("fouracc: time column %q is not increasing", out.Time)
+		}
+		out.Freq = 1 / dt
+	}
+
+	return out, nil
+}
+
+// columns returns the indices of the time and amplitude columns of a file
+// with the provided header (nil if none) and number of columns.
+// The index of the time column is -1 if there is none.
+func (cfg loadConfig) columns(hdr []string, n int) (tcol, ycol int, err error) {
+	tcol = -1
+	switch {
+	case cfg.timeSet:
+		if cfg.time != "" {
+			tcol, err = column(hdr, n, cfg.time)
+			if err != nil {
+				return -1, -1, fmt.Errorf("fouracc: invalid time column: %w", err)
+			}
+		}
+	case hdr != nil:
+		for i, name := range hdr {
+			if isTimeName(name) {
+				tcol = i
+				break
+			}
+		}
+	case n > 1:
+		tcol = 0
+	}
+
+	if cfg.col != "" {
+		ycol, err = column(hdr, n, cfg.col)
 		if err != nil {
-			return nil, nil, fmt.Errorf("fouracc: could not scan row %d: %w", id, err)
+			return -1, -1, fmt.Errorf("fouracc: invalid amplitude column: %w", err)
 		}
-		xs = append(xs, float64(id))
-		ys = append(ys, v)
-		id++
+		return tcol, ycol, nil
 	}
 
-	if err := rows.Err(); err != nil {
-		if err != io.EOF {
-			return nil, nil, fmt.Errorf("fouracc: error while processing rows: %w", err)
+	for i := 0; i < n; i++ {
+		if i != tcol {
+			return tcol, i, nil
 		}
 	}
+	return -1, -1, fmt.Errorf("fouracc: no amplitude column")
+}
 
-	return xs, ys, err
+// column returns the index of the column named or indexed by col.
+func column(hdr []string, n int, col string) (int, error) {
+	for i, name := range hdr {
+		if name == col {
+			return i, nil
+		}
+	}
+	i, err := strconv.Atoi(col)
+	if err != nil {
+		return -1, fmt.Errorf("no column named %q", col)
+	}
+	if i < 0 || i >= n {
+		return -1, fmt.Errorf("column index %d out of range [0, %d)", i, n)
+	}
+	return i, nil
+}
+
+// isTimeName returns whether the column name denotes a time column.
+func isTimeName(name string) bool {
+	name = strings.ToLower(name)
+	switch name {
+	case "t", "timestamp", "date", "datetime":
+		return true
+	}
+	return strings.HasPrefix(name, "time")
 }
@@ -0,0 +1,40 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		data   string
+		xs, ys []float64
+	}{
+		{"amplitudes", "1.5\n2\n-3\n", []float64{0, 1, 2}, []float64{1.5, 2, -3}},
+		{"times", "0,1.5\n0.5,2\n1,-3\n", []float64{0, 0.5, 1}, []float64{1.5, 2, -3}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			xs, ys, err := Load(strings.NewReader(tc.data))
+			if err != nil {
+				t.Fatalf("could not load data: %+v", err)
+			}
+			if !reflect.DeepEqual(xs, tc.xs) {
+				t.Fatalf("invalid xs: got=%v, want=%v", xs, tc.xs)
+			}
+			if !reflect.DeepEqual(ys, tc.ys) {
+				t.Fatalf("invalid ys: got=%v, want=%v", ys, tc.ys)
+			}
+		})
+	}
+
+	_, _, err := Load(strings.NewReader("1\nabc\n"))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Periodogram holds the result of a chunked Lomb-Scargle analysis.
+type Periodogram struct {
+	Data struct {
+		X []float64
+		Y []float64
+	}
+	Ts     []float64   // chunked time series
+	Freqs  []float64   // frequencies (Hz)
+	Coeffs [][]float64 // normalized periodogram power, in [0,1]
+
+	Name   string
+	Chunks int
+	TScale float64 // number of time units per second
+}
+
+// ChunkedLombScargle computes the generalised Lomb-Scargle periodogram of
+// the (ts, ys) series, by chunks of chunksz samples.
+// Samples do not need to be evenly spaced.
+//
+// tscale is the number of time units of ts per second, e.g. 1000 for
+// the timestamps returned by msr.File.TimeSeries.
+// If tscale is not positive, frequencies are expressed in units of 1/ts.
+//
+// Periodograms are evaluated on a common grid of chunksz/2 frequencies, with
+// a resolution of 1/T and up to the pseudo-Nyquist frequency 1/(2δt), where
+// δt is the median sampling interval and T=chunksz*δt.
+// A trailing chunk with less than 2 samples is discarded.
+func ChunkedLombScargle(fname string, chunksz int, ts, ys []float64, tscale float64) Periodogram {
+	if tscale <= 0 {
+		tscale = 1
+	}
+	if chunksz > len(ys) {
+		chunksz = len(ys)
+	}
+
+	pgram := Periodogram{
+		Name:   fname,
+		Chunks: chunksz,
+		TScale: tscale,
+	}
+	pgram.Data.X = ts
+	pgram.Data.Y = ys
+
+	secs := make([]float64, len(ts))
+	for i, t := range ts {
+		secs[i] = t / tscale
+	}
+
+	dt := medianStep(secs)
+	if chunksz < 2 || dt <= 0 {
+		return pgram
+	}
+
+	var (
+		nf = chunksz / 2
+		df = 1 / (float64(chunksz) * dt)
+	)
+	pgram.Freqs = make([]float64, nf)
+	for i := range pgram.Freqs {
+		pgram.Freqs[i] = float64(i+1) * df
+	}
+
+	for i := 0; i < len(ys); i += chunksz {
+		end := i + chunksz
+		if end > len(ys) {
+			end = len(ys)
+		}
+		if end-i < 2 {
+			break
+		}
+		pgram.Ts = append(pgram.Ts, ts[i])
+		pgram.Coeffs = append(pgram.Coeffs, LombScargle(secs[i:end], ys[i:end], pgram.Freqs))
+	}
+
+	return pgram
+}
+
+// LombScargle returns the generalised (floating-mean) Lomb-Scargle
+// periodogram of the (ts, ys) series, evaluated at the provided frequencies.
+// Frequencies are expressed in units of 1/ts.
+//
+// The returned power is normalized to [0,1], following M. Zechmeister and
+// M. Kürster, "The generalised Lomb-Scargle periodogram", A&A 496, 577 (2009).
+func LombScargle(ts, ys, freqs []float64) []float64 {
+	var (
+		n   = float64(len(ys))
+		out = make([]float64, len(freqs))
+		ym  = 0.0
+		yy  = 0.0
+	)
+	for _, y := range ys {
+		ym += y
+	}
+	ym /= n
+	for _, y := range ys {
+		yy += (y - ym) * (y - ym)
+	}
+	yy /= n
+
+	for j, f := range freqs {
+		var (
+			w          = 2 * math.Pi * f
+			c, s       float64
+			ycos, ysin float64
+			cc, ss, cs float64
+		)
+		for i, t := range ts {
+			sin, cos := math.Sincos(w * t)
+			y := ys[i] - ym
+			c += cos
+			s += sin
+			ycos += y * cos
+			ysin += y * sin
+			cc += cos * cos
+			ss += sin * sin
+			cs += cos * sin
+		}
+		c /= n
+		s /= n
+		ycos /= n
+		ysin /= n
+		cc = cc/n - c*c
+		ss = ss/n - s*s
+		cs = cs/n - c*s
+
+		d := cc*ss - cs*cs
+		if d <= 0 || yy == 0 {
+			out[j] = math.NaN()
+			continue
+		}
+		out[j] = (ss*ycos*ycos + cc*ysin*ysin - 2*cs*ycos*ysin) / (yy * d)
+	}
+	return out
+}
+
+// medianStep returns the median interval between consecutive values of xs.
+func medianStep(xs []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+	dxs := make([]float64, len(xs)-1)
+	for i := range dxs {
+		dxs[i] = xs[i+1] - xs[i]
+	}
+	sort.Float64s(dxs)
+	return dxs[len(dxs)/2]
+}
+
+func (pgram Periodogram) Dims() (c, r int) {
+	if len(pgram.Coeffs) == 0 {
+		return 0, 0
+	}
+	return len(pgram.Coeffs), len(pgram.Coeffs[0])
+}
+
+func (pgram Periodogram) Z(c, r int) float64 { return pgram.Coeffs[c][r] }
+func (pgram Periodogram) X(c int) float64    { return pgram.Ts[c] }
+func (pgram Periodogram) Y(r int) float64    { return pgram.Freqs[r] }
+
+func (pgram Periodogram) Title() string {
+	return fmt.Sprintf("%s -- lomb-scargle chunks=%d", pgram.Name, pgram.Chunks)
+}
+
+func (pgram Periodogram) Series() (xs, ys []float64) { return pgram.Data.X, pgram.Data.Y }
@@ -0,0 +1,110 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+func TestLombScargle(t *testing.T) {
+	for _, tc := range []struct {
+		f0, amp, phase, offset float64
+	}{
+		{f0: 1, amp: 1},
+		{f0: 2.5, amp: 3, phase: 0.3, offset: 10},
+		{f0: 7.25, amp: 0.1, phase: -1.2, offset: -4},
+	} {
+		var (
+			rnd = rand.New(rand.NewSource(1234))
+			ts  = make([]float64, 200)
+			ys  = make([]float64, len(ts))
+			t0  = 0.0
+		)
+		for i := range ts {
+			// irregular sampling, of 50 Hz on average.
+			t0 += 0.01 + 0.02*rnd.Float64()
+			ts[i] = t0
+			ys[i] = tc.offset + tc.amp*math.Sin(2*math.Pi*tc.f0*t0+tc.phase)
+		}
+
+		// a noise-free sinusoid is perfectly fitted at its frequency.
+		pow := LombScargle(ts, ys, []float64{tc.f0, 1.5 * tc.f0})
+		if got, want := pow[0], 1.0; math.Abs(got-want) > 1e-9 {
+			t.Fatalf("f0=%v: invalid power at f0: got=%v, want=%v", tc.f0, got, want)
+		}
+		if got := pow[1]; !(got >= 0 && got < 0.5) {
+			t.Fatalf("f0=%v: invalid power at 1.5*f0: got=%v", tc.f0, got)
+		}
+	}
+}
+
+func TestLombScargleConstant(t *testing.T) {
+	pow := LombScargle([]float64{0, 1, 2, 3}, []float64{2, 2, 2, 2}, []float64{0.25})
+	if !math.IsNaN(pow[0]) {
+		t.Fatalf("invalid power of a constant series: got=%v, want=NaN", pow[0])
+	}
+}
+
+func TestChunkedLombScargleGaps(t *testing.T) {
+	const (
+		freq = 100.0 // Hz
+		f0   = 12.5  // Hz
+	)
+	for _, tc := range []struct {
+		name    string
+		chunksz int
+		drop    func(i int) bool
+	}{
+		{"regular", 256, func(i int) bool { return false }},
+		{"gap", 256, func(i int) bool { return 300 <= i && i < 400 }},
+		{"dropouts", 200, func(i int) bool { return i%7 == 3 }},
+	} {
+		var ts, ys []float64
+		for i := 0; i < 1024; i++ {
+			if tc.drop(i) {
+				continue
+			}
+			t := float64(i) / freq
+			ts = append(ts, 1000*t) // ms
+			ys = append(ys, math.Sin(2*math.Pi*f0*t))
+		}
+
+		pgram := ChunkedLombScargle(tc.name, tc.chunksz, ts, ys, 1000)
+		if got, want := pgram.Freqs[len(pgram.Freqs)-1], freq/2; math.Abs(got-want) > 1e-9 {
+			t.Fatalf("%s: invalid highest frequency: got=%v, want=%v", tc.name, got, want)
+		}
+		df := pgram.Freqs[0]
+		for c, pow := range pgram.Coeffs {
+			if got := pgram.Freqs[floats.MaxIdx(pow)]; math.Abs(got-f0) > df {
+				t.Fatalf("%s: invalid peak of chunk %d: got=%v Hz, want=%v Hz", tc.name, c, got, f0)
+			}
+		}
+	}
+}
+
+func TestChunkedLombScargleEmpty(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		chunksz int
+		ts, ys  []float64
+	}{
+		{"no-sample", 16, nil, nil},
+		{"one-sample", 16, []float64{0}, []float64{1}},
+		{"one-sample-chunks", 1, []float64{0, 1, 2, 3}, []float64{1, 2, 3, 4}},
+		{"same-time", 16, []float64{5, 5, 5, 5}, []float64{1, 2, 3, 4}},
+		{"backwards", 16, []float64{3, 2, 1, 0}, []float64{1, 2, 3, 4}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pgram := ChunkedLombScargle(tc.name, tc.chunksz, tc.ts, tc.ys, 1000)
+			if c, r := pgram.Dims(); c != 0 || r != 0 {
+				t.Fatalf("invalid dims: got=(%d, %d), want=(0, 0)", c, r)
+			}
+		})
+	}
+}
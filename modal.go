@@ -0,0 +1,441 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sort"
+	"strings"
+
+	"gonum.org/v1/gonum/dsp/fourier"
+	"gonum.org/v1/gonum/mat"
+)
+
+// FRF is a complex frequency response around which modal parameters
+// are extracted: a transfer function, or the positive power spectrum of
+// a response-only measurement (see HalfSpectrum).
+type FRF struct {
+	Freqs []float64    // frequencies of the bins
+	H     []complex128 // complex response, per bin
+
+	Averages int // number of averaged spectra (0 for an exact response)
+
+	Name  string
+	Scale float64 // Frequency scale
+}
+
+// HalfSpectrum returns the positive power spectrum of ys, sampled at freq
+// (Hz), i.e. the Fourier transform of the positive lags of its
+// autocorrelation.
+// Its real part is half the power spectral density, estimated by averaging
+// the Hann-windowed spectra of half-overlapping chunks of chunksz samples,
+// and its poles
+// are those of the structure, so it can be fitted as a transfer function.
+func HalfSpectrum(fname string, chunksz int, ys []float64, freq float64) (FRF, error) {
+	if chunksz < 8 {
+		return FRF{}, fmt.Errorf("fouracc: chunk size %d too small for a power spectrum", chunksz)
+	}
+	if len(ys) < chunksz {
+		return FRF{}, fmt.Errorf("fouracc: not enough samples (%d) for a chunk size of %d", len(ys), chunksz)
+	}
+	scale := 1.0
+	if freq > 0 {
+		scale = freq
+	}
+
+	var (
+		n    = chunksz
+		fft  = fourier.NewFFT(n)
+		win  = make([]float64, n)
+		norm = 0.0
+		sub  = make([]float64, n)
+		psd  = make([]float64, n/2+1)
+		cs   = make([]complex128, n/2+1)
+		navg = 0
+	)
+	for i := range win {
+		win[i] = 0.5 * (1 - math.Cos(2*math.Pi*float64(i)/float64(n)))
+		norm += win[i] * win[i]
+	}
+	for beg := 0; beg+n <= len(ys); beg += n / 2 {
+		mean := 0.0
+		for _, v := range ys[beg : beg+n] {
+			mean += v
+		}
+		mean /= float64(n)
+		for i, v := range ys[beg : beg+n] {
+			sub[i] = (v - mean) * win[i]
+		}
+		fft.Coefficients(cs, sub)
+		for i, c := range cs {
+			v := cmplx.Abs(c)
+			psd[i] += v * v
+		}
+		navg++
+	}
+
+	// two-sided power spectral density.
+	spec := make([]complex128, len(psd))
+	for i, v := range psd {
+		spec[i] = complex(v/(float64(navg)*scale*norm), 0)
+	}
+
+	// keep the positive lags of the (circular) autocorrelation.
+	acf := fft.Sequence(nil, spec)
+	acf[0] *= 0.5
+	for i := n / 2; i < n; i++ {
+		acf[i] = 0
+	}
+	fft.Coefficients(cs, acf)
+
+	frf := FRF{
+		Freqs:    make([]float64, len(cs)),
+		H:        make([]complex128, len(cs)),
+		Averages: navg,
+		Name:     fname,
+		Scale:    freq,
+	}
+	for i, c := range cs {
+		frf.Freqs[i] = fft.Freq(i) * scale
+		frf.H[i] = c / complex(float64(n), 0)
+	}
+	return frf, nil
+}
+
+// Peaks returns the indices of the n highest local maxima of the magnitude
+// of the response, by increasing frequency.
+// Maxima whose half-power band overlaps the peak of a higher one, or the
+// other way around, are ignored, as are the DC and Nyquist bins.
+func (frf FRF) Peaks(n int) []int {
+	var maxima []int
+	for i := 1; i < len(frf.H)-1; i++ {
+		v := cmplx.Abs(frf.H[i])
+		if v > cmplx.Abs(frf.H[i-1]) && v >= cmplx.Abs(frf.H[i+1]) {
+			maxima = append(maxima, i)
+		}
+	}
+	sort.Slice(maxima, func(i, j int) bool {
+		return cmplx.Abs(frf.H[maxima[i]]) > cmplx.Abs(frf.H[maxima[j]])
+	})
+
+	var (
+		peaks []int
+		bands [][2]int
+	)
+loop:
+	for _, i := range maxima {
+		if len(peaks) == n {
+			break
+		}
+		lo, hi, err := frf.halfPower(i)
+		if err != nil {
+			lo, hi = i-1, i+1
+		}
+		for j, b := range bands {
+			if (b[0] < i && i < b[1]) || (lo < peaks[j] && peaks[j] < hi) {
+				continue loop
+			}
+		}
+		peaks = append(peaks, i)
+		bands = append(bands, [2]int{lo, hi})
+	}
+	sort.Ints(peaks)
+	return peaks
+}
+
+// ModalMethod is a method to extract modal parameters from a frequency
+// response.
+type ModalMethod int
+
+const (
+	HalfPower ModalMethod = iota // half-power bandwidth
+	CircleFit                    // circle fit in the Nyquist plane
+	RFP                          // rational-fraction polynomial fit
+)
+
+// ModalMethods lists the modal parameter extraction methods.
+var ModalMethods = []ModalMethod{HalfPower, CircleFit, RFP}
+
+// ParseModalMethod returns the modal parameter extraction method named
+// after the provided name.
+func ParseModalMethod(name string) (ModalMethod, error) {
+	switch strings.ToLower(name) {
+	case "hp", "half-power":
+		return HalfPower, nil
+	case "circle":
+		return CircleFit, nil
+	case "rfp":
+		return RFP, nil
+	}
+	return 0, fmt.Errorf("fouracc: unknown modal method %q", name)
+}
+
+func (m ModalMethod) String() string {
+	switch m {
+	case HalfPower:
+		return "half-power"
+	case CircleFit:
+		return "circle"
+	case RFP:
+		return "rfp"
+	}
+	return fmt.Sprintf("ModalMethod(%d)", int(m))
+}
+
+// Mode holds the modal parameters of a resonance, with their standard
+// uncertainties.
+type Mode struct {
+	Freq       float64 // natural frequency
+	FreqErr    float64
+	Damping    float64 // damping ratio
+	DampingErr float64
+	Peak       float64 // magnitude of the response at the peak
+
+	Method ModalMethod
+}
+
+// Mode extracts the modal parameters of the resonance at the peak-th bin,
+// with the provided method.
+//
+// Circle and rational-fraction polynomial fits use the bins of the
+// half-power band of the peak, which must span at least 5 bins.
+func (frf FRF) Mode(peak int, method ModalMethod) (Mode, error) {
+	if peak <= 0 || peak >= len(frf.H)-1 {
+		return Mode{}, fmt.Errorf("fouracc: invalid peak bin %d", peak)
+	}
+	lo, hi, err := frf.halfPower(peak)
+	if err != nil {
+		return Mode{}, err
+	}
+	switch method {
+	case HalfPower:
+		return frf.halfPowerMode(peak, lo, hi), nil
+	case CircleFit, RFP:
+		if hi-lo+1 < 5 {
+			return Mode{}, fmt.Errorf(
+				"fouracc: mode at %.4g not resolved (%d bins in half-power band; increase the chunk size)",
+				frf.Freqs[peak], hi-lo+1,
+			)
+		}
+		if method == CircleFit {
+			return frf.circleFit(peak, lo, hi)
+		}
+		return frf.rfp(peak, lo, hi)
+	}
+	return Mode{}, fmt.Errorf("fouracc: unknown modal method %d", int(method))
+}
+
+// power returns the squared magnitude of the response at bin i.
+func (frf FRF) power(i int) float64 {
+	v := cmplx.Abs(frf.H[i])
+	return v * v
+}
+
+// halfPower returns the first bins, on each side of the peak, below half
+// the power of the peak.
+func (frf FRF) halfPower(peak int) (lo, hi int, err error) {
+	half := 0.5 * frf.power(peak)
+	for lo = peak - 1; lo >= 0 && frf.power(lo) > half; lo-- {
+	}
+	for hi = peak + 1; hi < len(frf.H) && frf.power(hi) > half; hi++ {
+	}
+	if lo < 0 || hi >= len(frf.H) {
+		return 0, 0, fmt.Errorf("fouracc: half-power band of the mode at %.4g out of range", frf.Freqs[peak])
+	}
+	return lo, hi, nil
+}
+
+func (frf FRF) halfPowerMode(peak, lo, hi int) Mode {
+	var (
+		half = 0.5 * frf.power(peak)
+		// crossing frequency between bins i and j.
+		cross = func(i, j int) float64 {
+			pi, pj := frf.power(i), frf.power(j)
+			return frf.Freqs[i] + (half-pi)/(pj-pi)*(frf.Freqs[j]-frf.Freqs[i])
+		}
+		f1 = cross(lo, lo+1)
+		f2 = cross(hi, hi-1)
+		df = frf.Freqs[1] - frf.Freqs[0]
+		// crossings are located to within a bin.
+		ef = df / math.Sqrt(12)
+		fn = frf.Freqs[peak]
+	)
+	if frf.Averages > 0 {
+		// the relative noise of an averaged power spectrum moves the
+		// crossings by as much as the half bandwidth times that noise.
+		ef = math.Hypot(ef, 0.5*(f2-f1)/math.Sqrt(float64(frf.Averages)))
+	}
+	// refine the peak with a parabola through the neighbouring bins.
+	if p0, p1, p2 := frf.power(peak-1), frf.power(peak), frf.power(peak+1); p0-2*p1+p2 < 0 {
+		fn += 0.5 * (p0 - p2) / (p0 - 2*p1 + p2) * df
+	}
+	return Mode{
+		Freq:       fn,
+		FreqErr:    ef,
+		Damping:    (f2 - f1) / (2 * fn),
+		DampingErr: math.Sqrt2 * ef / (2 * fn),
+		Peak:       cmplx.Abs(frf.H[peak]),
+		Method:     HalfPower,
+	}
+}
+
+// circleFit fits a circle to the response of the [lo,hi] bins in the
+// Nyquist plane, locates the natural frequency at the maximum angular
+// sweep rate and averages the damping estimates of all pairs of bins
+// across it.
+func (frf FRF) circleFit(peak, lo, hi int) (Mode, error) {
+	var (
+		m   = hi - lo + 1
+		a   = mat.NewDense(m, 3, nil)
+		rhs = mat.NewVecDense(m, nil)
+	)
+	for i := 0; i < m; i++ {
+		x, y := real(frf.H[lo+i]), imag(frf.H[lo+i])
+		a.SetRow(i, []float64{x, y, 1})
+		rhs.SetVec(i, -(x*x + y*y))
+	}
+	var sol mat.VecDense
+	err := sol.SolveVec(a, rhs)
+	if err != nil {
+		return Mode{}, fmt.Errorf("fouracc: could not fit circle: %w", err)
+	}
+	xc, yc := -0.5*sol.AtVec(0), -0.5*sol.AtVec(1)
+
+	// angles around the centre, unwrapped along frequency.
+	phi := make([]float64, m)
+	for i := range phi {
+		phi[i] = math.Atan2(imag(frf.H[lo+i])-yc, real(frf.H[lo+i])-xc)
+		if i > 0 {
+			for phi[i]-phi[i-1] > math.Pi {
+				phi[i] -= 2 * math.Pi
+			}
+			for phi[i]-phi[i-1] < -math.Pi {
+				phi[i] += 2 * math.Pi
+			}
+		}
+	}
+
+	// natural frequency: maximum sweep rate dphi/d(f^2).
+	var (
+		fs   = frf.Freqs[lo : hi+1]
+		jmax = 0
+		rate = func(j int) float64 {
+			return math.Abs(phi[j+1]-phi[j]) / (fs[j+1]*fs[j+1] - fs[j]*fs[j])
+		}
+	)
+	for j := 1; j < m-1; j++ {
+		if rate(j) > rate(jmax) {
+			jmax = j
+		}
+	}
+	var (
+		fn   = math.Sqrt(0.5 * (fs[jmax]*fs[jmax] + fs[jmax+1]*fs[jmax+1]))
+		phin = 0.5 * (phi[jmax] + phi[jmax+1])
+		zs   []float64
+	)
+	for ib := 0; ib <= jmax; ib++ {
+		for ia := jmax + 1; ia < m; ia++ {
+			var (
+				tb = math.Tan(0.5 * math.Abs(phi[ib]-phin))
+				ta = math.Tan(0.5 * math.Abs(phi[ia]-phin))
+			)
+			if ta+tb <= 0 || math.Abs(phi[ib]-phin) >= math.Pi || math.Abs(phi[ia]-phin) >= math.Pi {
+				continue
+			}
+			eta := (fs[ia]*fs[ia] - fs[ib]*fs[ib]) / (fn * fn * (ta + tb))
+			zs = append(zs, 0.5*eta)
+		}
+	}
+	if len(zs) == 0 {
+		return Mode{}, fmt.Errorf("fouracc: no damping estimate from circle fit of mode at %.4g", frf.Freqs[peak])
+	}
+	zeta, std := meanStd(zs)
+	if len(zs) < 2 {
+		std = math.NaN()
+	}
+	return Mode{
+		Freq:       fn,
+		FreqErr:    (fs[jmax+1] - fs[jmax]) / math.Sqrt(12),
+		Damping:    zeta,
+		DampingErr: std,
+		Peak:       cmplx.Abs(frf.H[peak]),
+		Method:     CircleFit,
+	}, nil
+}
+
+// rfp fits the response of the [lo,hi] bins with the rational fraction
+//
+//	H(s) = (b0 + b1 s) / (a0 + a1 s + s²)
+//
+// by linear least squares, with s normalized to the peak frequency.
+// Uncertainties are propagated from the covariance of the fit.
+func (frf FRF) rfp(peak, lo, hi int) (Mode, error) {
+	var (
+		m   = hi - lo + 1
+		fp  = frf.Freqs[peak]
+		a   = mat.NewDense(2*m, 4, nil)
+		rhs = mat.NewVecDense(2*m, nil)
+	)
+	// H (a0 + a1 s) - (b0 + b1 s) = -H s², with unknowns (a0, a1, b0, b1),
+	// and H normalized to the peak.
+	norm := complex(1/cmplx.Abs(frf.H[peak]), 0)
+	for i := 0; i < m; i++ {
+		var (
+			h  = frf.H[lo+i] * norm
+			s  = complex(0, frf.Freqs[lo+i]/fp)
+			hs = h * s
+			r  = -h * s * s
+		)
+		a.SetRow(2*i, []float64{real(h), real(hs), -1, -real(s)})
+		a.SetRow(2*i+1, []float64{imag(h), imag(hs), 0, -imag(s)})
+		rhs.SetVec(2*i, real(r))
+		rhs.SetVec(2*i+1, imag(r))
+	}
+
+	var sol mat.VecDense
+	err := sol.SolveVec(a, rhs)
+	if err != nil {
+		return Mode{}, fmt.Errorf("fouracc: could not fit rational fraction: %w", err)
+	}
+	a0, a1 := sol.AtVec(0), sol.AtVec(1)
+	if a0 <= 0 || a1 <= 0 {
+		return Mode{}, fmt.Errorf("fouracc: unstable rational fraction fit of mode at %.4g", fp)
+	}
+
+	var res mat.VecDense
+	res.MulVec(a, &sol)
+	res.SubVec(&res, rhs)
+	var (
+		dof = float64(2*m - 4)
+		s2  = mat.Dot(&res, &res) / dof
+		ata mat.Dense
+		cov mat.Dense
+	)
+	ata.Mul(a.T(), a)
+	err = cov.Inverse(&ata)
+	if err != nil {
+		return Mode{}, fmt.Errorf("fouracc: could not invert rational fraction normal matrix: %w", err)
+	}
+	cov.Scale(s2, &cov)
+
+	var (
+		wn   = math.Sqrt(a0)
+		zeta = a1 / (2 * wn)
+		// gradient of zeta with respect to (a0, a1).
+		g0 = -a1 / (4 * a0 * wn)
+		g1 = 1 / (2 * wn)
+		vz = g0*g0*cov.At(0, 0) + 2*g0*g1*cov.At(0, 1) + g1*g1*cov.At(1, 1)
+	)
+	return Mode{
+		Freq:       wn * fp,
+		FreqErr:    math.Sqrt(cov.At(0, 0)) / (2 * wn) * fp,
+		Damping:    zeta,
+		DampingErr: math.Sqrt(math.Max(vz, 0)),
+		Peak:       cmplx.Abs(frf.H[peak]),
+		Method:     RFP,
+	}, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import (
+	"fmt"
+	"math"
+)
+
+// alignLobes is the number of lobes, on each side, of the Lanczos-windowed
+// sinc kernel of the fractional-delay filter of Align.
+const alignLobes = 16
+
+// WithAlignment instructs Parse and Merge to align the channels on the
+// common time base of the file, see File.Align.
+func WithAlignment() Option {
+	return func(cfg *config) {
+		cfg.align = true
+	}
+}
+
+// Align returns a copy of the file whose channels are shifted by their
+// TimeDelay onto the timestamps of the file.
+//
+// A channel with a time delay d holds at time t the sample measured at
+// t+d: its data are delayed by d with a Lanczos-windowed sinc
+// fractional-delay filter, assuming a uniform sampling at the nominal rate.
+// Delays of a whole number of sampling intervals shift the data exactly.
+// Samples beyond the ends of the channel are held at the edge values.
+// Forward-filled samples are shifted by the delay rounded to the nearest
+// sample.
+//
+// Channels already aligned, or without time delay, are left untouched.
+func (f File) Align() (File, error) {
+	var todo []int
+	for i, col := range f.Cols {
+		if i == 0 || col.Aligned || col.TimeDelay == 0 {
+			continue
+		}
+		todo = append(todo, i)
+	}
+	if len(todo) == 0 {
+		return f, nil
+	}
+
+	rate, err := f.Rate()
+	if err != nil {
+		return f, fmt.Errorf("could not align channels: %w", err)
+	}
+
+	out := f
+	out.Cols = make([]Column, len(f.Cols))
+	copy(out.Cols, f.Cols)
+	for _, i := range todo {
+		col := &out.Cols[i]
+		data, ok := col.Data.([]float64)
+		if !ok {
+			return f, fmt.Errorf("could not align channel %q: invalid data type %T", col.Name, col.Data)
+		}
+		shift := float64(col.TimeDelay) / float64(rate.Interval)
+		col.Data = delay(data, shift)
+		col.Filled = delayFilled(col.Filled, int(math.Round(shift)), len(data))
+		col.Aligned = true
+	}
+	if f.Mask != nil {
+		out.Mask = out.mask()
+	}
+	return out, nil
+}
+
+// delay returns the samples vs delayed by shift samples: the i-th returned
+// sample is vs interpolated at i-shift.
+// Integer shifts copy the samples, without interpolation.
+func delay(vs []float64, shift float64) []float64 {
+	var (
+		n   = len(vs)
+		out = make([]float64, n)
+		hw  = float64(alignLobes)
+	)
+	if n == 0 {
+		return out
+	}
+	for i := range out {
+		t := float64(i) - shift
+		switch {
+		case t <= 0:
+			out[i] = vs[0]
+			continue
+		case t >= float64(n-1):
+			out[i] = vs[n-1]
+			continue
+		case t == math.Trunc(t):
+			out[i] = vs[int(t)]
+			continue
+		}
+
+		var (
+			beg    = int(math.Max(0, math.Ceil(t-hw)))
+			end    = int(math.Min(float64(n-1), math.Floor(t+hw)))
+			sum, w float64
+		)
+		for k := beg; k <= end; k++ {
+			x := t - float64(k)
+			h := sinc(x) * sinc(x/hw)
+			sum += h * vs[k]
+			w += h
+		}
+		// normalize by the kernel weight, to preserve the mean close to
+		// the edges of the channel.
+		out[i] = sum / w
+	}
+	return out
+}
+
+// delayFilled returns the indices of the forward-filled samples shifted by
+// shift samples, dropping those beyond the n samples of the channel.
+func delayFilled(filled []int, shift, n int) []int {
+	if len(filled) == 0 || shift == 0 {
+		return filled
+	}
+	out := make([]int, 0, len(filled))
+	for _, j := range filled {
+		j += shift
+		if j < 0 || j >= n {
+			continue
+		}
+		out = append(out, j)
+	}
+	return out
+}
+
+// sinc is the normalized sinc function.
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
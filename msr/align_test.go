@@ -0,0 +1,149 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// alignFile returns a file of n samples, sampled at 50 Hz, with a "ref"
+// channel and a "delayed" channel with the provided time delay, both
+// holding fct(t), t in seconds.
+func alignFile(n int, d time.Duration, fct func(t float64) float64) File {
+	var (
+		t0  = time.Date(2019, 8, 6, 14, 0, 0, 0, time.UTC)
+		ts  = make([]time.Time, n)
+		ref = make([]float64, n)
+		del = make([]float64, n)
+	)
+	for i := range ts {
+		ts[i] = t0.Add(time.Duration(i) * 20 * time.Millisecond)
+		ref[i] = fct(float64(i) * 0.020)
+		del[i] = ref[i]
+	}
+	return File{
+		Start: t0,
+		Cols: []Column{
+			{Name: "Time", Data: ts},
+			{Name: "ref", Data: ref},
+			{Name: "delayed", Data: del, TimeDelay: d},
+		},
+	}
+}
+
+func TestAlignInteger(t *testing.T) {
+	const n = 64
+	fct := func(t float64) float64 { return t*t - 3*t + math.Sin(17*t) }
+	for _, tc := range []struct {
+		delay time.Duration
+		shift int
+	}{
+		{40 * time.Millisecond, 2},
+		{-60 * time.Millisecond, -3},
+		{20 * time.Millisecond, 1},
+		{2 * time.Second, 100}, // beyond the channel.
+	} {
+		t.Run(tc.delay.String(), func(t *testing.T) {
+			f := alignFile(n, tc.delay, fct)
+			f.Cols[2].Filled = []int{0, 10, n - 1}
+
+			got, err := f.Align()
+			if err != nil {
+				t.Fatalf("could not align file: %+v", err)
+			}
+			var (
+				vs  = got.Cols[2].Data.([]float64)
+				raw = f.Cols[2].Data.([]float64)
+			)
+			for i, v := range vs {
+				j := i - tc.shift
+				switch {
+				case j < 0:
+					j = 0
+				case j >= n:
+					j = n - 1
+				}
+				if v != raw[j] {
+					t.Fatalf("invalid sample %d: got=%v, want=%v (exact shift)", i, v, raw[j])
+				}
+			}
+			if !got.Cols[2].Aligned || got.Cols[1].Aligned {
+				t.Fatalf("invalid aligned flags: ref=%v, delayed=%v", got.Cols[1].Aligned, got.Cols[2].Aligned)
+			}
+
+			var want []int
+			for _, j := range f.Cols[2].Filled {
+				if j += tc.shift; 0 <= j && j < n {
+					want = append(want, j)
+				}
+			}
+			if len(got.Cols[2].Filled) != len(want) {
+				t.Fatalf("invalid filled samples: got=%v, want=%v", got.Cols[2].Filled, want)
+			}
+			for i := range want {
+				if got.Cols[2].Filled[i] != want[i] {
+					t.Fatalf("invalid filled samples: got=%v, want=%v", got.Cols[2].Filled, want)
+				}
+			}
+
+			// the input file is left untouched, and aligning twice is a no-op.
+			if f.Cols[2].Aligned || &raw[0] == &vs[0] {
+				t.Fatalf("input file modified")
+			}
+			again, err := got.Align()
+			if err != nil {
+				t.Fatalf("could not re-align file: %+v", err)
+			}
+			if &again.Cols[2].Data.([]float64)[0] != &vs[0] {
+				t.Fatalf("aligned channel re-aligned")
+			}
+		})
+	}
+}
+
+func TestAlignFractional(t *testing.T) {
+	const (
+		n  = 500
+		f0 = 2.0 // Hz, well below the 25 Hz Nyquist frequency.
+	)
+	fct := func(t float64) float64 { return math.Sin(2 * math.Pi * f0 * t) }
+	for _, d := range []time.Duration{
+		10 * time.Millisecond,
+		-5 * time.Millisecond,
+		33 * time.Millisecond,
+	} {
+		t.Run(d.String(), func(t *testing.T) {
+			got, err := alignFile(n, d, fct).Align()
+			if err != nil {
+				t.Fatalf("could not align file: %+v", err)
+			}
+			vs := got.Cols[2].Data.([]float64)
+			// away from the edges, the channel is the signal delayed by d.
+			for i := 2 * alignLobes; i < n-2*alignLobes; i++ {
+				want := fct(float64(i)*0.020 - d.Seconds())
+				if math.Abs(vs[i]-want) > 1e-3 {
+					t.Fatalf("invalid sample %d: got=%v, want=%v", i, vs[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestAlignErrors(t *testing.T) {
+	f := alignFile(1, 20*time.Millisecond, func(float64) float64 { return 1 })
+	_, err := f.Align()
+	if err == nil {
+		t.Fatalf("expected an error without sampling rate")
+	}
+
+	// no delayed channel: no sampling rate needed.
+	f.Cols[2].TimeDelay = 0
+	_, err = f.Align()
+	if err != nil {
+		t.Fatalf("could not align file: %+v", err)
+	}
+}
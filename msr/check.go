@@ -0,0 +1,275 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// IssueKind describes the kind of data-integrity issue found in a MSR file.
+type IssueKind byte
+
+const (
+	GapIssue          IssueKind = iota + 1 // missing samples in the timestamp sequence
+	FilledIssue                            // cells forward-filled from empty values
+	DuplicateIssue                         // repeated timestamps
+	NonMonotonicIssue                      // timestamps going backwards
+	RateChangeIssue                        // change of the sampling rate
+)
+
+func (k IssueKind) String() string {
+	switch k {
+	case GapIssue:
+		return "gap"
+	case FilledIssue:
+		return "filled"
+	case DuplicateIssue:
+		return "duplicate"
+	case NonMonotonicIssue:
+		return "non-monotonic"
+	case RateChangeIssue:
+		return "rate-change"
+	}
+	return fmt.Sprintf("IssueKind(%d)", int(k))
+}
+
+// Issue is a data-integrity issue affecting a range of samples.
+type Issue struct {
+	Kind    IssueKind
+	Channel string    // name of the affected channel, if any
+	Beg     int       // index of the first affected sample
+	End     int       // index one past the last affected sample
+	Start   time.Time // timestamp of the first affected sample
+	Stop    time.Time // timestamp of the last affected sample
+	Msg     string    // human readable description
+}
+
+func (is Issue) String() string {
+	var ch string
+	if is.Channel != "" {
+		ch = " [" + is.Channel + "]"
+	}
+	return fmt.Sprintf(
+		"%v%s: samples [%d, %d), %s -> %s: %s",
+		is.Kind, ch, is.Beg, is.End,
+		is.Start.Format(timeFormat), is.Stop.Format(timeFormat),
+		is.Msg,
+	)
+}
+
+const timeFormat = "2006-01-02 15:04:05.000"
+
+// Report is the result of a data-integrity check of a MSR file.
+type Report struct {
+	Samples  int           // number of samples
+	Interval time.Duration // nominal (median) sampling interval
+	Issues   []Issue
+}
+
+// Check runs a data-integrity pass over the provided MSR file.
+//
+// Check reports gaps in the timestamp sequence, forward-filled cells,
+// duplicate and non-monotonic timestamps, and sampling rate changes.
+func Check(f File) Report {
+	var rep Report
+	if len(f.Cols) == 0 {
+		return rep
+	}
+	ts, ok := f.Cols[0].Data.([]time.Time)
+	if !ok || len(ts) == 0 {
+		return rep
+	}
+	rep.Samples = len(ts)
+	rep.Interval = medianInterval(ts)
+
+	local := localIntervals(ts)
+	rep.Issues = append(rep.Issues, checkTimes(ts, local)...)
+	rep.Issues = append(rep.Issues, checkRates(ts, local)...)
+	for _, col := range f.Cols[1:] {
+		rep.Issues = append(rep.Issues, checkFilled(ts, col)...)
+	}
+
+	sort.SliceStable(rep.Issues, func(i, j int) bool {
+		return rep.Issues[i].Beg < rep.Issues[j].Beg
+	})
+	return rep
+}
+
+// checkTimes reports gaps, duplicate and non-monotonic timestamps.
+//
+// When the local sampling interval spans less than quantizedSteps steps of
+// the timestamp resolution, intervals within one step of it are regular
+// (e.g. the 0 and 1 ms intervals of a 1600 Hz sampling with millisecond
+// timestamps), as for Rate.
+func checkTimes(ts []time.Time, local []time.Duration) []Issue {
+	if len(ts) < 2 {
+		return nil
+	}
+	var (
+		issues []Issue
+		res    = resolution(ts)
+	)
+	for i := 1; i < len(ts); i++ {
+		var (
+			dt        = ts[i].Sub(ts[i-1])
+			med       = local[i-1]
+			quantized = med < quantizedSteps*res
+			gap       = med * 3 / 2
+		)
+		if quantized && gap < med+res {
+			gap = med + res
+		}
+		switch {
+		case dt < 0:
+			issues = append(issues, Issue{
+				Kind: NonMonotonicIssue, Beg: i - 1, End: i + 1,
+				Start: ts[i-1], Stop: ts[i],
+				Msg: fmt.Sprintf("timestamp goes backwards by %v", -dt),
+			})
+		case dt == 0 && !(quantized && med <= res):
+			beg := i - 1
+			for i+1 < len(ts) && ts[i+1].Equal(ts[i]) {
+				i++
+			}
+			issues = append(issues, Issue{
+				Kind: DuplicateIssue, Beg: beg, End: i + 1,
+				Start: ts[beg], Stop: ts[i],
+				Msg: fmt.Sprintf("%d samples share the same timestamp", i+1-beg),
+			})
+		case (med > 0 || quantized) && dt > gap:
+			missing := int(math.Round(float64(dt)/float64(med))) - 1
+			if med <= 0 {
+				missing = int(dt / res)
+			}
+			issues = append(issues, Issue{
+				Kind: GapIssue, Beg: i - 1, End: i + 1,
+				Start: ts[i-1], Stop: ts[i],
+				Msg: fmt.Sprintf("gap of %v (~%d missing samples)", dt, missing),
+			})
+		}
+	}
+	return issues
+}
+
+// rateBlock is the number of sampling intervals used to estimate the
+// local sampling rate.
+const rateBlock = 16
+
+// localIntervals returns, for each sampling interval, the median sampling
+// interval of the block of rateBlock intervals it belongs to.
+func localIntervals(ts []time.Time) []time.Duration {
+	if len(ts) < 2 {
+		return nil
+	}
+	local := make([]time.Duration, len(ts)-1)
+	for i := 0; i < len(local); i += rateBlock {
+		end := i + rateBlock
+		if end > len(local) {
+			end = len(local)
+		}
+		beg := i
+		if end-beg < rateBlock/2 {
+			// merge a short trailing block with the previous one.
+			beg = end - rateBlock
+			if beg < 0 {
+				beg = 0
+			}
+		}
+		dt := medianInterval(ts[beg : end+1])
+		for j := i; j < end; j++ {
+			local[j] = dt
+		}
+	}
+	return local
+}
+
+// checkRates reports changes of the sampling rate.
+func checkRates(ts []time.Time, local []time.Duration) []Issue {
+	var issues []Issue
+	for _, c := range rateChanges(ts, local) {
+		beg := c.Index - rateBlock
+		if beg < 0 {
+			beg = 0
+		}
+		end := c.Index + rateBlock + 1
+		if end > len(ts) {
+			end = len(ts)
+		}
+		issues = append(issues, Issue{
+			Kind: RateChangeIssue, Beg: beg, End: end,
+			Start: ts[beg], Stop: ts[end-1],
+			Msg: fmt.Sprintf("sampling rate changes from %.6g Hz to %.6g Hz", c.From, c.To),
+		})
+	}
+	return issues
+}
+
+// checkFilled reports ranges of forward-filled cells.
+func checkFilled(ts []time.Time, col Column) []Issue {
+	var issues []Issue
+	for i := 0; i < len(col.Filled); i++ {
+		beg := col.Filled[i]
+		end := beg + 1
+		for i+1 < len(col.Filled) && col.Filled[i+1] == end {
+			end++
+			i++
+		}
+		issues = append(issues, Issue{
+			Kind: FilledIssue, Channel: col.Name, Beg: beg, End: end,
+			Start: ts[beg], Stop: ts[end-1],
+			Msg: fmt.Sprintf("%d empty cells forward-filled", end-beg),
+		})
+	}
+	return issues
+}
+
+// mask returns the per-sample validity mask of the file.
+// Samples with a repeated or backwards timestamp, samples bordering a gap
+// in the timestamp sequence and forward-filled samples are invalid.
+// Repeated timestamps of a quantized sampling (see checkTimes) are valid.
+func (f File) mask() []bool {
+	if len(f.Cols) == 0 {
+		return nil
+	}
+	ts, ok := f.Cols[0].Data.([]time.Time)
+	if !ok {
+		return nil
+	}
+	mask := make([]bool, len(ts))
+	for i := range mask {
+		mask[i] = true
+	}
+	for _, is := range checkTimes(ts, localIntervals(ts)) {
+		beg := is.Beg
+		if is.Kind != GapIssue {
+			// keep the first sample of repeated or backwards timestamps.
+			beg++
+		}
+		for i := beg; i < is.End; i++ {
+			mask[i] = false
+		}
+	}
+	for _, col := range f.Cols[1:] {
+		for _, i := range col.Filled {
+			mask[i] = false
+		}
+	}
+	return mask
+}
+
+// medianInterval returns the median interval between consecutive timestamps.
+func medianInterval(ts []time.Time) time.Duration {
+	if len(ts) < 2 {
+		return 0
+	}
+	dts := make([]time.Duration, len(ts)-1)
+	for i := range dts {
+		dts[i] = ts[i+1].Sub(ts[i])
+	}
+	return median(dts)
+}
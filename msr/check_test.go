@@ -0,0 +1,144 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	t0 := time.Date(2019, 8, 6, 14, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name    string
+		f       File
+		kinds   []IssueKind
+		invalid []int // invalid samples of the mask
+	}{
+		{
+			name: "regular",
+			f:    mergeFile(t0, 0, 100),
+		},
+		{
+			name: "gap",
+			f: func() File {
+				f, _, _ := Merge([]File{mergeFile(t0, 0, 50), mergeFile(t0, 60, 50)})
+				return f
+			}(),
+			kinds:   []IssueKind{GapIssue},
+			invalid: []int{49, 50},
+		},
+		{
+			name: "duplicate",
+			f: func() File {
+				f := mergeFile(t0, 0, 100)
+				ts := f.Cols[0].Data.([]time.Time)
+				ts[31] = ts[30]
+				return f
+			}(),
+			// the interval following the duplicate is twice as long.
+			kinds:   []IssueKind{DuplicateIssue, GapIssue},
+			invalid: []int{31, 32},
+		},
+		{
+			name: "filled",
+			f: func() File {
+				f := mergeFile(t0, 0, 100)
+				f.Cols[1].Filled = []int{70, 71, 72}
+				return f
+			}(),
+			kinds:   []IssueKind{FilledIssue},
+			invalid: []int{70, 71, 72},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rep := Check(tc.f)
+			if got, want := rep.Interval, 20*time.Millisecond; got != want {
+				t.Fatalf("invalid interval: got=%v, want=%v", got, want)
+			}
+			if len(rep.Issues) != len(tc.kinds) {
+				t.Fatalf("invalid issues: got=%v, want=%v", rep.Issues, tc.kinds)
+			}
+			for i, is := range rep.Issues {
+				if is.Kind != tc.kinds[i] {
+					t.Fatalf("invalid issue %d: got=%v, want=%v", i, is.Kind, tc.kinds[i])
+				}
+			}
+
+			var (
+				mask    = tc.f.mask()
+				invalid []int
+			)
+			for i, ok := range mask {
+				if !ok {
+					invalid = append(invalid, i)
+				}
+			}
+			if len(invalid) != len(tc.invalid) {
+				t.Fatalf("invalid mask: got=%v, want=%v", invalid, tc.invalid)
+			}
+			for i := range invalid {
+				if invalid[i] != tc.invalid[i] {
+					t.Fatalf("invalid mask: got=%v, want=%v", invalid, tc.invalid)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckQuantized(t *testing.T) {
+	// millisecond timestamps of a 1600 Hz sampling are 0 or 1 ms apart.
+	quantized := func(ts []time.Time) File {
+		f := timeFile(ts)
+		f.Cols = append(f.Cols, Column{Name: "ACC x", Data: make([]float64, len(ts))})
+		return f
+	}
+	ts := regularTimes(1600, 1600, time.Millisecond)
+
+	for _, tc := range []struct {
+		name    string
+		f       File
+		kinds   []IssueKind
+		invalid []int
+	}{
+		{
+			name: "regular",
+			f:    quantized(ts),
+		},
+		{
+			name:    "gap",
+			f:       quantized(append(append([]time.Time(nil), ts[:800]...), ts[810:]...)),
+			kinds:   []IssueKind{GapIssue},
+			invalid: []int{799, 800},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rep := Check(tc.f)
+			if len(rep.Issues) != len(tc.kinds) {
+				t.Fatalf("invalid issues: got=%v, want=%v", rep.Issues, tc.kinds)
+			}
+			for i, is := range rep.Issues {
+				if is.Kind != tc.kinds[i] {
+					t.Fatalf("invalid issue %d: got=%v, want=%v", i, is.Kind, tc.kinds[i])
+				}
+			}
+
+			var invalid []int
+			for i, ok := range tc.f.mask() {
+				if !ok {
+					invalid = append(invalid, i)
+				}
+			}
+			if len(invalid) != len(tc.invalid) {
+				t.Fatalf("invalid mask: got=%v, want=%v", invalid, tc.invalid)
+			}
+			for i := range invalid {
+				if invalid[i] != tc.invalid[i] {
+					t.Fatalf("invalid mask: got=%v, want=%v", invalid, tc.invalid)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Junction describes the boundary between two consecutive files of a merged
+// MSR file.
+type Junction struct {
+	Index   int           // index of the first sample of the next file in the merged file
+	Time    time.Time     // timestamp of that sample
+	Gap     time.Duration // time between the two files, in excess of the sampling interval (negative for overlaps)
+	Missing int           // number of samples missing between the two files
+	Filled  int           // number of samples inserted to fill the gap, see WithGapFill
+	Dropped int           // number of samples of the next file dropped as overlapping the previous one
+}
+
+// WithGapFill instructs Merge to fill the gaps between consecutive files
+// with samples at the nominal sampling rate, forward-filled from the last
+// sample before the gap.
+func WithGapFill() Option {
+	return func(cfg *config) {
+		cfg.fill = true
+	}
+}
+
+// Merge stitches sequential recordings into one continuous MSR file.
+//
+// Files are ordered by the timestamp of their first sample, and must hold
+// the same channels, with the same units, recorded at the same nominal
+// sampling rate.
+// Samples of a file overlapping the previous one are dropped; gaps between
+// files are kept, or filled with WithGapFill.
+// The header of the merged file is the one of the earliest file.
+// WithAlignment aligns the channels of each file before stitching them, so
+// that no sample is interpolated across the gap between two files, and
+// WithMask computes the validity mask of the merged file.
+func Merge(files []File, opts ...Option) (File, []Junction, error) {
+	if len(files) == 0 {
+		return File{}, nil, fmt.Errorf("no file to merge")
+	}
+	var (
+		cfg   = newConfig(opts)
+		times = make([][]time.Time, len(files))
+		order = make([]int, len(files))
+	)
+	if cfg.align {
+		aligned := make([]File, len(files))
+		for i, f := range files {
+			var err error
+			aligned[i], err = f.Align()
+			if err != nil {
+				return File{}, nil, fmt.Errorf("could not merge file #%d: %w", i, err)
+			}
+		}
+		files = aligned
+	}
+	for i, f := range files {
+		ts, err := f.Times()
+		if err != nil {
+			return File{}, nil, fmt.Errorf("could not merge file #%d: %w", i, err)
+		}
+		if len(ts) == 0 {
+			return File{}, nil, fmt.Errorf("could not merge file #%d: no sample", i)
+		}
+		times[i] = ts
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return times[order[i]][0].Before(times[order[j]][0])
+	})
+
+	ref := files[order[0]]
+	rate, err := ref.Rate()
+	if err != nil {
+		return File{}, nil, fmt.Errorf("could not merge file #%d: %w", order[0], err)
+	}
+	for _, i := range order[1:] {
+		err := compatible(ref, files[i], rate)
+		if err != nil {
+			return File{}, nil, fmt.Errorf("could not merge file #%d: %w", i, err)
+		}
+	}
+
+	var (
+		out  = ref
+		chs  = ref.Channels()
+		ts   = make([]time.Time, 0, len(times[order[0]]))
+		vs   = make([][]float64, len(chs))
+		fill = make([][]int, len(chs))
+		jcts []Junction
+	)
+	out.Cols = make([]Column, len(ref.Cols))
+	copy(out.Cols, ref.Cols)
+	out.Mask = nil
+
+	for k, i := range order {
+		var (
+			f   = files[i]
+			fts = times[i]
+			beg = 0
+		)
+		if k > 0 {
+			var (
+				last = ts[len(ts)-1]
+				jct  = Junction{Index: len(ts), Gap: fts[0].Sub(last) - rate.Interval}
+			)
+			for beg < len(fts) && !fts[beg].After(last) {
+				beg++
+			}
+			jct.Dropped = beg
+			if beg == len(fts) {
+				jct.Time = last
+				jcts = append(jcts, jct)
+				continue
+			}
+			jct.Time = fts[beg]
+			if jct.Gap > 0 {
+				n := int(math.Round(float64(fts[beg].Sub(last))/float64(rate.Interval))) - 1
+				if n > 0 {
+					jct.Missing = n
+				}
+			}
+			if cfg.fill {
+				for j := 1; j <= jct.Missing; j++ {
+					for c := range vs {
+						fill[c] = append(fill[c], len(ts))
+						vs[c] = append(vs[c], vs[c][len(vs[c])-1])
+					}
+					ts = append(ts, last.Add(time.Duration(j)*rate.Interval))
+				}
+				jct.Filled = jct.Missing
+				jct.Index = len(ts)
+			}
+			jcts = append(jcts, jct)
+		}
+
+		off := len(ts) - beg
+		ts = append(ts, fts[beg:]...)
+		for c, col := range f.Channels() {
+			data, ok := col.Data.([]float64)
+			if !ok {
+				return File{}, nil, fmt.Errorf("could not merge file #%d: invalid data type %T of channel %q", i, col.Data, col.Name)
+			}
+			if len(data) != len(fts) {
+				return File{}, nil, fmt.Errorf("could not merge file #%d: channel %q has %d samples, want %d", i, col.Name, len(data), len(fts))
+			}
+			vs[c] = append(vs[c], data[beg:]...)
+			for _, j := range col.Filled {
+				if j >= beg {
+					fill[c] = append(fill[c], j+off)
+				}
+			}
+		}
+	}
+
+	out.Cols[0].Data = ts
+	for c := range chs {
+		out.Cols[c+1].Data = vs[c]
+		out.Cols[c+1].Filled = fill[c]
+	}
+	if cfg.mask {
+		out.Mask = out.mask()
+	}
+	return out, jcts, nil
+}
+
+// compatible returns whether the file f can be merged with the reference
+// file ref, sampled at rate.
+func compatible(ref, f File, rate Rate) error {
+	var (
+		want = ref.Channels()
+		got  = f.Channels()
+	)
+	if len(got) != len(want) {
+		return fmt.Errorf("%d channels, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name || got[i].Unit != want[i].Unit {
+			return fmt.Errorf(
+				"channel #%d is %q [%s], want %q [%s]",
+				i, got[i].Name, got[i].Unit, want[i].Name, want[i].Unit,
+			)
+		}
+		if got[i].Calibrated != want[i].Calibrated {
+			return fmt.Errorf("channel %q calibrated=%v, want %v", got[i].Name, got[i].Calibrated, want[i].Calibrated)
+		}
+		if got[i].Aligned != want[i].Aligned {
+			return fmt.Errorf("channel %q aligned=%v, want %v", got[i].Name, got[i].Aligned, want[i].Aligned)
+		}
+	}
+
+	r, err := f.Rate()
+	switch {
+	case err != nil:
+		// single sample: no rate to check.
+	case math.Abs(r.Nominal-rate.Nominal) > 0.01*rate.Nominal:
+		return fmt.Errorf("sampling rate %.6g Hz differs from %.6g Hz", r.Nominal, rate.Nominal)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// mergeFile returns a file of n samples of the "ACC x" channel, sampled at
+// 50 Hz from the i0-th sample after t0, whose values are the sample indices.
+func mergeFile(t0 time.Time, i0, n int) File {
+	var (
+		ts = make([]time.Time, n)
+		vs = make([]float64, n)
+	)
+	for i := range ts {
+		ts[i] = t0.Add(time.Duration(i0+i) * 20 * time.Millisecond)
+		vs[i] = float64(i0 + i)
+	}
+	return File{
+		Start: ts[0],
+		Cols: []Column{
+			{Name: "Time", Data: ts},
+			{Name: "ACC x", Unit: "g", Data: vs},
+		},
+	}
+}
+
+func TestMerge(t *testing.T) {
+	t0 := time.Date(2019, 8, 6, 14, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name   string
+		files  []File
+		opts   []Option
+		want   []float64 // merged data
+		filled []int
+		jcts   []Junction
+	}{
+		{
+			name:  "contiguous",
+			files: []File{mergeFile(t0, 0, 4), mergeFile(t0, 4, 3)},
+			want:  []float64{0, 1, 2, 3, 4, 5, 6},
+			jcts:  []Junction{{Index: 4, Time: t0.Add(80 * time.Millisecond)}},
+		},
+		{
+			name:  "unordered",
+			files: []File{mergeFile(t0, 7, 2), mergeFile(t0, 0, 4), mergeFile(t0, 4, 3)},
+			want:  []float64{0, 1, 2, 3, 4, 5, 6, 7, 8},
+			jcts: []Junction{
+				{Index: 4, Time: t0.Add(80 * time.Millisecond)},
+				{Index: 7, Time: t0.Add(140 * time.Millisecond)},
+			},
+		},
+		{
+			name:  "gap",
+			files: []File{mergeFile(t0, 0, 3), mergeFile(t0, 5, 2)},
+			want:  []float64{0, 1, 2, 5, 6},
+			jcts: []Junction{{
+				Index: 3, Time: t0.Add(100 * time.Millisecond),
+				Gap: 40 * time.Millisecond, Missing: 2,
+			}},
+		},
+		{
+			name:   "gap-fill",
+			files:  []File{mergeFile(t0, 0, 3), mergeFile(t0, 5, 2)},
+			opts:   []Option{WithGapFill()},
+			want:   []float64{0, 1, 2, 2, 2, 5, 6},
+			filled: []int{3, 4},
+			jcts: []Junction{{
+				Index: 5, Time: t0.Add(100 * time.Millisecond),
+				Gap: 40 * time.Millisecond, Missing: 2, Filled: 2,
+			}},
+		},
+		{
+			name:  "overlap",
+			files: []File{mergeFile(t0, 0, 5), mergeFile(t0, 3, 4)},
+			want:  []float64{0, 1, 2, 3, 4, 5, 6},
+			jcts: []Junction{{
+				Index: 5, Time: t0.Add(100 * time.Millisecond),
+				Gap: -40 * time.Millisecond, Dropped: 2,
+			}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, jcts, err := Merge(tc.files, tc.opts...)
+			if err != nil {
+				t.Fatalf("could not merge files: %+v", err)
+			}
+
+			vs, col, err := got.Channel("ACC x")
+			if err != nil {
+				t.Fatalf("could not retrieve channel: %+v", err)
+			}
+			if !reflect.DeepEqual(vs, tc.want) {
+				t.Fatalf("invalid merged data:\ngot= %v\nwant=%v", vs, tc.want)
+			}
+			if !reflect.DeepEqual(col.Filled, tc.filled) {
+				t.Fatalf("invalid filled samples: got=%v, want=%v", col.Filled, tc.filled)
+			}
+
+			ts, _ := got.Times()
+			if len(ts) != len(vs) {
+				t.Fatalf("invalid number of timestamps: got=%d, want=%d", len(ts), len(vs))
+			}
+			for i := 1; i < len(ts); i++ {
+				if !ts[i].After(ts[i-1]) {
+					t.Fatalf("timestamps not increasing at %d: %v, %v", i, ts[i-1], ts[i])
+				}
+			}
+			if !got.Start.Equal(t0) {
+				t.Fatalf("invalid start: got=%v, want=%v", got.Start, t0)
+			}
+
+			if len(jcts) != len(tc.jcts) {
+				t.Fatalf("invalid junctions:\ngot= %+v\nwant=%+v", jcts, tc.jcts)
+			}
+			for i := range jcts {
+				if !jcts[i].Time.Equal(tc.jcts[i].Time) {
+					t.Fatalf("invalid junction %d time: got=%v, want=%v", i, jcts[i].Time, tc.jcts[i].Time)
+				}
+				jcts[i].Time = tc.jcts[i].Time
+				if jcts[i] != tc.jcts[i] {
+					t.Fatalf("invalid junction %d:\ngot= %+v\nwant=%+v", i, jcts[i], tc.jcts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMergeContiguousNoGap(t *testing.T) {
+	// two contiguous files merge into the file they were split from, with
+	// no gap nor integrity issue at the junction.
+	t0 := time.Date(2019, 8, 6, 14, 0, 0, 0, time.UTC)
+	want := mergeFile(t0, 0, 100)
+	a, err := want.Slice(0, 60)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	b, err := want.Slice(60, 100)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	b.Start = t0.Add(60 * 20 * time.Millisecond)
+
+	got, jcts, err := Merge([]File{a, b}, WithMask())
+	if err != nil {
+		t.Fatalf("could not merge files: %+v", err)
+	}
+	if jcts[0].Gap != 0 || jcts[0].Missing != 0 || jcts[0].Dropped != 0 {
+		t.Fatalf("invalid junction: %+v", jcts[0])
+	}
+	if !reflect.DeepEqual(got.Cols[0].Data, want.Cols[0].Data) || !reflect.DeepEqual(got.Cols[1].Data, want.Cols[1].Data) {
+		t.Fatalf("merged file differs from the original one")
+	}
+	for i, ok := range got.Mask {
+		if !ok {
+			t.Fatalf("invalid sample %d", i)
+		}
+	}
+	if rep := Check(got); len(rep.Issues) != 0 {
+		t.Fatalf("invalid integrity report: %v", rep.Issues)
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	t0 := time.Date(2019, 8, 6, 14, 0, 0, 0, time.UTC)
+	renamed := mergeFile(t0, 10, 5)
+	renamed.Cols[1].Name = "ACC y"
+
+	resampled := mergeFile(t0, 10, 5)
+	for i, t := range resampled.Cols[0].Data.([]time.Time) {
+		resampled.Cols[0].Data.([]time.Time)[i] = t.Add(time.Duration(i) * 20 * time.Millisecond)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		files []File
+	}{
+		{"no-file", nil},
+		{"empty", []File{mergeFile(t0, 0, 5), {Cols: []Column{{Name: "Time", Data: []time.Time{}}}}}},
+		{"channels", []File{mergeFile(t0, 0, 5), renamed}},
+		{"rate", []File{mergeFile(t0, 0, 5), resampled}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := Merge(tc.files)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+		})
+	}
+}
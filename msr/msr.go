@@ -6,40 +6,68 @@
 package msr
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type File struct {
-	Start time.Time
-	Cols  []Column
+	Creator string // software which produced the file
+	Version string // version of the creator software
+
+	Start    time.Time
+	Location *time.Location // time zone of Start and of the data rows, see WithLocation
+	Cols     []Column
+	Mask     []bool // per-sample validity mask, see WithMask
+}
+
+// Modules returns the serial numbers of the modules recording the channels,
+// in order of first appearance.
+func (f File) Modules() []string {
+	var (
+		ids  []string
+		seen = make(map[string]bool)
+	)
+	for _, col := range f.Cols {
+		if col.SensorID == "" || seen[col.SensorID] {
+			continue
+		}
+		seen[col.SensorID] = true
+		ids = append(ids, col.SensorID)
+	}
+	return ids
 }
 
+// Freq returns the nominal sampling frequency (Hz) of the file, or 0 if it
+// cannot be estimated. See Rate.
 func (f File) Freq() float64 {
-	ts := f.Cols[0].Data.([]time.Time)
-	delta := ts[len(ts)-1].Sub(f.Start)
-	freq := float64(len(ts)) / delta.Seconds()
-	return freq
+	rate, err := f.Rate()
+	if err != nil {
+		return 0
+	}
+	return rate.Nominal
 }
 
+// Axis returns the indices of the samples.
 func (f File) Axis() []float64 {
-	xs := make([]float64, len(f.AccX()))
+	ts, _ := f.Times()
+	xs := make([]float64, len(ts))
 	for i := range xs {
 		xs[i] = float64(i)
 	}
 	return xs
 }
 
+// TimeSeries returns the time (ms) of the samples since the start time.
 func (f File) TimeSeries() []float64 {
-	col := f.Cols[0]
-	if col.Name != "Time" {
+	ts, err := f.Times()
+	if err != nil {
 		return nil
 	}
-	ts := col.Data.([]time.Time)
 	xs := make([]float64, len(ts))
 	for i, t := range ts {
 		xs[i] = float64(t.Sub(f.Start).Milliseconds())
@@ -47,56 +75,151 @@ func (f File) TimeSeries() []float64 {
 	return xs
 }
 
-func (f File) col(name string) (Column, bool) {
-	for _, col := range f.Cols {
-		if col.Name != name {
+// ErrNoChannel is returned when a file has no channel of the requested name.
+var ErrNoChannel = errors.New("no such channel")
+
+// Times returns the timestamps of the samples, from the time column.
+func (f File) Times() ([]time.Time, error) {
+	if len(f.Cols) == 0 || f.Cols[0].Name != "Time" {
+		return nil, fmt.Errorf("no time column")
+	}
+	ts, ok := f.Cols[0].Data.([]time.Time)
+	if !ok {
+		return nil, fmt.Errorf("invalid time column data type %T", f.Cols[0].Data)
+	}
+	return ts, nil
+}
+
+// Channels returns the data channels of the file, i.e. all its columns but
+// the time column.
+func (f File) Channels() []Column {
+	if len(f.Cols) < 2 {
+		return nil
+	}
+	return f.Cols[1:]
+}
+
+// Channel returns the samples and the description of the named data channel.
+// Names are matched case-insensitively, e.g. "ACC x", "TEMP" or "humidity".
+func (f File) Channel(name string) ([]float64, Column, error) {
+	var names []string
+	for _, col := range f.Channels() {
+		if !strings.EqualFold(col.Name, name) {
+			names = append(names, col.Name)
 			continue
 		}
-		return col, true
+		vs, ok := col.Data.([]float64)
+		if !ok {
+			return nil, col, fmt.Errorf("invalid data type %T of channel %q", col.Data, col.Name)
+		}
+		return vs, col, nil
 	}
-	return Column{}, false
+	return nil, Column{}, fmt.Errorf("%w %q (channels: %s)", ErrNoChannel, name, strings.Join(names, ", "))
 }
 
-func (f File) AccX() []float64 {
-	col, ok := f.col("ACC x")
-	if !ok {
-		return nil
+// Select returns a copy of the file holding only the time column and the
+// named data channels, in the provided order.
+// The returned file shares its data with f.
+func (f File) Select(names ...string) (File, error) {
+	if len(f.Cols) == 0 {
+		return f, fmt.Errorf("no time column")
 	}
-	return col.Data.([]float64)
+	out := f
+	out.Cols = []Column{f.Cols[0]}
+	for _, name := range names {
+		_, col, err := f.Channel(name)
+		if err != nil {
+			return f, err
+		}
+		out.Cols = append(out.Cols, col)
+	}
+	return out, nil
 }
 
-func (f File) AccY() []float64 {
-	col, ok := f.col("ACC y")
-	if !ok {
-		return nil
+// Slice returns a copy of the file holding the samples [beg, end).
+// The returned file shares its data with f.
+func (f File) Slice(beg, end int) (File, error) {
+	ts, err := f.Times()
+	if err != nil {
+		return f, err
+	}
+	if beg < 0 || end > len(ts) || beg > end {
+		return f, fmt.Errorf("invalid slice [%d:%d] of %d samples", beg, end, len(ts))
 	}
-	return col.Data.([]float64)
+
+	out := f
+	out.Cols = make([]Column, len(f.Cols))
+	copy(out.Cols, f.Cols)
+	out.Cols[0].Data = ts[beg:end]
+	for i := range out.Cols[1:] {
+		col := &out.Cols[i+1]
+		vs, ok := col.Data.([]float64)
+		if !ok {
+			return f, fmt.Errorf("invalid data type %T of channel %q", col.Data, col.Name)
+		}
+		col.Data = vs[beg:end]
+		col.Filled = nil
+		for _, j := range f.Cols[i+1].Filled {
+			if beg <= j && j < end {
+				col.Filled = append(col.Filled, j-beg)
+			}
+		}
+	}
+	if f.Mask != nil {
+		out.Mask = f.Mask[beg:end]
+	}
+	return out, nil
+}
+
+// AccX returns the samples of the "ACC x" channel, or nil if there is none.
+//
+// Deprecated: use Channel, which reports missing channels.
+func (f File) AccX() []float64 {
+	vs, _, _ := f.Channel("ACC x")
+	return vs
+}
+
+// AccY returns the samples of the "ACC y" channel, or nil if there is none.
+//
+// Deprecated: use Channel, which reports missing channels.
+func (f File) AccY() []float64 {
+	vs, _, _ := f.Channel("ACC y")
+	return vs
 }
 
+// AccZ returns the samples of the "ACC z" channel, or nil if there is none.
+//
+// Deprecated: use Channel, which reports missing channels.
 func (f File) AccZ() []float64 {
-	col, ok := f.col("ACC z")
-	if !ok {
-		return nil
-	}
-	return col.Data.([]float64)
+	vs, _, _ := f.Channel("ACC z")
+	return vs
 }
 
 type Column struct {
 	Name      string // title of the associated data
 	Unit      string // units of the associated data
-	Sensor    string // name of the sensor collecting the data
-	SensorID  string // id of the sensor collecting the data
+	Sensor    string // display name of the sensor collecting the data
+	SensorID  string // serial number of the module collecting the data
 	TimeDelay time.Duration
 	Limits    Limits
 	CalibData CalibData
 	Data      interface{}
+	Filled    []int // indices of the samples forward-filled from empty cells
+
+	Calibrated bool // whether CalibData was applied to Data, see WithCalibration
+	Aligned    bool // whether Data were shifted by TimeDelay onto the time base, see File.Align
 }
 
+// Row is a data row of a MSR stream.
 type Row struct {
-	Time time.Time
-	Data []float64
+	Time   time.Time
+	Data   []float64 // samples of the channels Cols[1:]
+	Filled []bool    // whether each sample was forward-filled from an empty cell
 }
 
+// Limits holds the alarm and recording levels of a channel, from the
+// LIMITS section of a MSR file.
+// Levels not set in the file are NaN.
 type Limits struct {
 	Alarm    float64
 	Recorded float64
@@ -104,6 +227,20 @@ type Limits struct {
 	Limit2   float64
 }
 
+// IsSet returns whether any of the levels is set.
+func (lim Limits) IsSet() bool {
+	for _, v := range []float64{lim.Alarm, lim.Recorded, lim.Limit1, lim.Limit2} {
+		if !math.IsNaN(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// CalibData holds the two-point calibration (X0,Y0)-(X1,Y1) of a channel,
+// from the CALIBRATION section of a MSR file: raw values X are mapped
+// linearly to calibrated values Y.
+// Points not set in the file are NaN.
 type CalibData struct {
 	Info string
 	Date time.Time
@@ -113,153 +250,273 @@ type CalibData struct {
 	Y1   float64
 }
 
-// Parse parses a MSR stream.
-func Parse(r io.Reader) (File, error) {
-	var (
-		err  error
-		cols []Column
-		rows []Row
-		sec  sectionKind
-		msr  File
-	)
-
-	sc := bufio.NewScanner(r)
-	for sc.Scan() {
-		txt := strings.TrimSpace(sc.Text())
-		if len(txt) == 0 {
-			continue
+// IsValid returns whether the calibration defines a linear mapping.
+func (cal CalibData) IsValid() bool {
+	for _, v := range []float64{cal.X0, cal.Y0, cal.X1, cal.Y1} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
 		}
-		if txt[0] == '*' {
-			switch txt {
-			case "*CREATOR":
-				sec = CreatorSection
+	}
+	return cal.X0 != cal.X1
+}
 
-			case "*STARTTIME":
-				sec = StartTimeSection
+// Apply returns the calibrated value of the raw value x.
+func (cal CalibData) Apply(x float64) float64 {
+	return cal.Y0 + (x-cal.X0)*(cal.Y1-cal.Y0)/(cal.X1-cal.X0)
+}
 
-			case "*MODUL":
-				sec = ModuleSection
+// Option configures the parsing or the merging of MSR streams.
+type Option func(cfg *config)
 
-			case "*NAME":
-				sec = NameSection
+type config struct {
+	mask  bool
+	calib bool
+	loc   *time.Location
+	fill  bool // fill gaps between merged files
+	align bool // shift channels by their time delay
+}
 
-			case "*TIMEDELAY":
-				sec = TimeDelaySection
+func newConfig(opts []Option) config {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
 
-			case "*CHANNEL":
-				sec = ChannelSection
+// WithMask instructs Parse and Merge to compute the per-sample validity
+// mask of the MSR stream.
+// A sample is invalid if any of its cells was forward-filled, if its
+// timestamp is duplicated or goes backwards, or if it borders a gap in the
+// timestamp sequence.
+func WithMask() Option {
+	return func(cfg *config) {
+		cfg.mask = true
+	}
+}
 
-			case "*UNIT":
-				sec = UnitSection
+// WithCalibration instructs Parse and Reader to apply the two-point calibration of the
+// CALIBRATION section to the data of the channels with a valid calibration.
+func WithCalibration() Option {
+	return func(cfg *config) {
+		cfg.calib = true
+	}
+}
 
-			case "*LIMITS":
-				sec = LimitsSection
+// WithLocation sets the time zone of the start time and of the data rows of
+// the MSR stream, overriding the one detected from the STARTTIME section.
+// Without zone in the file, times are in UTC.
+func WithLocation(loc *time.Location) Option {
+	return func(cfg *config) {
+		cfg.loc = loc
+	}
+}
 
-			case "*CALIBRATION":
-				sec = CalibrationSection
+// Parse parses a MSR stream.
+func Parse(r io.Reader, opts ...Option) (File, error) {
+	rr, err := NewReader(r, opts...)
+	if err != nil {
+		return File{}, err
+	}
 
-			case "*DATA":
-				sec = DataSection
-			}
-			continue
+	msr := rr.Header()
+	for {
+		blk, err := rr.ReadBlock(parseBlock)
+		if err == io.EOF {
+			break
 		}
+		if err != nil {
+			return msr, err
+		}
+		msr.Cols[0].Data = append(msr.Cols[0].Data.([]time.Time), blk.Times...)
+		for i, vs := range blk.Data {
+			col := &msr.Cols[i+1]
+			col.Data = append(col.Data.([]float64), vs...)
+			col.Filled = append(col.Filled, blk.Filled[i]...)
+		}
+	}
 
-		tokens := strings.Split(txt, ";")
-		switch sec {
-		case CreatorSection:
-		case StartTimeSection:
-			start, err := time.Parse("2006-01-02;15:04:05;", txt)
-			if err != nil {
-				return msr, fmt.Errorf("could not parse start-time %q: %w", txt, err)
-			}
-			msr.Start = start
-
-		case ModuleSection:
-			cols = make([]Column, len(tokens))
-			for i, tok := range tokens {
-				cols[i].Sensor = tok
-				switch i {
-				case 0:
-					cols[i].Data = []time.Time{}
-				default:
-					cols[i].Data = []float64{}
-				}
-			}
+	if rr.cfg.align {
+		msr, err = msr.Align()
+		if err != nil {
+			return msr, err
+		}
+	}
+	if rr.cfg.mask {
+		msr.Mask = msr.mask()
+	}
+	return msr, nil
+}
 
-		case NameSection:
-
-		case TimeDelaySection:
-			for i, tok := range tokens {
-				if i > 0 {
-					delay, err := time.ParseDuration(tok + tokens[0])
-					if err != nil {
-						return msr, fmt.Errorf("could not parse #%d-th time-delay %q: %w", i, txt, err)
-					}
-					cols[i].TimeDelay = delay
-				}
-			}
+// parseBlock is the number of data rows read at a time by Parse.
+const parseBlock = 4096
 
-		case ChannelSection:
-			for i, tok := range tokens {
-				if tok == "TIME" {
-					tok = "Time"
-				}
-				cols[i].Name = tok
-			}
+// creator splits the description of the creator software, on the first row
+// of the CREATOR section, into its name and version, e.g.
+// "MSR ReportGenerator 5.1.08".
+func creator(txt string) (name, version string) {
+	i := strings.LastIndex(txt, " ")
+	if i < 0 {
+		return txt, ""
+	}
+	v := txt[i+1:]
+	if v == "" || v[0] < '0' || v[0] > '9' {
+		return txt, ""
+	}
+	return txt[:i], v
+}
+
+// ParseZone parses a time zone: UTC, a UTC offset (e.g. +02:00, +0200,
+// UTC+2 or GMT-05:30), Local, or an IANA time zone name (e.g. Europe/Paris).
+func ParseZone(v string) (*time.Location, error) {
+	v = strings.TrimSpace(v)
+	switch strings.ToUpper(v) {
+	case "", "UTC", "GMT", "Z":
+		return time.UTC, nil
+	case "LOCAL":
+		return time.Local, nil
+	}
 
-		case UnitSection:
-			for i, tok := range tokens {
-				if i == 0 {
-					continue
-				}
-				cols[i].Unit = tok
+	off := strings.TrimPrefix(strings.TrimPrefix(strings.ToUpper(v), "UTC"), "GMT")
+	if off != "" && (off[0] == '+' || off[0] == '-') {
+		sign := 1
+		if off[0] == '-' {
+			sign = -1
+		}
+		hm := strings.Replace(off[1:], ":", "", 1)
+		var h, m int
+		var err error
+		switch len(hm) {
+		case 1, 2:
+			h, err = strconv.Atoi(hm)
+		case 4:
+			h, err = strconv.Atoi(hm[:2])
+			if err == nil {
+				m, err = strconv.Atoi(hm[2:])
 			}
+		default:
+			err = fmt.Errorf("invalid UTC offset")
+		}
+		if err != nil || h > 14 || m > 59 {
+			return nil, fmt.Errorf("invalid UTC offset %q", v)
+		}
+		return time.FixedZone(v, sign*(h*3600+m*60)), nil
+	}
+
+	return time.LoadLocation(v)
+}
+
+// parseLimits parses the row-th row of the LIMITS section.
+// Each row holds a level for each channel, after a label naming the level
+// (Alarm, Recorded, Limit1 or Limit2).
+// Rows without a known label are the levels in that order.
+func parseLimits(cols []Column, tokens []string, row int) error {
+	for i, tok := range tokens[1:] {
+		if i+1 >= len(cols) || tok == "" {
+			continue
+		}
+		lim := &cols[i+1].Limits
+		var dst *float64
+		switch label(tokens[0], row, "alarm", "recorded", "limit1", "limit2") {
+		case "alarm":
+			dst = &lim.Alarm
+		case "recorded":
+			dst = &lim.Recorded
+		case "limit1":
+			dst = &lim.Limit1
+		case "limit2":
+			dst = &lim.Limit2
+		default:
+			return nil
+		}
+		v, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse #%d-th limit: %w", i+1, err)
+		}
+		*dst = v
+	}
+	return nil
+}
 
-		case LimitsSection:
-			// TODO(sbinet)
-		case CalibrationSection:
-			// TODO(sbinet)
-		case DataSection:
-			var row Row
-			row.Time, err = time.Parse("2006-01-02 15:04:05.999", tokens[0])
+// parseCalib parses the row-th row of the CALIBRATION section.
+// Each row holds a calibration field for each channel, after a label
+// naming the field (Info, Date, X0, Y0, X1 or Y1).
+// Rows without a known label are the fields in that order.
+func parseCalib(cols []Column, tokens []string, row int) error {
+	for i, tok := range tokens[1:] {
+		if i+1 >= len(cols) || tok == "" {
+			continue
+		}
+		cal := &cols[i+1].CalibData
+		var dst *float64
+		switch label(tokens[0], row, "info", "date", "x0", "y0", "x1", "y1") {
+		case "info":
+			cal.Info = tok
+			continue
+		case "date":
+			date, err := parseDate(tok)
 			if err != nil {
-				return msr, fmt.Errorf("could not parse data row[%d] %q: %w", len(rows), txt, err)
+				return fmt.Errorf("could not parse #%d-th calibration date: %w", i+1, err)
 			}
-			cols[0].Data = append(cols[0].Data.([]time.Time), row.Time)
-			row.Data = make([]float64, len(tokens)-1)
-			for i, tok := range tokens[1:] {
-				vs := cols[i+1].Data.([]float64)
-				switch tok {
-				case "":
-					switch len(vs) {
-					case 0:
-						row.Data[i] = 0.0
-					default:
-						row.Data[i] = vs[len(vs)-1]
-					}
-				default:
-					val, err := strconv.ParseFloat(tok, 64)
-					if err != nil {
-						return msr, fmt.Errorf("could not parse float %q in row %d: %w", tok, len(rows), err)
-					}
-					row.Data[i] = val
-				}
-				cols[i+1].Data = append(vs, row.Data[i])
-			}
-			rows = append(rows, row)
+			cal.Date = date
+			continue
+		case "x0":
+			dst = &cal.X0
+		case "y0":
+			dst = &cal.Y0
+		case "x1":
+			dst = &cal.X1
+		case "y1":
+			dst = &cal.Y1
+		default:
+			return nil
 		}
+		v, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse #%d-th calibration point: %w", i+1, err)
+		}
+		*dst = v
 	}
+	return nil
+}
 
-	err = sc.Err()
-	if err == io.EOF {
-		err = nil
+// label returns the normalized label of the row-th row of a section, or
+// the row-th of the known labels if the row is not labelled with one.
+func label(tok string, row int, known ...string) string {
+	tok = strings.ToLower(strings.NewReplacer(" ", "", "_", "", "-", "").Replace(tok))
+	if tok == "record" {
+		tok = "recorded"
 	}
-	if err != nil {
-		return msr, fmt.Errorf("could not scan MSR file: %w", err)
+	for _, v := range known {
+		if tok == v {
+			return v
+		}
 	}
+	if row < len(known) {
+		return known[row]
+	}
+	return ""
+}
 
-	msr.Cols = cols
-	return msr, nil
+// parseDate parses a calibration date.
+func parseDate(v string) (time.Time, error) {
+	var err error
+	for _, layout := range []string{
+		"2006-01-02",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		time.RFC3339,
+		"02.01.2006",
+		"02.01.2006 15:04:05",
+		"02.01.2006 15:04",
+	} {
+		var date time.Time
+		date, err = time.Parse(layout, v)
+		if err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, err
 }
 
 type sectionKind byte
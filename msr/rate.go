@@ -0,0 +1,185 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Rate describes the sampling rate of a MSR file.
+type Rate struct {
+	Nominal  float64       // nominal sampling frequency (Hz), from the median sampling interval
+	Measured float64       // measured sampling frequency (Hz), from the mean of the inlier intervals
+	Interval time.Duration // median sampling interval
+	Jitter   time.Duration // standard deviation of the inlier intervals
+	Outliers int           // number of intervals rejected as outliers (gaps, duplicates, ...)
+	Changes  []RateChange  // detected changes of the sampling rate
+}
+
+// RateChange is a change of the sampling rate.
+type RateChange struct {
+	Index int       // index of the first sample of the block at the new rate
+	Time  time.Time // timestamp of that sample
+	From  float64   // previous sampling frequency (Hz)
+	To    float64   // new sampling frequency (Hz)
+}
+
+// outlierMADs is the number of (normal-consistent) median absolute
+// deviations beyond which a sampling interval is an outlier.
+const outlierMADs = 5
+
+// quantizedSteps is the number of timestamp resolution steps below which
+// the median sampling interval is too coarse to give the nominal rate.
+const quantizedSteps = 10
+
+// Rate estimates the sampling rate of the file from the intervals between
+// its timestamps.
+//
+// The nominal rate is the inverse of the median interval.
+// Intervals further than outlierMADs median absolute deviations (and more
+// than 10%) away from the median are rejected as outliers; the measured rate
+// and the jitter are the inverse of the mean and the standard deviation of
+// the remaining intervals.
+//
+// When the median interval spans less than quantizedSteps steps of the
+// timestamp resolution (e.g. 1600 Hz with millisecond timestamps, where
+// intervals are 0 or 1 ms), intervals within one step of the median are
+// inliers, and the nominal rate is the measured one: the number of inlier
+// intervals over their time span.
+//
+// Rate returns an error if no interval is an inlier.
+func (f File) Rate() (Rate, error) {
+	var rate Rate
+	ts, err := f.Times()
+	if err != nil {
+		return rate, err
+	}
+	if len(ts) < 2 {
+		return rate, fmt.Errorf("not enough samples (%d) to estimate the sampling rate", len(ts))
+	}
+
+	dts := make([]time.Duration, len(ts)-1)
+	for i := range dts {
+		dts[i] = ts[i+1].Sub(ts[i])
+	}
+	var (
+		res       = resolution(ts)
+		med       = median(dts)
+		quantized = med < quantizedSteps*res
+	)
+	if med < 0 || (med == 0 && !quantized) {
+		return rate, fmt.Errorf("non-increasing timestamps (median interval=%v)", med)
+	}
+
+	var inlier func(dt time.Duration) bool
+	switch {
+	case quantized:
+		inlier = func(dt time.Duration) bool {
+			return dt >= 0 && dt >= med-res && dt <= med+res
+		}
+	default:
+		devs := make([]time.Duration, len(dts))
+		for i, dt := range dts {
+			devs[i] = dt - med
+			if devs[i] < 0 {
+				devs[i] = -devs[i]
+			}
+		}
+		tol := math.Max(outlierMADs*1.4826*float64(median(devs)), 0.1*float64(med))
+		inlier = func(dt time.Duration) bool {
+			return dt > 0 && math.Abs(float64(dt-med)) <= tol
+		}
+	}
+
+	var (
+		n      = 0
+		mu, m2 float64 // running mean and sum of squared deviations (Welford)
+	)
+	for _, dt := range dts {
+		if !inlier(dt) {
+			rate.Outliers++
+			continue
+		}
+		n++
+		d := float64(dt) - mu
+		mu += d / float64(n)
+		m2 += d * (float64(dt) - mu)
+	}
+	if n == 0 || mu <= 0 {
+		return rate, fmt.Errorf("no regular sampling interval (median interval=%v, %d outlier(s))", med, rate.Outliers)
+	}
+
+	rate.Interval = med
+	rate.Nominal = 1 / med.Seconds()
+	rate.Measured = 1 / time.Duration(mu).Seconds()
+	if quantized {
+		rate.Interval = time.Duration(mu)
+		rate.Nominal = rate.Measured
+	}
+	if n > 1 {
+		rate.Jitter = time.Duration(math.Sqrt(m2 / float64(n-1)))
+	}
+	rate.Changes = rateChanges(ts, localIntervals(ts))
+	return rate, nil
+}
+
+// resolution returns the resolution of the provided timestamps: the
+// coarsest of 1s, 1ms and 1µs all the timestamps are multiples of, relative
+// to the first one.
+func resolution(ts []time.Time) time.Duration {
+	for _, res := range []time.Duration{time.Second, time.Millisecond, time.Microsecond} {
+		ok := true
+		for _, t := range ts[1:] {
+			if t.Sub(ts[0])%res != 0 {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return res
+		}
+	}
+	return time.Nanosecond
+}
+
+// rateChanges returns the changes of the sampling rate, detected from the
+// local sampling intervals.
+func rateChanges(ts []time.Time, local []time.Duration) []RateChange {
+	var (
+		changes []RateChange
+		cur     time.Duration // sampling interval of the current segment
+	)
+	for i := 0; i < len(local); i += rateBlock {
+		dt := local[i]
+		if dt <= 0 {
+			continue
+		}
+		switch {
+		case cur == 0:
+			cur = dt
+		case math.Abs(float64(dt-cur)) > 0.1*float64(cur):
+			changes = append(changes, RateChange{
+				Index: i,
+				Time:  ts[i],
+				From:  1 / cur.Seconds(),
+				To:    1 / dt.Seconds(),
+			})
+			cur = dt
+		}
+	}
+	return changes
+}
+
+// median returns the median of the provided durations, sorting them in place.
+func median(dts []time.Duration) time.Duration {
+	if len(dts) == 0 {
+		return 0
+	}
+	sort.Slice(dts, func(i, j int) bool { return dts[i] < dts[j] })
+	return dts[len(dts)/2]
+}
@@ -0,0 +1,125 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// timeFile returns a file holding only the provided timestamps.
+func timeFile(ts []time.Time) File {
+	return File{Cols: []Column{{Name: "Time", Data: ts}}}
+}
+
+// regularTimes returns n timestamps sampled at freq (Hz), truncated to the
+// provided resolution.
+func regularTimes(n int, freq float64, res time.Duration) []time.Time {
+	var (
+		t0 = time.Date(2019, 8, 6, 14, 0, 0, 0, time.UTC)
+		ts = make([]time.Time, n)
+	)
+	for i := range ts {
+		dt := time.Duration(math.Round(float64(i) / freq * float64(time.Second)))
+		ts[i] = t0.Add(dt.Truncate(res))
+	}
+	return ts
+}
+
+func TestRate(t *testing.T) {
+	var (
+		gap = func() []time.Time {
+			ts := regularTimes(1000, 50, time.Millisecond)
+			return append(ts[:500], ts[510:]...)
+		}()
+		dup = func() []time.Time {
+			ts := regularTimes(1000, 50, time.Millisecond)
+			ts[501] = ts[500]
+			return ts
+		}()
+		change = func() []time.Time {
+			ts := regularTimes(512, 50, time.Millisecond)
+			end := ts[len(ts)-1]
+			for _, t := range regularTimes(1025, 100, time.Millisecond)[1:] {
+				ts = append(ts, end.Add(t.Sub(ts[0])))
+			}
+			return ts
+		}()
+	)
+
+	for _, tc := range []struct {
+		name     string
+		ts       []time.Time
+		nominal  float64
+		measured float64
+		tol      float64 // relative tolerance
+		outliers int
+		changes  []float64 // new rates
+	}{
+		{"50Hz", regularTimes(1000, 50, time.Millisecond), 50, 50, 1e-9, 0, nil},
+		{"50Hz-gap", gap, 50, 50, 1e-9, 1, nil},
+		{"50Hz-duplicate", dup, 50, 50, 1e-9, 2, nil},
+		{"1kHz", regularTimes(1000, 1000, time.Millisecond), 1000, 1000, 1e-9, 0, nil},
+		// millisecond timestamps of a 1600 Hz sampling are 0 or 1 ms apart.
+		{"1600Hz-ms", regularTimes(4096, 1600, time.Millisecond), 1600, 1600, 1e-3, 0, nil},
+		{"1600Hz-µs", regularTimes(4096, 1600, time.Microsecond), 1600, 1600, 1e-3, 0, nil},
+		// intervals of the minority rate are outliers.
+		{"50Hz-100Hz", change, 100, 100, 1e-9, 511, []float64{100}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rate, err := timeFile(tc.ts).Rate()
+			if err != nil {
+				t.Fatalf("could not estimate rate: %+v", err)
+			}
+			if got := rate.Nominal; math.Abs(got-tc.nominal) > tc.tol*tc.nominal {
+				t.Fatalf("invalid nominal rate: got=%v, want=%v", got, tc.nominal)
+			}
+			if got := rate.Measured; math.Abs(got-tc.measured) > tc.tol*tc.measured {
+				t.Fatalf("invalid measured rate: got=%v, want=%v", got, tc.measured)
+			}
+			if math.IsInf(rate.Measured, 0) || math.IsNaN(rate.Measured) {
+				t.Fatalf("invalid measured rate: %v", rate.Measured)
+			}
+			if got, want := rate.Outliers, tc.outliers; got != want {
+				t.Fatalf("invalid number of outliers: got=%d, want=%d", got, want)
+			}
+			if got, want := len(rate.Changes), len(tc.changes); got != want {
+				t.Fatalf("invalid number of rate changes: got=%d (%+v), want=%d", got, rate.Changes, want)
+			}
+			for i, c := range rate.Changes {
+				if math.Abs(c.To-tc.changes[i]) > 0.01*tc.changes[i] {
+					t.Fatalf("invalid rate change %d: got=%v Hz, want=%v Hz", i, c.To, tc.changes[i])
+				}
+			}
+			if got, want := timeFile(tc.ts).Freq(), rate.Nominal; got != want {
+				t.Fatalf("invalid frequency: got=%v, want=%v", got, want)
+			}
+		})
+	}
+}
+
+func TestRateErrors(t *testing.T) {
+	t0 := time.Date(2019, 8, 6, 14, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name string
+		f    File
+	}{
+		{"no-time", File{}},
+		{"one-sample", timeFile([]time.Time{t0})},
+		{"same-time", timeFile([]time.Time{t0, t0, t0, t0})},
+		{"backwards", timeFile([]time.Time{t0, t0.Add(-time.Second), t0.Add(-2 * time.Second)})},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.f.Rate()
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got := tc.f.Freq(); got != 0 {
+				t.Fatalf("invalid frequency: got=%v, want=0", got)
+			}
+		})
+	}
+}
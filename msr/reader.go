@@ -0,0 +1,348 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reader reads a MSR stream, one data row or one block of data rows at a
+// time, without holding the whole stream in memory.
+type Reader struct {
+	sc  *bufio.Scanner
+	cfg config
+	hdr File
+
+	row  int       // index of the next data row
+	last []float64 // last raw value of each channel, for forward-filling
+	err  error     // sticky error
+}
+
+// NewReader returns a Reader reading from r.
+// NewReader parses the header sections of the stream, up to the DATA
+// section.
+func NewReader(r io.Reader, opts ...Option) (*Reader, error) {
+	rr := &Reader{
+		sc:  bufio.NewScanner(r),
+		cfg: newConfig(opts),
+	}
+	err := rr.header()
+	if err != nil {
+		return nil, err
+	}
+	return rr, nil
+}
+
+// Header returns the header of the MSR stream.
+// Its columns hold no data.
+func (r *Reader) Header() File {
+	f := r.hdr
+	f.Cols = make([]Column, len(r.hdr.Cols))
+	copy(f.Cols, r.hdr.Cols)
+	return f
+}
+
+// header parses the header sections of the MSR stream.
+func (r *Reader) header() error {
+	var (
+		err  error
+		cols []Column
+		sec  sectionKind
+		srow int // index of the row within the current section
+		msr  = &r.hdr
+	)
+
+loop:
+	for r.sc.Scan() {
+		txt := strings.TrimSpace(r.sc.Text())
+		if len(txt) == 0 {
+			continue
+		}
+		if txt[0] == '*' {
+			srow = 0
+			switch txt {
+			case "*CREATOR":
+				sec = CreatorSection
+
+			case "*STARTTIME":
+				sec = StartTimeSection
+
+			case "*MODUL":
+				sec = ModuleSection
+
+			case "*NAME":
+				sec = NameSection
+
+			case "*TIMEDELAY":
+				sec = TimeDelaySection
+
+			case "*CHANNEL":
+				sec = ChannelSection
+
+			case "*UNIT":
+				sec = UnitSection
+
+			case "*LIMITS":
+				sec = LimitsSection
+
+			case "*CALIBRATION":
+				sec = CalibrationSection
+
+			case "*DATA":
+				break loop
+			}
+			continue
+		}
+
+		tokens := strings.Split(txt, ";")
+		srow++
+		switch sec {
+		case CreatorSection:
+			if srow == 1 {
+				msr.Creator, msr.Version = creator(txt)
+			}
+
+		case StartTimeSection:
+			if len(tokens) < 2 {
+				return fmt.Errorf("could not parse start-time %q", txt)
+			}
+			msr.Location = r.cfg.loc
+			if msr.Location == nil && len(tokens) > 2 && tokens[2] != "" {
+				msr.Location, err = ParseZone(tokens[2])
+				if err != nil {
+					return fmt.Errorf("could not parse start-time zone %q: %w", txt, err)
+				}
+			}
+			if msr.Location == nil {
+				msr.Location = time.UTC
+			}
+			start, err := time.ParseInLocation("2006-01-02 15:04:05", tokens[0]+" "+tokens[1], msr.Location)
+			if err != nil {
+				return fmt.Errorf("could not parse start-time %q: %w", txt, err)
+			}
+			msr.Start = start
+
+		case ModuleSection:
+			cols = make([]Column, len(tokens))
+			for i, tok := range tokens {
+				cols[i].SensorID = tok
+				cols[i].Limits = Limits{math.NaN(), math.NaN(), math.NaN(), math.NaN()}
+				cols[i].CalibData = CalibData{X0: math.NaN(), Y0: math.NaN(), X1: math.NaN(), Y1: math.NaN()}
+				switch i {
+				case 0:
+					cols[i].Data = []time.Time{}
+				default:
+					cols[i].Data = []float64{}
+				}
+			}
+
+		case NameSection:
+			for i, tok := range tokens {
+				if i == 0 || i >= len(cols) {
+					continue
+				}
+				cols[i].Sensor = tok
+			}
+
+		case TimeDelaySection:
+			for i, tok := range tokens {
+				if i > 0 && i < len(cols) {
+					delay, err := time.ParseDuration(tok + tokens[0])
+					if err != nil {
+						return fmt.Errorf("could not parse #%d-th time-delay %q: %w", i, txt, err)
+					}
+					cols[i].TimeDelay = delay
+				}
+			}
+
+		case ChannelSection:
+			for i, tok := range tokens {
+				if i >= len(cols) {
+					continue
+				}
+				if tok == "TIME" {
+					tok = "Time"
+				}
+				cols[i].Name = tok
+			}
+
+		case UnitSection:
+			for i, tok := range tokens {
+				if i == 0 || i >= len(cols) {
+					continue
+				}
+				cols[i].Unit = tok
+			}
+
+		case LimitsSection:
+			err = parseLimits(cols, tokens, srow-1)
+			if err != nil {
+				return fmt.Errorf("could not parse limits %q: %w", txt, err)
+			}
+
+		case CalibrationSection:
+			err = parseCalib(cols, tokens, srow-1)
+			if err != nil {
+				return fmt.Errorf("could not parse calibration %q: %w", txt, err)
+			}
+		}
+	}
+
+	err = r.sc.Err()
+	if err != nil {
+		return fmt.Errorf("could not scan MSR file: %w", err)
+	}
+
+	if msr.Location == nil {
+		msr.Location = time.UTC
+		if r.cfg.loc != nil {
+			msr.Location = r.cfg.loc
+		}
+	}
+	if r.cfg.calib {
+		for i := 1; i < len(cols); i++ {
+			cols[i].Calibrated = cols[i].CalibData.IsValid()
+		}
+	}
+	msr.Cols = cols
+	if len(cols) > 0 {
+		r.last = make([]float64, len(cols)-1)
+	}
+	return nil
+}
+
+// Next returns the next data row of the MSR stream.
+// Empty cells are forward-filled with the previous value of their channel,
+// or 0 if there is none.
+// Next returns io.EOF when no more rows are available.
+func (r *Reader) Next() (Row, error) {
+	if r.err != nil {
+		return Row{}, r.err
+	}
+	row, err := r.next()
+	if err != nil {
+		r.err = err
+	}
+	return row, err
+}
+
+func (r *Reader) next() (Row, error) {
+	for r.sc.Scan() {
+		txt := strings.TrimSpace(r.sc.Text())
+		if len(txt) == 0 {
+			continue
+		}
+		return r.parse(txt)
+	}
+
+	err := r.sc.Err()
+	if err != nil {
+		return Row{}, fmt.Errorf("could not scan MSR file: %w", err)
+	}
+	return Row{}, io.EOF
+}
+
+// parse parses a data row.
+func (r *Reader) parse(txt string) (Row, error) {
+	var (
+		err    error
+		row    Row
+		cols   = r.hdr.Cols
+		tokens = strings.Split(txt, ";")
+	)
+	if len(cols) == 0 {
+		return row, fmt.Errorf("could not parse data row[%d] %q: no channel", r.row, txt)
+	}
+	for len(tokens) > len(cols) && tokens[len(tokens)-1] == "" {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) > len(cols) {
+		return row, fmt.Errorf("could not parse data row[%d] %q: %d cells for %d channels", r.row, txt, len(tokens), len(cols))
+	}
+
+	row.Time, err = time.ParseInLocation("2006-01-02 15:04:05.999", tokens[0], r.hdr.Location)
+	if err != nil {
+		return row, fmt.Errorf("could not parse data row[%d] %q: %w", r.row, txt, err)
+	}
+	row.Data = make([]float64, len(cols)-1)
+	row.Filled = make([]bool, len(cols)-1)
+	for i := range row.Data {
+		tok := ""
+		if i+1 < len(tokens) {
+			tok = tokens[i+1]
+		}
+		switch tok {
+		case "":
+			row.Filled[i] = true
+		default:
+			val, err := strconv.ParseFloat(tok, 64)
+			if err != nil {
+				return row, fmt.Errorf("could not parse float %q in row %d: %w", tok, r.row, err)
+			}
+			r.last[i] = val
+		}
+		row.Data[i] = r.last[i]
+		if col := cols[i+1]; col.Calibrated {
+			row.Data[i] = col.CalibData.Apply(row.Data[i])
+		}
+	}
+	r.row++
+	return row, nil
+}
+
+// Block is a block of consecutive data rows of a MSR stream, stored by
+// channel.
+type Block struct {
+	Beg    int         // index of the first row of the block in the stream
+	Times  []time.Time // timestamps of the rows
+	Data   [][]float64 // samples of the channels Cols[1:]
+	Filled [][]int     // per channel, stream indices of the forward-filled samples
+}
+
+// Len returns the number of rows of the block.
+func (blk Block) Len() int { return len(blk.Times) }
+
+// ReadBlock returns the next block of at most n data rows of the MSR stream.
+// ReadBlock returns io.EOF when no more rows are available.
+func (r *Reader) ReadBlock(n int) (Block, error) {
+	if n <= 0 {
+		return Block{}, fmt.Errorf("invalid block size %d", n)
+	}
+	blk := Block{
+		Beg:    r.row,
+		Times:  make([]time.Time, 0, n),
+		Data:   make([][]float64, len(r.last)),
+		Filled: make([][]int, len(r.last)),
+	}
+	for i := range blk.Data {
+		blk.Data[i] = make([]float64, 0, n)
+	}
+	for blk.Len() < n {
+		row, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return blk, err
+		}
+		for i, v := range row.Data {
+			blk.Data[i] = append(blk.Data[i], v)
+			if row.Filled[i] {
+				blk.Filled[i] = append(blk.Filled[i], blk.Beg+blk.Len())
+			}
+		}
+		blk.Times = append(blk.Times, row.Time)
+	}
+	if blk.Len() == 0 {
+		return blk, io.EOF
+	}
+	return blk, nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampleMSR is a MSR stream with a non-UTC time zone, limits and calibration
+// points not set for every channel, and empty cells.
+const sampleMSR = `*CREATOR
+MSR ReportGenerator 5.1.08
+*STARTTIME
+2019-08-06;14:00:00;+02:00
+*MODUL
+;340939;340939;340939
+*NAME
+;MSR145;MSR145;MSR145
+*TIMEDELAY
+s;0;0;0
+*CHANNEL
+TIME;ACC x;ACC y;TEMP
+*UNIT
+;g;g;°C
+*LIMITS
+Alarm;2;;
+Recorded;;;
+Limit1;1.5;;40
+Limit2;;;
+*CALIBRATION
+Info;factory;spare;
+Date;2019-01-02;;
+X0;0;;
+Y0;0;;
+X1;1;;
+Y1;2;;
+*DATA
+2019-08-06 14:00:00.000;0.5;;21.5
+2019-08-06 14:00:00.020;0.25;0.125;
+2019-08-06 14:00:00.040;;-0.125;
+2019-08-06 14:00:00.060;-0.5;;22
+2019-08-06 14:00:00.080;0.75;0.5;22
+`
+
+func TestReaderNext(t *testing.T) {
+	r, err := NewReader(strings.NewReader(sampleMSR), WithCalibration())
+	if err != nil {
+		t.Fatalf("could not create reader: %+v", err)
+	}
+
+	hdr := r.Header()
+	if got, want := len(hdr.Cols), 4; got != want {
+		t.Fatalf("invalid number of columns: got=%d, want=%d", got, want)
+	}
+	if got := hdr.Cols[0].Data.([]time.Time); len(got) != 0 {
+		t.Fatalf("header holds data: %v", got)
+	}
+
+	loc := time.FixedZone("+02:00", 2*3600)
+	for i, want := range []Row{
+		{
+			Time:   time.Date(2019, 8, 6, 14, 0, 0, 0, loc),
+			Data:   []float64{1, 0, 21.5},
+			Filled: []bool{false, true, false},
+		},
+		{
+			Time:   time.Date(2019, 8, 6, 14, 0, 0, 20e6, loc),
+			Data:   []float64{0.5, 0.125, 21.5},
+			Filled: []bool{false, false, true},
+		},
+		{
+			Time:   time.Date(2019, 8, 6, 14, 0, 0, 40e6, loc),
+			Data:   []float64{0.5, -0.125, 21.5},
+			Filled: []bool{true, false, true},
+		},
+		{
+			Time:   time.Date(2019, 8, 6, 14, 0, 0, 60e6, loc),
+			Data:   []float64{-1, -0.125, 22},
+			Filled: []bool{false, true, false},
+		},
+		{
+			Time:   time.Date(2019, 8, 6, 14, 0, 0, 80e6, loc),
+			Data:   []float64{1.5, 0.5, 22},
+			Filled: []bool{false, false, false},
+		},
+	} {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("could not read row %d: %+v", i, err)
+		}
+		if !got.Time.Equal(want.Time) {
+			t.Fatalf("invalid time of row %d: got=%v, want=%v", i, got.Time, want.Time)
+		}
+		if !reflect.DeepEqual(got.Data, want.Data) || !reflect.DeepEqual(got.Filled, want.Filled) {
+			t.Fatalf("invalid row %d:\ngot= %v %v\nwant=%v %v", i, got.Data, got.Filled, want.Data, want.Filled)
+		}
+	}
+
+	_, err = r.Next()
+	if err != io.EOF {
+		t.Fatalf("invalid end of stream: got=%v, want=%v", err, io.EOF)
+	}
+}
+
+func TestReaderReadBlock(t *testing.T) {
+	want, err := Parse(strings.NewReader(sampleMSR))
+	if err != nil {
+		t.Fatalf("could not parse file: %+v", err)
+	}
+
+	for _, n := range []int{1, 2, 3, 5, 10} {
+		r, err := NewReader(strings.NewReader(sampleMSR))
+		if err != nil {
+			t.Fatalf("n=%d: could not create reader: %+v", n, err)
+		}
+
+		var (
+			got  = r.Header()
+			nblk = 0
+		)
+		for {
+			blk, err := r.ReadBlock(n)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("n=%d: could not read block %d: %+v", n, nblk, err)
+			}
+			if blk.Len() > n {
+				t.Fatalf("n=%d: block %d too long: %d", n, nblk, blk.Len())
+			}
+			if got, want := blk.Beg, nblk*n; got != want {
+				t.Fatalf("n=%d: invalid start of block %d: got=%d, want=%d", n, nblk, got, want)
+			}
+			got.Cols[0].Data = append(got.Cols[0].Data.([]time.Time), blk.Times...)
+			for i, vs := range blk.Data {
+				col := &got.Cols[i+1]
+				col.Data = append(col.Data.([]float64), vs...)
+				col.Filled = append(col.Filled, blk.Filled[i]...)
+			}
+			nblk++
+		}
+		if want := (5 + n - 1) / n; nblk != want {
+			t.Fatalf("n=%d: invalid number of blocks: got=%d, want=%d", n, nblk, want)
+		}
+
+		for i := range want.Cols {
+			if !reflect.DeepEqual(got.Cols[i].Data, want.Cols[i].Data) {
+				t.Fatalf("n=%d: invalid column %q data:\ngot= %v\nwant=%v", n, want.Cols[i].Name, got.Cols[i].Data, want.Cols[i].Data)
+			}
+			if !reflect.DeepEqual(got.Cols[i].Filled, want.Cols[i].Filled) {
+				t.Fatalf("n=%d: invalid column %q filled cells: got=%v, want=%v", n, want.Cols[i].Name, got.Cols[i].Filled, want.Cols[i].Filled)
+			}
+		}
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	r, err := NewReader(strings.NewReader(sampleMSR))
+	if err != nil {
+		t.Fatalf("could not create reader: %+v", err)
+	}
+	_, err = r.ReadBlock(0)
+	if err == nil {
+		t.Fatalf("expected an error for an empty block")
+	}
+
+	bad := strings.Replace(sampleMSR, "-0.125", "abc", 1)
+	r, err = NewReader(strings.NewReader(bad))
+	if err != nil {
+		t.Fatalf("could not create reader: %+v", err)
+	}
+	blk, err := r.ReadBlock(10)
+	if err == nil {
+		t.Fatalf("expected an error for an invalid cell")
+	}
+	if got, want := blk.Len(), 2; got != want {
+		t.Fatalf("invalid number of rows before the error: got=%d, want=%d", got, want)
+	}
+	_, err2 := r.Next()
+	if err2 != err {
+		t.Fatalf("error is not sticky: got=%v, want=%v", err2, err)
+	}
+}
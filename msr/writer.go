@@ -0,0 +1,268 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encoder writes a MSR stream, header first and then one data row at a time.
+type Encoder struct {
+	w    *bufio.Writer
+	hdr  File
+	buf  []byte
+	last []float64 // last written value of each channel
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+// Write writes the MSR file f to w.
+func Write(w io.Writer, f File) error {
+	return NewEncoder(w).Encode(f)
+}
+
+// Encode writes the header and the data rows of the MSR file f, and flushes
+// the stream.
+func (enc *Encoder) Encode(f File) error {
+	err := enc.WriteHeader(f)
+	if err != nil {
+		return err
+	}
+
+	ts, err := f.Times()
+	if err != nil {
+		return fmt.Errorf("could not encode MSR file: %w", err)
+	}
+	var (
+		chs    = f.Channels()
+		vs     = make([][]float64, len(chs))
+		filled = make([]int, len(chs)) // index of the next filled sample of each channel
+		row    = Row{
+			Data:   make([]float64, len(chs)),
+			Filled: make([]bool, len(chs)),
+		}
+	)
+	for i, col := range chs {
+		data, ok := col.Data.([]float64)
+		if !ok {
+			return fmt.Errorf("could not encode MSR file: invalid data type %T of channel %q", col.Data, col.Name)
+		}
+		if len(data) != len(ts) {
+			return fmt.Errorf("could not encode MSR file: channel %q has %d samples, want %d", col.Name, len(data), len(ts))
+		}
+		vs[i] = data
+	}
+
+	for j, t := range ts {
+		row.Time = t
+		for i, col := range chs {
+			row.Data[i] = vs[i][j]
+			row.Filled[i] = false
+			for filled[i] < len(col.Filled) && col.Filled[filled[i]] <= j {
+				row.Filled[i] = col.Filled[filled[i]] == j
+				filled[i]++
+			}
+		}
+		err = enc.WriteRow(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return enc.Flush()
+}
+
+// WriteHeader writes the header sections of the MSR file f, up to the DATA
+// section. The data of f are not written.
+//
+// The calibration of the channels whose data were calibrated, and the time
+// delay of the channels whose data were aligned, are not written, so that
+// they are not applied twice.
+func (enc *Encoder) WriteHeader(f File) error {
+	if len(f.Cols) == 0 {
+		return fmt.Errorf("could not encode MSR header: no column")
+	}
+	enc.hdr = f
+	enc.last = make([]float64, len(f.Cols)-1)
+	if enc.hdr.Location == nil {
+		enc.hdr.Location = time.UTC
+	}
+
+	var (
+		cols = f.Cols
+		loc  = enc.hdr.Location
+		row  = func(tok string, vs func(col Column) string) {
+			enc.w.WriteString(tok)
+			for _, col := range cols[1:] {
+				enc.w.WriteByte(';')
+				enc.w.WriteString(vs(col))
+			}
+			enc.w.WriteByte('\n')
+		}
+	)
+
+	enc.w.WriteString("*CREATOR\n")
+	enc.w.WriteString(strings.TrimSpace(f.Creator + " " + f.Version))
+	enc.w.WriteString("\n")
+
+	zone := loc.String()
+	switch _, err := ParseZone(zone); {
+	case loc == time.UTC:
+		zone = ""
+	case err != nil || zone == "":
+		zone = f.Start.In(loc).Format("-07:00")
+	}
+	enc.w.WriteString("*STARTTIME\n")
+	enc.w.WriteString(f.Start.In(loc).Format("2006-01-02;15:04:05") + ";" + zone + "\n")
+
+	enc.w.WriteString("*MODUL\n")
+	row(cols[0].SensorID, func(col Column) string { return col.SensorID })
+
+	enc.w.WriteString("*NAME\n")
+	row(cols[0].Sensor, func(col Column) string { return col.Sensor })
+
+	enc.w.WriteString("*TIMEDELAY\n")
+	row("s", func(col Column) string {
+		if col.Aligned {
+			return "0"
+		}
+		return strconv.FormatFloat(col.TimeDelay.Seconds(), 'f', -1, 64)
+	})
+
+	enc.w.WriteString("*CHANNEL\n")
+	row("TIME", func(col Column) string { return col.Name })
+
+	enc.w.WriteString("*UNIT\n")
+	row("", func(col Column) string { return col.Unit })
+
+	var (
+		limits = false
+		calib  = false
+	)
+	for _, col := range cols[1:] {
+		limits = limits || col.Limits.IsSet()
+		calib = calib || (!col.Calibrated && col.CalibData.isSet())
+	}
+
+	if limits {
+		enc.w.WriteString("*LIMITS\n")
+		for _, lvl := range []struct {
+			label string
+			value func(lim Limits) float64
+		}{
+			{"Alarm", func(lim Limits) float64 { return lim.Alarm }},
+			{"Recorded", func(lim Limits) float64 { return lim.Recorded }},
+			{"Limit1", func(lim Limits) float64 { return lim.Limit1 }},
+			{"Limit2", func(lim Limits) float64 { return lim.Limit2 }},
+		} {
+			row(lvl.label, func(col Column) string { return formatFloat(lvl.value(col.Limits)) })
+		}
+	}
+
+	if calib {
+		enc.w.WriteString("*CALIBRATION\n")
+		for _, field := range []struct {
+			label string
+			value func(cal CalibData) string
+		}{
+			{"Info", func(cal CalibData) string { return cal.Info }},
+			{"Date", func(cal CalibData) string {
+				if cal.Date.IsZero() {
+					return ""
+				}
+				return cal.Date.Format("2006-01-02")
+			}},
+			{"X0", func(cal CalibData) string { return formatFloat(cal.X0) }},
+			{"Y0", func(cal CalibData) string { return formatFloat(cal.Y0) }},
+			{"X1", func(cal CalibData) string { return formatFloat(cal.X1) }},
+			{"Y1", func(cal CalibData) string { return formatFloat(cal.Y1) }},
+		} {
+			row(field.label, func(col Column) string {
+				if col.Calibrated {
+					return ""
+				}
+				return field.value(col.CalibData)
+			})
+		}
+	}
+
+	_, err := enc.w.WriteString("*DATA\n")
+	if err != nil {
+		return fmt.Errorf("could not encode MSR header: %w", err)
+	}
+	return nil
+}
+
+// WriteRow writes a data row, after the header.
+// Forward-filled samples are written as empty cells, unless forward-filling
+// would not restore their value.
+// Timestamps are written with the millisecond resolution of the MSR format,
+// rounded to the nearest millisecond.
+func (enc *Encoder) WriteRow(row Row) error {
+	if len(enc.hdr.Cols) == 0 {
+		return fmt.Errorf("could not encode MSR row: no header")
+	}
+	if n := len(enc.hdr.Cols) - 1; len(row.Data) != n {
+		return fmt.Errorf("could not encode MSR row: %d samples for %d channels", len(row.Data), n)
+	}
+
+	buf := row.Time.Round(time.Millisecond).In(enc.hdr.Location).AppendFormat(enc.buf[:0], "2006-01-02 15:04:05.000")
+	for i, v := range row.Data {
+		buf = append(buf, ';')
+		if i < len(row.Filled) && row.Filled[i] && v == enc.last[i] {
+			continue
+		}
+		buf = strconv.AppendFloat(buf, v, 'f', -1, 64)
+		enc.last[i] = v
+	}
+	buf = append(buf, '\n')
+	enc.buf = buf
+
+	_, err := enc.w.Write(buf)
+	if err != nil {
+		return fmt.Errorf("could not encode MSR row: %w", err)
+	}
+	return nil
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (enc *Encoder) Flush() error {
+	err := enc.w.Flush()
+	if err != nil {
+		return fmt.Errorf("could not flush MSR stream: %w", err)
+	}
+	return nil
+}
+
+// isSet returns whether any field of the calibration is set.
+func (cal CalibData) isSet() bool {
+	if cal.Info != "" || !cal.Date.IsZero() {
+		return true
+	}
+	for _, v := range []float64{cal.X0, cal.Y0, cal.X1, cal.Y1} {
+		if !math.IsNaN(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatFloat formats a level or a calibration point, NaN values being
+// written as empty cells.
+func formatFloat(v float64) string {
+	if math.IsNaN(v) {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,176 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		accx []float64
+	}{
+		{"raw", nil, []float64{0.5, 0.25, 0.25, -0.5, 0.75}},
+		{"calibrated", []Option{WithCalibration()}, []float64{1, 0.5, 0.5, -1, 1.5}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			want, err := Parse(strings.NewReader(sampleMSR), tc.opts...)
+			if err != nil {
+				t.Fatalf("could not parse input: %+v", err)
+			}
+
+			buf := new(bytes.Buffer)
+			err = Write(buf, want)
+			if err != nil {
+				t.Fatalf("could not write file: %+v", err)
+			}
+
+			got, err := Parse(bytes.NewReader(buf.Bytes()), tc.opts...)
+			if err != nil {
+				t.Fatalf("could not parse output: %+v\n%s", err, buf.Bytes())
+			}
+
+			if got.Creator != want.Creator || got.Version != want.Version {
+				t.Fatalf("invalid creator: got=%q %q, want=%q %q", got.Creator, got.Version, want.Creator, want.Version)
+			}
+			if !got.Start.Equal(want.Start) || got.Location.String() != "+02:00" {
+				t.Fatalf("invalid start: got=%v (%v), want=%v (+02:00)", got.Start, got.Location, want.Start)
+			}
+			if _, off := got.Start.Zone(); off != 2*3600 {
+				t.Fatalf("invalid start zone offset: got=%d, want=%d", off, 2*3600)
+			}
+
+			gts, _ := got.Times()
+			wts, _ := want.Times()
+			if len(gts) != len(wts) {
+				t.Fatalf("invalid number of samples: got=%d, want=%d", len(gts), len(wts))
+			}
+			for i := range wts {
+				if !gts[i].Equal(wts[i]) {
+					t.Fatalf("invalid time[%d]: got=%v, want=%v", i, gts[i], wts[i])
+				}
+			}
+
+			if len(got.Cols) != len(want.Cols) {
+				t.Fatalf("invalid number of columns: got=%d, want=%d", len(got.Cols), len(want.Cols))
+			}
+			for i, wcol := range want.Cols[1:] {
+				gcol := got.Cols[i+1]
+				if gcol.Name != wcol.Name || gcol.Unit != wcol.Unit || gcol.Sensor != wcol.Sensor || gcol.SensorID != wcol.SensorID {
+					t.Fatalf("invalid channel[%d] description: got=%q [%s], want=%q [%s]", i, gcol.Name, gcol.Unit, wcol.Name, wcol.Unit)
+				}
+				if !reflect.DeepEqual(gcol.Data, wcol.Data) {
+					t.Fatalf("invalid channel %q data:\ngot= %v\nwant=%v", wcol.Name, gcol.Data, wcol.Data)
+				}
+				if !reflect.DeepEqual(gcol.Filled, wcol.Filled) {
+					t.Fatalf("invalid channel %q filled cells: got=%v, want=%v", wcol.Name, gcol.Filled, wcol.Filled)
+				}
+				if !sameLimits(gcol.Limits, wcol.Limits) {
+					t.Fatalf("invalid channel %q limits: got=%+v, want=%+v", wcol.Name, gcol.Limits, wcol.Limits)
+				}
+
+				// a calibrated channel is written calibrated, without its
+				// calibration, so that it is not applied twice.
+				wcal := wcol.CalibData
+				if wcol.Calibrated {
+					wcal = CalibData{X0: math.NaN(), Y0: math.NaN(), X1: math.NaN(), Y1: math.NaN()}
+				}
+				if !sameCalib(gcol.CalibData, wcal) {
+					t.Fatalf("invalid channel %q calibration: got=%+v, want=%+v", wcol.Name, gcol.CalibData, wcal)
+				}
+			}
+
+			x, _, _ := got.Channel("ACC x")
+			if !reflect.DeepEqual(x, tc.accx) {
+				t.Fatalf("invalid ACC x data: got=%v, want=%v", x, tc.accx)
+			}
+		})
+	}
+}
+
+func TestWriteRowMillisecond(t *testing.T) {
+	var (
+		start = time.Date(2019, 8, 6, 14, 0, 0, 0, time.UTC)
+		hdr   = File{
+			Start: start,
+			Cols: []Column{
+				{Name: "Time"},
+				{
+					Name:      "ACC x",
+					Limits:    Limits{math.NaN(), math.NaN(), math.NaN(), math.NaN()},
+					CalibData: CalibData{X0: math.NaN(), Y0: math.NaN(), X1: math.NaN(), Y1: math.NaN()},
+				},
+			},
+		}
+	)
+	for _, tc := range []struct {
+		dt   time.Duration
+		want string
+	}{
+		{0, "2019-08-06 14:00:00.000;1\n"},
+		{20 * time.Millisecond, "2019-08-06 14:00:00.020;1\n"},
+		{625 * time.Microsecond, "2019-08-06 14:00:00.001;1\n"},
+		{1400 * time.Microsecond, "2019-08-06 14:00:00.001;1\n"},
+		{999600 * time.Microsecond, "2019-08-06 14:00:01.000;1\n"},
+	} {
+		t.Run(tc.dt.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			enc := NewEncoder(buf)
+			err := enc.WriteHeader(hdr)
+			if err != nil {
+				t.Fatalf("could not write header: %+v", err)
+			}
+			buf.Reset()
+			enc.w.Reset(buf)
+
+			err = enc.WriteRow(Row{Time: start.Add(tc.dt), Data: []float64{1}})
+			if err != nil {
+				t.Fatalf("could not write row: %+v", err)
+			}
+			err = enc.Flush()
+			if err != nil {
+				t.Fatalf("could not flush: %+v", err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("invalid row: got=%q, want=%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func sameLimits(a, b Limits) bool {
+	return sameFloats(
+		[]float64{a.Alarm, a.Recorded, a.Limit1, a.Limit2},
+		[]float64{b.Alarm, b.Recorded, b.Limit1, b.Limit2},
+	)
+}
+
+func sameCalib(a, b CalibData) bool {
+	return a.Info == b.Info && a.Date.Equal(b.Date) && sameFloats(
+		[]float64{a.X0, a.Y0, a.X1, a.Y1},
+		[]float64{b.X0, b.Y0, b.X1, b.Y1},
+	)
+}
+
+// sameFloats returns whether the two slices are equal, NaN values being
+// equal to each other.
+func sameFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] && !(math.IsNaN(a[i]) && math.IsNaN(b[i])) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,421 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	"gonum.org/v1/gonum/dsp/fourier"
+	"gonum.org/v1/gonum/floats"
+	lapack "gonum.org/v1/gonum/lapack/gonum"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Multitaper holds the result of a chunked multitaper spectral analysis.
+//
+// Power spectral densities are estimated with Thomson's method, using
+// adaptively weighted DPSS tapers.
+// For each chunk and frequency, a F-test for the presence of a line component
+// is also computed.
+type Multitaper struct {
+	Data struct {
+		X []float64
+		Y []float64
+	}
+	Ts     []float64   // chunked time series
+	Freqs  []float64   // frequencies
+	Coeffs [][]float64 // one-sided power spectral densities
+	FTest  [][]float64 // F-test statistics for line components
+	PValue [][]float64 // p-values of the F-test statistics
+
+	Name   string
+	Chunks int
+	NW     float64   // time-bandwidth product
+	Ratios []float64 // concentration ratios of the DPSS tapers
+	Scale  float64   // Frequency scale
+}
+
+// ChunkedMultitaper computes the multitaper power spectral density of ys,
+// by chunks of chunksz samples, using k DPSS tapers with the nw time-bandwidth
+// product.
+// If k is not positive, 2*nw-1 tapers are used.
+// A trailing chunk with less than chunksz samples is discarded, unless it is
+// the only chunk.
+//
+// ChunkedMultitaper returns an error if chunksz or nw are not positive, if
+// nw is not less than half the chunk size, or if no taper can be used.
+func ChunkedMultitaper(fname string, chunksz int, xs, ys []float64, freq, nw float64, k int) (Multitaper, error) {
+	if chunksz <= 0 {
+		return Multitaper{}, fmt.Errorf("fouracc: invalid chunk size %d", chunksz)
+	}
+	if nw <= 0 {
+		return Multitaper{}, fmt.Errorf("fouracc: invalid time-bandwidth product NW=%v", nw)
+	}
+	scale := 1.0
+	if freq > 0 {
+		scale = freq
+	}
+	if chunksz > len(ys) {
+		chunksz = len(ys)
+	}
+	if k <= 0 {
+		k = int(2*nw) - 1
+	}
+	if k <= 0 {
+		return Multitaper{}, fmt.Errorf("fouracc: no DPSS taper for NW=%v (want NW >= 1 or an explicit number of tapers)", nw)
+	}
+
+	mtm := Multitaper{
+		Name:   fname,
+		Chunks: chunksz,
+		NW:     nw,
+		Scale:  freq,
+	}
+	mtm.Data.X = xs
+	mtm.Data.Y = ys
+	if chunksz <= 0 {
+		return mtm, nil
+	}
+
+	tapers, ratios, err := DPSS(chunksz, nw, k)
+	if err != nil {
+		return mtm, err
+	}
+	var (
+		fft = fourier.NewFFT(chunksz)
+		nf  = chunksz/2 + 1
+	)
+	mtm.Ratios = ratios
+	mtm.Freqs = make([]float64, nf)
+	for i := range mtm.Freqs {
+		mtm.Freqs[i] = fft.Freq(i) * scale
+	}
+
+	for i := 0; i+chunksz <= len(ys); i += chunksz {
+		psd, ftest := multitaper(fft, tapers, ratios, ys[i:i+chunksz], scale)
+		pval := make([]float64, len(ftest))
+		dist := distuv.F{D1: 2, D2: float64(2*len(tapers) - 2)}
+		for j, v := range ftest {
+			if math.IsNaN(v) {
+				// no F-test with a single taper.
+				pval[j] = math.NaN()
+				continue
+			}
+			pval[j] = dist.Survival(v)
+		}
+		mtm.Ts = append(mtm.Ts, xs[i])
+		mtm.Coeffs = append(mtm.Coeffs, psd)
+		mtm.FTest = append(mtm.FTest, ftest)
+		mtm.PValue = append(mtm.PValue, pval)
+	}
+
+	return mtm, nil
+}
+
+// multitaper returns the adaptively weighted one-sided power spectral density
+// of ys and the F-test statistics for line components.
+func multitaper(fft *fourier.FFT, tapers [][]float64, ratios, ys []float64, scale float64) (psd, ftest []float64) {
+	var (
+		n    = len(ys)
+		k    = len(tapers)
+		nf   = n/2 + 1
+		dt   = 1 / scale
+		wrk  = make([]float64, n)
+		eig  = make([][]complex128, k) // eigencoefficients
+		sk   = make([][]float64, k)    // eigenspectra
+		mean = floats.Sum(ys) / float64(n)
+	)
+
+	for i, taper := range tapers {
+		for j, v := range ys {
+			wrk[j] = (v - mean) * taper[j]
+		}
+		eig[i] = fft.Coefficients(nil, wrk)
+		sk[i] = make([]float64, nf)
+		for j, c := range eig[i] {
+			v := cmplx.Abs(c)
+			sk[i][j] = v * v * dt
+		}
+	}
+
+	// adaptive weighting, following Thomson (1982) and
+	// Percival & Walden (1993), section 7.4.
+	var (
+		sigma2 = 0.0
+		bk     = make([]float64, k)
+	)
+	for _, v := range ys {
+		sigma2 += (v - mean) * (v - mean)
+	}
+	sigma2 *= dt / float64(n) // variance, as a two-sided PSD integral.
+
+	psd = make([]float64, nf)
+	for j := range psd {
+		switch k {
+		case 1:
+			psd[j] = sk[0][j]
+			continue
+		default:
+			psd[j] = 0.5 * (sk[0][j] + sk[1][j])
+		}
+		for iter := 0; iter < 100; iter++ {
+			var num, den float64
+			for i := range bk {
+				b := math.Sqrt(ratios[i]) * psd[j] / (ratios[i]*psd[j] + (1-ratios[i])*sigma2)
+				bk[i] = b * b
+				num += bk[i] * sk[i][j]
+				den += bk[i]
+			}
+			v := num / den
+			if math.Abs(v-psd[j]) <= 1e-10*math.Abs(psd[j]) {
+				psd[j] = v
+				break
+			}
+			psd[j] = v
+		}
+	}
+	// one-sided PSD.
+	for j := 1; j < nf; j++ {
+		if n%2 == 0 && j == nf-1 {
+			continue
+		}
+		psd[j] *= 2
+	}
+
+	// F-test for line components, Thomson (1982).
+	u0 := make([]float64, k)
+	sum2 := 0.0
+	for i, taper := range tapers {
+		u0[i] = floats.Sum(taper)
+		sum2 += u0[i] * u0[i]
+	}
+	ftest = make([]float64, nf)
+	for j := range ftest {
+		if k < 2 || sum2 == 0 {
+			ftest[j] = math.NaN()
+			continue
+		}
+		var mu complex128
+		for i := range tapers {
+			mu += complex(u0[i], 0) * eig[i][j]
+		}
+		mu /= complex(sum2, 0)
+		res := 0.0
+		for i := range tapers {
+			v := cmplx.Abs(eig[i][j] - mu*complex(u0[i], 0))
+			res += v * v
+		}
+		v := cmplx.Abs(mu)
+		ftest[j] = float64(k-1) * v * v * sum2 / res
+	}
+
+	return psd, ftest
+}
+
+// DPSS returns the first k discrete prolate spheroidal sequences (Slepian
+// tapers) of length n, for the nw time-bandwidth product, together with
+// their concentration ratios.
+// Tapers are normalized to unit energy.
+// At most n tapers are returned.
+//
+// Tapers are computed as the eigenvectors of the tridiagonal matrix that
+// commutes with the concentration problem, see Percival & Walden (1993),
+// section 8.3.
+//
+// DPSS returns an error if n or k are not positive, or if nw is not within
+// (0, n/2).
+func DPSS(n int, nw float64, k int) (tapers [][]float64, ratios []float64, err error) {
+	if n <= 0 {
+		return nil, nil, fmt.Errorf("fouracc: invalid DPSS length %d", n)
+	}
+	if k <= 0 {
+		return nil, nil, fmt.Errorf("fouracc: invalid number of DPSS tapers %d", k)
+	}
+	if nw <= 0 || nw >= float64(n)/2 {
+		return nil, nil, fmt.Errorf("fouracc: invalid time-bandwidth product NW=%v for n=%d", nw, n)
+	}
+	if k > n {
+		k = n
+	}
+
+	var (
+		w    = nw / float64(n)
+		diag = make([]float64, n)
+		off  = make([]float64, n-1)
+	)
+	for i := range diag {
+		v := float64(n-1-2*i) / 2
+		diag[i] = v * v * math.Cos(2*math.Pi*w)
+	}
+	for i := range off {
+		off[i] = float64((i+1)*(n-i-1)) / 2
+	}
+
+	tapers = make([][]float64, k)
+	ratios = make([]float64, k)
+	for i := range tapers {
+		lambda := tridiagEigen(diag, off, n-1-i)
+		tapers[i] = tridiagEigvec(diag, off, lambda, tapers[:i])
+		// sign convention: symmetric tapers have a positive mean,
+		// anti-symmetric tapers start with a positive lobe.
+		var s float64
+		switch i % 2 {
+		case 0:
+			s = floats.Sum(tapers[i])
+		default:
+			for j := 0; j < n/2; j++ {
+				s += float64(n-1-2*j) * tapers[i][j]
+			}
+		}
+		if s < 0 {
+			floats.Scale(-1, tapers[i])
+		}
+		ratios[i] = concentration(tapers[i], w)
+	}
+
+	return tapers, ratios, nil
+}
+
+// tridiagEigen returns the j-th smallest eigenvalue of the symmetric
+// tridiagonal matrix with the provided diagonal and off-diagonal,
+// using Sturm sequence bisection.
+func tridiagEigen(diag, off []float64, j int) float64 {
+	lo, hi := math.Inf(+1), math.Inf(-1)
+	for i, d := range diag {
+		r := 0.0
+		if i > 0 {
+			r += math.Abs(off[i-1])
+		}
+		if i < len(off) {
+			r += math.Abs(off[i])
+		}
+		lo = math.Min(lo, d-r)
+		hi = math.Max(hi, d+r)
+	}
+
+	// count returns the number of eigenvalues smaller than x.
+	count := func(x float64) int {
+		var (
+			n = 0
+			q = 1.0
+		)
+		for i, d := range diag {
+			switch i {
+			case 0:
+				q = d - x
+			default:
+				if q == 0 {
+					q = 1e-300
+				}
+				q = d - x - off[i-1]*off[i-1]/q
+			}
+			if q < 0 {
+				n++
+			}
+		}
+		return n
+	}
+
+	tol := 4 * (math.Nextafter(1, 2) - 1) * math.Max(math.Abs(lo), math.Abs(hi))
+	for hi-lo > tol {
+		mid := 0.5 * (lo + hi)
+		if mid == lo || mid == hi {
+			break
+		}
+		if count(mid) > j {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return 0.5 * (lo + hi)
+}
+
+// tridiagEigvec returns the normalized eigenvector associated with the
+// eigenvalue lambda of the symmetric tridiagonal matrix with the provided
+// diagonal and off-diagonal, using inverse iteration.
+// The eigenvector is kept orthogonal to the already computed eigenvectors.
+func tridiagEigvec(diag, off []float64, lambda float64, prev [][]float64) []float64 {
+	var (
+		n     = len(diag)
+		impl  = lapack.Implementation{}
+		shift = lambda + 1e-10*math.Max(1, math.Abs(lambda))
+		dl    = make([]float64, n-1)
+		d     = make([]float64, n)
+		du    = make([]float64, n-1)
+		v     = make([]float64, n)
+	)
+	for i := range v {
+		v[i] = 1 / math.Sqrt(float64(n))
+	}
+	for iter := 0; iter < 5; iter++ {
+		copy(dl, off)
+		copy(du, off)
+		for i, x := range diag {
+			d[i] = x - shift
+		}
+		if !impl.Dgtsv(n, 1, dl, d, du, v, 1) {
+			break
+		}
+		for _, u := range prev {
+			floats.AddScaled(v, -floats.Dot(u, v), u)
+		}
+		floats.Scale(1/floats.Norm(v, 2), v)
+	}
+	return v
+}
+
+// concentration returns the fraction of the energy of the taper v
+// within the [-w,w] frequency band.
+func concentration(v []float64, w float64) float64 {
+	n := len(v)
+	// autocorrelation of v, computed with a zero-padded FFT.
+	var (
+		m   = 2 * n
+		fft = fourier.NewFFT(m)
+		wrk = make([]float64, m)
+	)
+	copy(wrk, v)
+	cs := fft.Coefficients(nil, wrk)
+	for i, c := range cs {
+		a := cmplx.Abs(c)
+		cs[i] = complex(a*a, 0)
+	}
+	acf := fft.Sequence(nil, cs)
+
+	sum := 2 * w * acf[0] / float64(m)
+	for lag := 1; lag < n; lag++ {
+		sinc := math.Sin(2*math.Pi*w*float64(lag)) / (math.Pi * float64(lag))
+		sum += 2 * sinc * acf[lag] / float64(m)
+	}
+	return sum
+}
+
+// Significance returns the significance level of the F-test flagging line
+// components: 1/N for chunks of N samples, i.e. about one false detection
+// per spectrum, following Thomson (1982).
+func (mtm Multitaper) Significance() float64 { return 1 / float64(mtm.Chunks) }
+
+func (mtm Multitaper) Dims() (c, r int) {
+	if len(mtm.Coeffs) == 0 {
+		return 0, 0
+	}
+	return len(mtm.Coeffs), len(mtm.Coeffs[0])
+}
+
+func (mtm Multitaper) Z(c, r int) float64 { return mtm.Coeffs[c][r] }
+func (mtm Multitaper) X(c int) float64    { return mtm.Ts[c] }
+func (mtm Multitaper) Y(r int) float64    { return mtm.Freqs[r] }
+
+func (mtm Multitaper) Title() string {
+	if mtm.Scale > 0 {
+		return fmt.Sprintf("%s -- mtm chunks=%d NW=%v K=%d (freq=%v Hz)", mtm.Name, mtm.Chunks, mtm.NW, len(mtm.Ratios), mtm.Scale)
+	}
+	return fmt.Sprintf("%s -- mtm chunks=%d NW=%v K=%d", mtm.Name, mtm.Chunks, mtm.NW, len(mtm.Ratios))
+}
+
+func (mtm Multitaper) Series() (xs, ys []float64) { return mtm.Data.X, mtm.Data.Y }
@@ -0,0 +1,197 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+func TestDPSS(t *testing.T) {
+	for _, tc := range []struct {
+		n  int
+		nw float64
+		k  int
+	}{
+		{n: 64, nw: 2, k: 3},
+		{n: 128, nw: 3, k: 5},
+		{n: 256, nw: 4, k: 7},
+		{n: 255, nw: 4, k: 8},
+	} {
+		tapers, ratios, err := DPSS(tc.n, tc.nw, tc.k)
+		if err != nil {
+			t.Fatalf("n=%d, nw=%v: %+v", tc.n, tc.nw, err)
+		}
+		if len(tapers) != tc.k || len(ratios) != tc.k {
+			t.Fatalf("n=%d, nw=%v: invalid number of tapers: got=%d, want=%d", tc.n, tc.nw, len(tapers), tc.k)
+		}
+
+		w := tc.nw / float64(tc.n)
+		for i, vi := range tapers {
+			// orthonormality.
+			for j, vj := range tapers {
+				want := 0.0
+				if i == j {
+					want = 1
+				}
+				if got := floats.Dot(vi, vj); math.Abs(got-want) > 1e-9 {
+					t.Fatalf("n=%d, nw=%v: invalid <v%d,v%d>: got=%v, want=%v", tc.n, tc.nw, i, j, got, want)
+				}
+			}
+
+			// even tapers are symmetric, odd tapers anti-symmetric.
+			sign := 1.0
+			if i%2 == 1 {
+				sign = -1
+			}
+			for j := range vi {
+				if got, want := vi[tc.n-1-j], sign*vi[j]; math.Abs(got-want) > 1e-9 {
+					t.Fatalf("n=%d, nw=%v: invalid symmetry of taper %d at %d: got=%v, want=%v", tc.n, tc.nw, i, j, got, want)
+				}
+			}
+
+			// tapers are the eigenvectors of the sinc kernel of the
+			// concentration problem, with the ratios as eigenvalues.
+			for j := range vi {
+				var got float64
+				for m, v := range vi {
+					kern := 2 * w
+					if m != j {
+						d := float64(j - m)
+						kern = math.Sin(2*math.Pi*w*d) / (math.Pi * d)
+					}
+					got += kern * v
+				}
+				if want := ratios[i] * vi[j]; math.Abs(got-want) > 1e-8 {
+					t.Fatalf("n=%d, nw=%v: taper %d is not a concentration eigenvector at %d: got=%v, want=%v", tc.n, tc.nw, i, j, got, want)
+				}
+			}
+
+			if i > 0 && ratios[i] > ratios[i-1] {
+				t.Fatalf("n=%d, nw=%v: ratios not decreasing: %v", tc.n, tc.nw, ratios)
+			}
+			if i < int(2*tc.nw)-1 && ratios[i] < 0.9 {
+				t.Fatalf("n=%d, nw=%v: taper %d badly concentrated: %v", tc.n, tc.nw, i, ratios[i])
+			}
+		}
+	}
+}
+
+func TestDPSSErrors(t *testing.T) {
+	for _, tc := range []struct {
+		n  int
+		nw float64
+		k  int
+	}{
+		{n: 0, nw: 2, k: 3},
+		{n: 64, nw: 2, k: 0},
+		{n: 64, nw: 0, k: 3},
+		{n: 64, nw: -1, k: 3},
+		{n: 64, nw: 32, k: 3},
+	} {
+		_, _, err := DPSS(tc.n, tc.nw, tc.k)
+		if err == nil {
+			t.Fatalf("n=%d, nw=%v, k=%d: expected an error", tc.n, tc.nw, tc.k)
+		}
+	}
+}
+
+func TestChunkedMultitaper(t *testing.T) {
+	const (
+		n    = 1024
+		freq = 100.0
+		amp  = 1.0
+	)
+	for _, tc := range []struct {
+		chunksz int
+		f0      float64 // frequency of the line component
+	}{
+		{chunksz: 256, f0: 12.5},
+		{chunksz: 256, f0: 31.25},
+		{chunksz: 512, f0: 20.3125},
+	} {
+		var (
+			rnd = rand.New(rand.NewSource(1234))
+			xs  = make([]float64, n)
+			ys  = make([]float64, n)
+		)
+		for i := range xs {
+			xs[i] = float64(i)
+			ys[i] = amp*math.Sin(2*math.Pi*tc.f0*float64(i)/freq) + 0.1*rnd.NormFloat64()
+		}
+
+		mtm, err := ChunkedMultitaper("sine", tc.chunksz, xs, ys, freq, 4, 0)
+		if err != nil {
+			t.Fatalf("f0=%v: %+v", tc.f0, err)
+		}
+		if got, want := len(mtm.Coeffs), n/tc.chunksz; got != want {
+			t.Fatalf("f0=%v: invalid number of chunks: got=%d, want=%d", tc.f0, got, want)
+		}
+		if got, want := len(mtm.Ratios), 7; got != want {
+			t.Fatalf("f0=%v: invalid number of tapers: got=%d, want=%d", tc.f0, got, want)
+		}
+
+		df := freq / float64(tc.chunksz)
+		for c, psd := range mtm.Coeffs {
+			// the PSD of a line is flat over the resolution bandwidth.
+			if got := mtm.Freqs[floats.MaxIdx(psd)]; math.Abs(got-tc.f0) > mtm.NW*df {
+				t.Fatalf("f0=%v: invalid PSD peak of chunk %d: got=%v Hz", tc.f0, c, got)
+			}
+
+			// the PSD integrates to the variance of the signal.
+			if got, want := floats.Sum(psd)*df, amp*amp/2+0.01; math.Abs(got-want) > 0.1*want {
+				t.Fatalf("f0=%v: invalid PSD integral of chunk %d: got=%v, want=%v", tc.f0, c, got, want)
+			}
+
+			peak := floats.MaxIdx(mtm.FTest[c][1:]) + 1
+			if got := mtm.Freqs[peak]; math.Abs(got-tc.f0) > df/2 {
+				t.Fatalf("f0=%v: invalid F-test peak of chunk %d: got=%v Hz", tc.f0, c, got)
+			}
+			if p := mtm.PValue[c][peak]; !(p < mtm.Significance()) {
+				t.Fatalf("f0=%v: line of chunk %d not significant: p=%v", tc.f0, c, p)
+			}
+		}
+	}
+}
+
+func TestChunkedMultitaperErrors(t *testing.T) {
+	ys := make([]float64, 256)
+	for _, tc := range []struct {
+		chunksz int
+		nw      float64
+		k       int
+	}{
+		{chunksz: 0, nw: 4, k: 0},
+		{chunksz: 128, nw: 0, k: 0},
+		{chunksz: 128, nw: 0.5, k: 0},
+		{chunksz: 128, nw: 64, k: 3},
+	} {
+		_, err := ChunkedMultitaper("zero", tc.chunksz, ys, ys, 100, tc.nw, tc.k)
+		if err == nil {
+			t.Fatalf("chunksz=%d, nw=%v, k=%d: expected an error", tc.chunksz, tc.nw, tc.k)
+		}
+	}
+}
+
+func TestChunkedMultitaperSingleTaper(t *testing.T) {
+	ys := make([]float64, 256)
+	for i := range ys {
+		ys[i] = math.Sin(2 * math.Pi * 10 * float64(i) / 100)
+	}
+	mtm, err := ChunkedMultitaper("sine", 128, ys, ys, 100, 1, 1)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	for c := range mtm.FTest {
+		for j, v := range mtm.FTest[c] {
+			if !math.IsNaN(v) || !math.IsNaN(mtm.PValue[c][j]) {
+				t.Fatalf("invalid F-test with a single taper: got=%v (p=%v), want=NaN", v, mtm.PValue[c][j])
+			}
+		}
+	}
+}
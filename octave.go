@@ -0,0 +1,200 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	"gonum.org/v1/gonum/dsp/fourier"
+)
+
+// octaveRatio is the base-10 octave frequency ratio of IEC 61260-1.
+var octaveRatio = math.Pow(10, 0.3)
+
+// Bands is a set of fractional-octave frequency bands.
+type Bands struct {
+	Fraction int       // bandwidth designator b: 1/b octave bands
+	Centers  []float64 // exact mid-band frequencies (Hz)
+	Lo       []float64 // lower band-edge frequencies (Hz)
+	Hi       []float64 // upper band-edge frequencies (Hz)
+}
+
+// OctaveBands returns the 1/b octave bands, following the IEC 61260-1
+// base-10 system, whose mid-band frequencies lie within [lo,hi].
+// Supported bandwidth designators are 1, 3, 6 and 12.
+func OctaveBands(b int, lo, hi float64) (Bands, error) {
+	switch b {
+	case 1, 3, 6, 12:
+		// ok
+	default:
+		return Bands{}, fmt.Errorf("fouracc: invalid octave fraction 1/%d", b)
+	}
+	if lo <= 0 || hi < lo {
+		return Bands{}, fmt.Errorf("fouracc: invalid octave bands range [%v, %v]", lo, hi)
+	}
+
+	var (
+		bands = Bands{Fraction: b}
+		fb    = float64(b)
+		edge  = math.Pow(octaveRatio, 1/(2*fb))
+		// mid-band frequency of the x-th band, relative to 1 kHz.
+		center = func(x int) float64 {
+			if b%2 == 1 {
+				return 1000 * math.Pow(octaveRatio, float64(x)/fb)
+			}
+			return 1000 * math.Pow(octaveRatio, float64(2*x+1)/(2*fb))
+		}
+		index = func(f float64) float64 {
+			return fb * math.Log(f/1000) / math.Log(octaveRatio)
+		}
+	)
+
+	for x := int(math.Floor(index(lo))) - 1; x <= int(math.Ceil(index(hi)))+1; x++ {
+		fm := center(x)
+		if fm < lo*(1-1e-9) || fm > hi*(1+1e-9) {
+			continue
+		}
+		bands.Centers = append(bands.Centers, fm)
+		bands.Lo = append(bands.Lo, fm/edge)
+		bands.Hi = append(bands.Hi, fm*edge)
+	}
+	return bands, nil
+}
+
+// MeanSquare returns the one-sided mean-square spectrum of ys, i.e. the
+// contribution of each positive frequency to the mean square of ys.
+// Frequencies are expressed in units of freq, or in cycles per sample if
+// freq is not positive.
+func MeanSquare(ys []float64, freq float64) (freqs, ms []float64) {
+	scale := 1.0
+	if freq > 0 {
+		scale = freq
+	}
+	n := len(ys)
+	if n < 2 {
+		return nil, nil
+	}
+
+	var (
+		fft  = fourier.NewFFT(n)
+		cs   = fft.Coefficients(nil, ys)
+		norm = 1 / float64(n*n)
+	)
+	freqs = make([]float64, len(cs)-1)
+	ms = make([]float64, len(cs)-1)
+	for i, c := range cs[1:] {
+		k := i + 1
+		v := cmplx.Abs(c)
+		freqs[i] = fft.Freq(k) * scale
+		ms[i] = 2 * v * v * norm
+		if 2*k == n {
+			// the Nyquist bin is not mirrored.
+			ms[i] /= 2
+		}
+	}
+	return freqs, ms
+}
+
+// BandRMS returns the RMS value within each band of the provided one-sided
+// mean-square spectrum.
+// Spectral bins are assigned to the band containing their frequency.
+// Bands without any spectral bin are set to NaN.
+func BandRMS(freqs, ms []float64, bands Bands) []float64 {
+	var (
+		out = make([]float64, len(bands.Centers))
+		ns  = make([]int, len(bands.Centers))
+	)
+	for i, f := range freqs {
+		for j := range out {
+			if bands.Lo[j] <= f && f < bands.Hi[j] {
+				out[j] += ms[i]
+				ns[j]++
+				break
+			}
+		}
+	}
+	for j, v := range out {
+		switch ns[j] {
+		case 0:
+			out[j] = math.NaN()
+		default:
+			out[j] = math.Sqrt(v)
+		}
+	}
+	return out
+}
+
+// Octave holds the result of a fractional-octave band analysis.
+type Octave struct {
+	Data struct {
+		X []float64
+		Y []float64
+	}
+	Bands  Bands
+	RMS    []float64   // band RMS over the whole time series
+	Ts     []float64   // chunked time series
+	Coeffs [][]float64 // band RMS, per chunk
+
+	Name   string
+	Chunks int
+	Scale  float64 // Frequency scale
+}
+
+// ChunkedOctave computes the band RMS of ys within the provided bands,
+// over the whole time series and by chunks of chunksz samples.
+// Bands narrower than the frequency resolution of a chunk are set to NaN.
+//
+// ChunkedOctave returns an error if chunksz is not positive, or if the
+// sampling frequency is unknown (freq <= 0): octave bands are defined in Hz.
+func ChunkedOctave(fname string, chunksz int, xs, ys []float64, freq float64, bands Bands) (Octave, error) {
+	if chunksz <= 0 {
+		return Octave{}, fmt.Errorf("fouracc: invalid chunk size %d", chunksz)
+	}
+	if freq <= 0 {
+		return Octave{}, fmt.Errorf("fouracc: octave analysis needs a sampling frequency (got freq=%v)", freq)
+	}
+
+	oct := Octave{
+		Bands:  bands,
+		Name:   fname,
+		Chunks: chunksz,
+		Scale:  freq,
+	}
+	oct.Data.X = xs
+	oct.Data.Y = ys
+
+	fs, ms := MeanSquare(ys, freq)
+	oct.RMS = BandRMS(fs, ms, bands)
+	for i := 0; i < len(ys); i += chunksz {
+		end := i + chunksz
+		if end > len(ys) {
+			end = len(ys)
+		}
+		if end-i < 2 {
+			break
+		}
+		fs, ms := MeanSquare(ys[i:end], freq)
+		oct.Ts = append(oct.Ts, xs[i])
+		oct.Coeffs = append(oct.Coeffs, BandRMS(fs, ms, bands))
+	}
+
+	return oct, nil
+}
+
+func (oct Octave) Dims() (c, r int)   { return len(oct.Coeffs), len(oct.Bands.Centers) }
+func (oct Octave) Z(c, r int) float64 { return oct.Coeffs[c][r] }
+func (oct Octave) X(c int) float64    { return oct.Ts[c] }
+func (oct Octave) Y(r int) float64    { return oct.Bands.Centers[r] }
+
+func (oct Octave) Title() string {
+	if oct.Scale > 0 {
+		return fmt.Sprintf("%s -- 1/%d octave chunks=%d (freq=%v Hz)", oct.Name, oct.Bands.Fraction, oct.Chunks, oct.Scale)
+	}
+	return fmt.Sprintf("%s -- 1/%d octave chunks=%d", oct.Name, oct.Bands.Fraction, oct.Chunks)
+}
+
+func (oct Octave) Series() (xs, ys []float64) { return oct.Data.X, oct.Data.Y }
@@ -0,0 +1,153 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"math"
+	"testing"
+)
+
+func TestOctaveBands(t *testing.T) {
+	// exact mid-band and band-edge frequencies of the IEC 61260-1 base-10
+	// system, e.g. Table E.1 (octave) and Table E.2 (one-third octave).
+	for _, tc := range []struct {
+		b      int
+		lo, hi float64
+		want   Bands
+	}{
+		{
+			b: 1, lo: 100, hi: 2000,
+			want: Bands{
+				Centers: []float64{125.893, 251.189, 501.187, 1000, 1995.26},
+				Lo:      []float64{89.1251, 177.828, 354.813, 707.946, 1412.54},
+				Hi:      []float64{177.828, 354.813, 707.946, 1412.54, 2818.38},
+			},
+		},
+		{
+			b: 3, lo: 79, hi: 130,
+			want: Bands{
+				Centers: []float64{79.4328, 100, 125.893},
+				Lo:      []float64{70.7946, 89.1251, 112.202},
+				Hi:      []float64{89.1251, 112.202, 141.254},
+			},
+		},
+		{
+			b: 3, lo: 900, hi: 1300,
+			want: Bands{
+				Centers: []float64{1000, 1258.93},
+				Lo:      []float64{891.251, 1122.02},
+				Hi:      []float64{1122.02, 1412.54},
+			},
+		},
+		{
+			// even fractions have no band centred on 1 kHz.
+			b: 6, lo: 900, hi: 1100,
+			want: Bands{
+				Centers: []float64{944.061, 1059.25},
+				Lo:      []float64{891.251, 1000},
+				Hi:      []float64{1000, 1122.02},
+			},
+		},
+	} {
+		got, err := OctaveBands(tc.b, tc.lo, tc.hi)
+		if err != nil {
+			t.Fatalf("1/%d [%v, %v]: %+v", tc.b, tc.lo, tc.hi, err)
+		}
+		if got.Fraction != tc.b {
+			t.Fatalf("1/%d: invalid fraction: got=%d", tc.b, got.Fraction)
+		}
+		for _, v := range []struct {
+			name      string
+			got, want []float64
+		}{
+			{"centers", got.Centers, tc.want.Centers},
+			{"lower edges", got.Lo, tc.want.Lo},
+			{"upper edges", got.Hi, tc.want.Hi},
+		} {
+			if len(v.got) != len(v.want) {
+				t.Fatalf("1/%d [%v, %v]: invalid %s: got=%v, want=%v", tc.b, tc.lo, tc.hi, v.name, v.got, v.want)
+			}
+			for i := range v.got {
+				if math.Abs(v.got[i]-v.want[i]) > 1e-5*v.want[i] {
+					t.Fatalf("1/%d [%v, %v]: invalid %s: got=%v, want=%v", tc.b, tc.lo, tc.hi, v.name, v.got, v.want)
+				}
+			}
+		}
+	}
+}
+
+func TestOctaveBandsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		b      int
+		lo, hi float64
+	}{
+		{b: 2, lo: 10, hi: 100},
+		{b: 0, lo: 10, hi: 100},
+		{b: 3, lo: 0, hi: 100},
+		{b: 3, lo: 100, hi: 10},
+	} {
+		_, err := OctaveBands(tc.b, tc.lo, tc.hi)
+		if err == nil {
+			t.Fatalf("1/%d [%v, %v]: expected an error", tc.b, tc.lo, tc.hi)
+		}
+	}
+}
+
+func TestChunkedOctave(t *testing.T) {
+	const (
+		freq = 1024.0 // Hz
+		f0   = 100.0  // Hz, within the 100 Hz one-third octave band
+		amp  = 2.0
+	)
+	var (
+		xs = make([]float64, 4096)
+		ys = make([]float64, len(xs))
+	)
+	for i := range xs {
+		xs[i] = float64(i)
+		ys[i] = amp * math.Sin(2*math.Pi*f0*float64(i)/freq)
+	}
+	bands, err := OctaveBands(3, 50, 200)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	oct, err := ChunkedOctave("sine", 1024, xs, ys, freq, bands)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if got, want := len(oct.Coeffs), 4; got != want {
+		t.Fatalf("invalid number of chunks: got=%d, want=%d", got, want)
+	}
+	for j, fm := range bands.Centers {
+		want := 0.0
+		if math.Abs(fm-f0) < 1e-6 {
+			want = amp / math.Sqrt2
+		}
+		if got := oct.RMS[j]; math.Abs(got-want) > 1e-9 {
+			t.Fatalf("invalid RMS of the %v Hz band: got=%v, want=%v", fm, got, want)
+		}
+		for c := range oct.Coeffs {
+			if got := oct.Coeffs[c][j]; math.Abs(got-want) > 1e-9 {
+				t.Fatalf("invalid RMS of the %v Hz band of chunk %d: got=%v, want=%v", fm, c, got, want)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		chunksz int
+		freq    float64
+	}{
+		{0, freq},
+		{-1, freq},
+		{1024, 0},
+		{1024, -1},
+	} {
+		_, err := ChunkedOctave("sine", tc.chunksz, xs, ys, tc.freq, bands)
+		if err == nil {
+			t.Fatalf("chunksz=%d, freq=%v: expected an error", tc.chunksz, tc.freq)
+		}
+	}
+}
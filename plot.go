@@ -7,24 +7,35 @@ package fouracc
 import (
 	"fmt"
 	"image/color"
+	"math"
 
 	"go-hep.org/x/hep/hplot"
+	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/palette"
+	"gonum.org/v1/plot/palette/moreland"
 	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
 	"gonum.org/v1/plot/vg"
 	"gonum.org/v1/plot/vg/draw"
 )
 
-// Plot plots the provided FFT on the provided canvas.
-func Plot(dc draw.Canvas, fft FFT) error {
+// Plot plots the provided spectrogram (FFT or CWT) on the provided canvas.
+// The natural frequencies and damping ratios of the provided modes are
+// annotated on the spectrogram.
+func Plot(dc draw.Canvas, spec Spectrogram, modes ...Mode) error {
 	var err error
 
-	err = topPlot(dc, fft)
+	err = topPlot(dc, spec)
 	if err != nil {
 		return err
 	}
 
-	err = bottomPlot(dc, fft)
+	extra, err := modeMarkers(spec, modes)
+	if err != nil {
+		return err
+	}
+
+	err = bottomPlot(dc, spec, extra...)
 	if err != nil {
 		return err
 	}
@@ -32,7 +43,37 @@ func Plot(dc draw.Canvas, fft FFT) error {
 	return nil
 }
 
-func topPlot(dc draw.Canvas, fft FFT) error {
+// modeMarkers returns dashed lines at the natural frequencies of the
+// provided modes, labeled with their damping ratio.
+func modeMarkers(spec Spectrogram, modes []Mode) ([]plot.Plotter, error) {
+	if len(modes) == 0 {
+		return nil, nil
+	}
+	var (
+		ps   []plot.Plotter
+		lbls plotter.XYLabels
+		x0   = spec.X(0)
+	)
+	for _, m := range modes {
+		line := hplot.HLine(m.Freq, nil, nil)
+		line.Line.Color = color.White
+		line.Line.Dashes = []vg.Length{vg.Points(4), vg.Points(2)}
+		ps = append(ps, line)
+
+		lbls.XYs = append(lbls.XYs, plotter.XY{X: x0, Y: m.Freq})
+		lbls.Labels = append(lbls.Labels, fmt.Sprintf(" f=%.4g ζ=%.2g%%", m.Freq, 100*m.Damping))
+	}
+	txt, err := plotter.NewLabels(lbls)
+	if err != nil {
+		return nil, fmt.Errorf("fouracc: could not create mode labels: %w", err)
+	}
+	for i := range txt.TextStyle {
+		txt.TextStyle[i].Color = color.White
+	}
+	return append(ps, txt), nil
+}
+
+func topPlot(dc draw.Canvas, spec Spectrogram) error {
 	var (
 		pt     = dc.Size()
 		height = pt.Y
@@ -48,13 +89,8 @@ func topPlot(dc draw.Canvas, fft FFT) error {
 	}
 
 	p := hplot.New()
-	switch {
-	case fft.Scale > 0:
-		p.Title.Text = fmt.Sprintf("%s -- chunks=%d (freq=%v Hz)", fft.Name, fft.Chunks, fft.Scale)
-	default:
-		p.Title.Text = fmt.Sprintf("%s -- chunks=%d", fft.Name, fft.Chunks)
-	}
-	line, err := hplot.NewLine(hplot.ZipXY(fft.Data.X, fft.Data.Y))
+	p.Title.Text = spec.Title()
+	line, err := hplot.NewLine(hplot.ZipXY(spec.Series()))
 	if err != nil {
 		return fmt.Errorf("fouracc: could not create new-line: %w", err)
 	}
@@ -66,7 +102,9 @@ func topPlot(dc draw.Canvas, fft FFT) error {
 	return nil
 }
 
-func bottomPlot(dc draw.Canvas, fft FFT) error {
+// bottomPlot plots the spectrogram heatmap, overlaid with the provided
+// extra plotters.
+func bottomPlot(dc draw.Canvas, spec Spectrogram, extra ...plot.Plotter) error {
 	var (
 		pt     = dc.Size()
 		height = pt.Y
@@ -83,14 +121,767 @@ func bottomPlot(dc draw.Canvas, fft FFT) error {
 
 	p := hplot.New()
 	pal := palette.Rainbow(255, 0, 1, 1, 1, 1)
-	hmap := plotter.NewHeatMap(fft, pal)
+	hmap := plotter.NewHeatMap(spec, pal)
 	hmap.NaN = color.Black
 	p.Add(hmap)
+
+	switch spec := spec.(type) {
+	case CWT:
+		// wavelet scales are geometrically spaced: use a rasterized heatmap
+		// on a logarithmic frequency axis.
+		hmap.Rasterized = true
+		_, _, ymin, ymax := hmap.DataRange()
+		if ymin > 0 {
+			p.Y.Scale = plot.LogScale{}
+			p.Y.Tick.Marker = plot.LogTicks{Prec: -1}
+		}
+
+		coi, err := coiPolygon(spec, ymin, ymax)
+		if err != nil {
+			return fmt.Errorf("fouracc: could not create cone of influence: %w", err)
+		}
+		p.Add(coi)
+
+	case Octave:
+		// octave bands are geometrically spaced.
+		p.Y.Scale = plot.LogScale{}
+		p.Y.Tick.Marker = plot.LogTicks{Prec: -1}
+
+	case Multitaper:
+		lines, err := lineMarkers(spec)
+		if err != nil {
+			return fmt.Errorf("fouracc: could not create line markers: %w", err)
+		}
+		if lines != nil {
+			p.Add(lines)
+			p.Legend.Add(fmt.Sprintf("F-test p<%.2g", spec.Significance()), lines)
+			p.Legend.Top = true
+		}
+	}
+
+	p.Add(extra...)
 	p.Draw(bottom)
 
 	return nil
 }
 
+// lineMarkers returns markers on the cells of the provided multitaper
+// analysis where the F-test detects a line component, or nil if there is
+// none.
+func lineMarkers(mtm Multitaper) (*plotter.Scatter, error) {
+	var (
+		xys   plotter.XYs
+		alpha = mtm.Significance()
+	)
+	for i, pval := range mtm.PValue {
+		// the F-test is meaningless on the removed mean.
+		for j := 1; j < len(pval); j++ {
+			if pval[j] < alpha {
+				xys = append(xys, plotter.XY{X: mtm.Ts[i], Y: mtm.Freqs[j]})
+			}
+		}
+	}
+	if len(xys) == 0 {
+		return nil, nil
+	}
+
+	sca, err := plotter.NewScatter(xys)
+	if err != nil {
+		return nil, err
+	}
+	sca.GlyphStyle.Shape = draw.CircleGlyph{}
+	sca.GlyphStyle.Color = color.White
+	sca.GlyphStyle.Radius = vg.Points(2)
+	return sca, nil
+}
+
+// coiPolygon returns the cone of influence of the provided CWT as
+// a shaded polygon.
+func coiPolygon(cwt CWT, ymin, ymax float64) (*plotter.Polygon, error) {
+	clamp := func(v float64) float64 {
+		switch {
+		case v < ymin:
+			return ymin
+		case v > ymax:
+			return ymax
+		}
+		return v
+	}
+
+	n := len(cwt.COI)
+	xys := make(plotter.XYs, 0, n+2)
+	for i, v := range cwt.COI {
+		xys = append(xys, plotter.XY{X: cwt.Ts[i], Y: clamp(v)})
+	}
+	xys = append(xys,
+		plotter.XY{X: cwt.Ts[n-1], Y: ymin},
+		plotter.XY{X: cwt.Ts[0], Y: ymin},
+	)
+
+	poly, err := plotter.NewPolygon(xys)
+	if err != nil {
+		return nil, err
+	}
+	poly.Color = color.NRGBA{R: 255, G: 255, B: 255, A: 128}
+	poly.LineStyle.Color = color.Black
+	poly.LineStyle.Dashes = []vg.Length{vg.Points(4), vg.Points(2)}
+	return poly, nil
+}
+
+// PlotEnvelope plots the provided envelope analysis on the provided canvas.
+// The top plot shows the band-passed signal and its envelope, the bottom
+// plot shows the envelope spectrum.
+func PlotEnvelope(dc draw.Canvas, env Envelope) error {
+	var (
+		pt     = dc.Size()
+		height = pt.Y
+		width  = pt.X
+	)
+
+	top := draw.Canvas{
+		Canvas: dc,
+		Rectangle: vg.Rectangle{
+			Min: vg.Point{X: 0, Y: 0.5 * height},
+			Max: vg.Point{X: width, Y: height},
+		},
+	}
+	bottom := draw.Canvas{
+		Canvas: dc,
+		Rectangle: vg.Rectangle{
+			Min: vg.Point{X: 0, Y: 0},
+			Max: vg.Point{X: width, Y: 0.5 * height},
+		},
+	}
+
+	p := hplot.New()
+	switch {
+	case env.Scale > 0:
+		p.Title.Text = fmt.Sprintf("%s -- envelope [%v, %v] Hz (freq=%v Hz)", env.Name, env.Lo, env.Hi, env.Scale)
+	default:
+		p.Title.Text = fmt.Sprintf("%s -- envelope [%v, %v]", env.Name, env.Lo, env.Hi)
+	}
+	sig, err := hplot.NewLine(hplot.ZipXY(env.Ts, env.Signal))
+	if err != nil {
+		return fmt.Errorf("fouracc: could not create signal line: %w", err)
+	}
+	sig.LineStyle.Color = color.Gray{Y: 128}
+
+	envl, err := hplot.NewLine(hplot.ZipXY(env.Ts, env.Env))
+	if err != nil {
+		return fmt.Errorf("fouracc: could not create envelope line: %w", err)
+	}
+	envl.LineStyle.Color = color.RGBA{R: 255, A: 255}
+
+	p.Add(sig, envl, hplot.NewGrid())
+	p.Legend.Add("signal", sig)
+	p.Legend.Add("envelope", envl)
+	p.Legend.Top = true
+	p.Draw(top)
+
+	p = hplot.New()
+	p.Title.Text = "envelope spectrum"
+	p.X.Label.Text = "frequency"
+	p.Y.Label.Text = "amplitude"
+	spec, err := hplot.NewLine(hplot.ZipXY(env.Freqs, env.Spectrum))
+	if err != nil {
+		return fmt.Errorf("fouracc: could not create envelope spectrum line: %w", err)
+	}
+	spec.LineStyle.Color = color.RGBA{B: 255, A: 255}
+	p.Add(spec, hplot.NewGrid())
+	p.Draw(bottom)
+
+	return nil
+}
+
+// PlotSRS plots the provided shock response spectrum on the provided canvas,
+// with logarithmic axes.
+func PlotSRS(dc draw.Canvas, srs SRS) error {
+	p := hplot.New()
+	p.Title.Text = fmt.Sprintf("%s -- SRS %v (Q=%v)", srs.Name, srs.Kind, srs.Q)
+	p.X.Label.Text = "natural frequency"
+	p.X.Scale = plot.LogScale{}
+	p.X.Tick.Marker = plot.LogTicks{Prec: -1}
+	p.Y.Scale = plot.LogScale{}
+	p.Y.Tick.Marker = plot.LogTicks{Prec: -1}
+	switch srs.Kind {
+	case PseudoVelSRS:
+		p.Y.Label.Text = "pseudo-velocity"
+	default:
+		p.Y.Label.Text = "absolute acceleration"
+	}
+
+	for _, v := range []struct {
+		name  string
+		data  []float64
+		color color.Color
+		dash  bool
+	}{
+		{"maximax", srs.Maximax, color.Black, false},
+		{"primary +", srs.PrimaryPos, color.RGBA{R: 255, A: 255}, false},
+		{"primary -", srs.PrimaryNeg, color.RGBA{R: 255, A: 255}, true},
+		{"residual +", srs.ResidualPos, color.RGBA{B: 255, A: 255}, false},
+		{"residual -", srs.ResidualNeg, color.RGBA{B: 255, A: 255}, true},
+	} {
+		xys := make(plotter.XYs, 0, len(v.data))
+		for i, y := range v.data {
+			if y <= 0 || srs.Freqs[i] <= 0 {
+				// not representable on a logarithmic scale.
+				continue
+			}
+			xys = append(xys, plotter.XY{X: srs.Freqs[i], Y: y})
+		}
+		if len(xys) == 0 {
+			continue
+		}
+		line, err := hplot.NewLine(xys)
+		if err != nil {
+			return fmt.Errorf("fouracc: could not create %s line: %w", v.name, err)
+		}
+		line.LineStyle.Color = v.color
+		if v.dash {
+			line.LineStyle.Dashes = []vg.Length{vg.Points(4), vg.Points(2)}
+		}
+		p.Add(line)
+		p.Legend.Add(v.name, line)
+	}
+	p.Add(hplot.NewGrid())
+	p.Legend.Top = true
+	p.Legend.Left = true
+	p.Draw(dc)
+
+	return nil
+}
+
+// PlotOctave plots the provided fractional-octave band analysis on the
+// provided canvas.
+// The top plot shows the band RMS over the whole time series as a bar chart,
+// the bottom plot shows the band RMS per chunk.
+func PlotOctave(dc draw.Canvas, oct Octave) error {
+	var (
+		pt     = dc.Size()
+		height = pt.Y
+		width  = pt.X
+	)
+
+	top := draw.Canvas{
+		Canvas: dc,
+		Rectangle: vg.Rectangle{
+			Min: vg.Point{X: 0, Y: 0.6 * height},
+			Max: vg.Point{X: width, Y: height},
+		},
+	}
+
+	p := hplot.New()
+	p.Title.Text = oct.Title()
+	p.X.Label.Text = "frequency"
+	p.X.Scale = plot.LogScale{}
+	p.X.Tick.Marker = plot.LogTicks{Prec: -1}
+	p.Y.Label.Text = "RMS"
+
+	for i, v := range oct.RMS {
+		if math.IsNaN(v) {
+			continue
+		}
+		lo, hi := oct.Bands.Lo[i], oct.Bands.Hi[i]
+		bar, err := plotter.NewPolygon(plotter.XYs{
+			{X: lo, Y: 0}, {X: lo, Y: v}, {X: hi, Y: v}, {X: hi, Y: 0},
+		})
+		if err != nil {
+			return fmt.Errorf("fouracc: could not create octave band bar: %w", err)
+		}
+		bar.Color = color.RGBA{B: 255, A: 255}
+		bar.LineStyle.Color = color.Black
+		p.Add(bar)
+	}
+	p.Add(hplot.NewGrid())
+	p.Draw(top)
+
+	if len(oct.Coeffs) == 0 {
+		return nil
+	}
+	return bottomPlot(dc, oct)
+}
+
+// PlotVC plots the 1/3 octave band velocity spectra of the provided
+// vibration criteria evaluation, overlaid with the VC curves.
+func PlotVC(dc draw.Canvas, vc VC) error {
+	p := hplot.New()
+	p.Title.Text = fmt.Sprintf("%s -- vibration criteria (target=%v)", vc.Name, vc.Target)
+	p.X.Label.Text = "1/3 octave band frequency"
+	p.X.Scale = plot.LogScale{}
+	p.X.Tick.Marker = plot.LogTicks{Prec: -1}
+	p.Y.Label.Text = "RMS velocity (m/s)"
+	p.Y.Scale = plot.LogScale{}
+	p.Y.Tick.Marker = plot.LogTicks{Prec: -1}
+
+	spectrum := func(vs []float64) plotter.XYs {
+		xys := make(plotter.XYs, 0, len(vs))
+		for i, v := range vs {
+			if math.IsNaN(v) || v <= 0 {
+				continue
+			}
+			xys = append(xys, plotter.XY{X: vc.Bands.Centers[i], Y: v})
+		}
+		return xys
+	}
+
+	for _, vs := range vc.Velocity {
+		xys := spectrum(vs)
+		if len(xys) == 0 {
+			continue
+		}
+		line, err := hplot.NewLine(xys)
+		if err != nil {
+			return fmt.Errorf("fouracc: could not create velocity line: %w", err)
+		}
+		line.LineStyle.Color = color.Gray{Y: 192}
+		p.Add(line)
+	}
+
+	if xys := spectrum(vc.Max); len(xys) > 0 {
+		line, err := hplot.NewLine(xys)
+		if err != nil {
+			return fmt.Errorf("fouracc: could not create max velocity line: %w", err)
+		}
+		line.LineStyle.Color = color.Black
+		line.LineStyle.Width = vg.Points(2)
+		p.Add(line)
+		p.Legend.Add("max", line)
+	}
+
+	pal := palette.Rainbow(len(VCClasses), 0, 0.8, 1, 1, 1).Colors()
+	for i, class := range VCClasses {
+		xys := make(plotter.XYs, len(vc.Bands.Centers))
+		for j, f := range vc.Bands.Centers {
+			xys[j] = plotter.XY{X: f, Y: class.Limit(f)}
+		}
+		line, err := hplot.NewLine(xys)
+		if err != nil {
+			return fmt.Errorf("fouracc: could not create %v line: %w", class, err)
+		}
+		line.LineStyle.Color = pal[i]
+		line.LineStyle.Dashes = []vg.Length{vg.Points(4), vg.Points(2)}
+		if class == vc.Target {
+			line.LineStyle.Width = vg.Points(2)
+		}
+		p.Add(line)
+		p.Legend.Add(class.String(), line)
+	}
+
+	p.Add(hplot.NewGrid())
+	p.Legend.Top = true
+	p.Draw(dc)
+
+	return nil
+}
+
+// PlotWeighted plots the provided frequency-weighted acceleration on the
+// provided canvas.
+// The top plot shows the weighted acceleration, the bottom plot shows its
+// running RMS.
+func PlotWeighted(dc draw.Canvas, wrms WeightedRMS) error {
+	var (
+		pt     = dc.Size()
+		height = pt.Y
+		width  = pt.X
+	)
+
+	top := draw.Canvas{
+		Canvas: dc,
+		Rectangle: vg.Rectangle{
+			Min: vg.Point{X: 0, Y: 0.5 * height},
+			Max: vg.Point{X: width, Y: height},
+		},
+	}
+	bottom := draw.Canvas{
+		Canvas: dc,
+		Rectangle: vg.Rectangle{
+			Min: vg.Point{X: 0, Y: 0},
+			Max: vg.Point{X: width, Y: 0.5 * height},
+		},
+	}
+
+	p := hplot.New()
+	p.Title.Text = fmt.Sprintf("%s -- %v-weighted (freq=%v Hz)", wrms.Name, wrms.Weighting, wrms.Scale)
+	sig, err := hplot.NewLine(hplot.ZipXY(wrms.Ts, wrms.Weighted))
+	if err != nil {
+		return fmt.Errorf("fouracc: could not create weighted signal line: %w", err)
+	}
+	sig.LineStyle.Color = color.RGBA{R: 255, A: 255}
+	p.Add(sig, hplot.NewGrid())
+	p.Draw(top)
+
+	p = hplot.New()
+	p.Title.Text = fmt.Sprintf("running RMS (tau=%vs): rms=%.4g, MTVV=%.4g", wrms.Tau, wrms.RMS, wrms.MTVV)
+	run, err := hplot.NewLine(hplot.ZipXY(wrms.Ts, wrms.Running))
+	if err != nil {
+		return fmt.Errorf("fouracc: could not create running RMS line: %w", err)
+	}
+	run.LineStyle.Color = color.RGBA{B: 255, A: 255}
+
+	rms := hplot.HLine(wrms.RMS, nil, nil)
+	rms.Line.Color = color.Black
+	rms.Line.Dashes = []vg.Length{vg.Points(4), vg.Points(2)}
+
+	p.Add(run, rms, hplot.NewGrid())
+	p.Legend.Add("running RMS", run)
+	p.Legend.Add("RMS", rms)
+	p.Legend.Top = true
+	p.Draw(bottom)
+
+	return nil
+}
+
+// PlotCumRMS plots the provided cumulative RMS on the provided canvas.
+// The top plot shows the power spectral density, the bottom plot shows the
+// cumulative RMS from low to high frequencies and the reverse, with markers
+// at the frequencies where each energy level is reached.
+func PlotCumRMS(dc draw.Canvas, cum CumRMS) error {
+	var (
+		pt     = dc.Size()
+		height = pt.Y
+		width  = pt.X
+	)
+
+	top := draw.Canvas{
+		Canvas: dc,
+		Rectangle: vg.Rectangle{
+			Min: vg.Point{X: 0, Y: 0.5 * height},
+			Max: vg.Point{X: width, Y: height},
+		},
+	}
+	bottom := draw.Canvas{
+		Canvas: dc,
+		Rectangle: vg.Rectangle{
+			Min: vg.Point{X: 0, Y: 0},
+			Max: vg.Point{X: width, Y: 0.5 * height},
+		},
+	}
+
+	p := hplot.New()
+	if cum.Scale > 0 {
+		p.Title.Text = fmt.Sprintf("%s -- PSD (freq=%v Hz)", cum.Name, cum.Scale)
+	} else {
+		p.Title.Text = fmt.Sprintf("%s -- PSD", cum.Name)
+	}
+	p.X.Label.Text = "frequency"
+	p.Y.Scale = plot.LogScale{}
+	p.Y.Tick.Marker = plot.LogTicks{Prec: -1}
+
+	xys := make(plotter.XYs, 0, len(cum.PSD))
+	for i, v := range cum.PSD {
+		if v <= 0 || math.IsNaN(v) {
+			continue
+		}
+		xys = append(xys, plotter.XY{X: cum.Freqs[i], Y: v})
+	}
+	if len(xys) > 0 {
+		psd, err := hplot.NewLine(xys)
+		if err != nil {
+			return fmt.Errorf("fouracc: could not create PSD line: %w", err)
+		}
+		psd.LineStyle.Color = color.RGBA{R: 255, A: 255}
+		p.Add(psd)
+	}
+	p.Add(hplot.NewGrid())
+	p.Draw(top)
+
+	p = hplot.New()
+	p.Title.Text = fmt.Sprintf("cumulative RMS: rms=%.4g", cum.RMS)
+	p.X.Label.Text = "frequency"
+	p.Y.Label.Text = "RMS"
+
+	for _, v := range []struct {
+		name  string
+		ys    []float64
+		freqs []float64
+		color color.Color
+	}{
+		{"low to high", cum.Up, cum.UpFreqs, color.RGBA{B: 255, A: 255}},
+		{"high to low", cum.Down, cum.DownFreqs, color.RGBA{G: 160, A: 255}},
+	} {
+		if len(v.ys) == 0 {
+			continue
+		}
+		line, err := hplot.NewLine(hplot.ZipXY(cum.Freqs, v.ys))
+		if err != nil {
+			return fmt.Errorf("fouracc: could not create cumulative RMS line: %w", err)
+		}
+		line.LineStyle.Color = v.color
+		p.Add(line)
+		p.Legend.Add(v.name, line)
+
+		marks := plotter.XYLabels{}
+		for i, f := range v.freqs {
+			if math.IsNaN(f) {
+				continue
+			}
+			marks.XYs = append(marks.XYs, plotter.XY{X: f, Y: math.Sqrt(cum.Levels[i]) * cum.RMS})
+			marks.Labels = append(marks.Labels, fmt.Sprintf(" %g%%", 100*cum.Levels[i]))
+		}
+		if len(marks.XYs) == 0 {
+			continue
+		}
+		pts, err := plotter.NewScatter(marks)
+		if err != nil {
+			return fmt.Errorf("fouracc: could not create energy level markers: %w", err)
+		}
+		pts.GlyphStyle.Color = v.color
+		pts.GlyphStyle.Shape = draw.CircleGlyph{}
+		pts.GlyphStyle.Radius = vg.Points(3)
+		lbls, err := plotter.NewLabels(marks)
+		if err != nil {
+			return fmt.Errorf("fouracc: could not create energy level labels: %w", err)
+		}
+		p.Add(pts, lbls)
+	}
+
+	p.Add(hplot.NewGrid())
+	p.Draw(bottom)
+
+	return nil
+}
+
+// PlotHarmonics plots the provided spectrogram on the provided canvas,
+// with the peaks of its harmonic families marked and labeled.
+func PlotHarmonics(dc draw.Canvas, spec Spectrogram, h Harmonics) error {
+	err := topPlot(dc, spec)
+	if err != nil {
+		return err
+	}
+
+	var extra []plot.Plotter
+	for i, fam := range h.Families {
+		var pts plotter.XYs
+		for j, t := range fam.Ts {
+			for _, n := range fam.Orders[j] {
+				pts = append(pts, plotter.XY{X: t, Y: float64(n) * fam.F0[j]})
+			}
+		}
+		sca, err := plotter.NewScatter(pts)
+		if err != nil {
+			return fmt.Errorf("fouracc: could not create harmonic family markers: %w", err)
+		}
+		sca.GlyphStyle.Color = color.White
+		sca.GlyphStyle.Shape = plotutil.Shape(i)
+		sca.GlyphStyle.Radius = vg.Points(3)
+
+		lbl, err := plotter.NewLabels(plotter.XYLabels{
+			XYs:    plotter.XYs{{X: fam.Ts[0], Y: fam.F0[0]}},
+			Labels: []string{" " + fam.Label()},
+		})
+		if err != nil {
+			return fmt.Errorf("fouracc: could not create harmonic family label: %w", err)
+		}
+		lbl.TextStyle[0].Color = color.White
+		extra = append(extra, sca, lbl)
+	}
+
+	return bottomPlot(dc, spec, extra...)
+}
+
+// PlotAnomaly plots the scored spectrogram on the provided canvas, with
+// its anomalous cells highlighted.
+func PlotAnomaly(dc draw.Canvas, an Anomaly) error {
+	err := topPlot(dc, an.FFT)
+	if err != nil {
+		return err
+	}
+
+	mask := plotter.NewHeatMap(maskGrid{an}, maskPalette{})
+	mask.Min = 0
+	mask.Max = 1
+
+	return bottomPlot(dc, an.FFT, mask)
+}
+
+// PlotDiff plots the provided spectra comparison on the provided canvas.
+// The top plot shows the difference of the mean spectra, the bottom plot
+// shows the difference per chunk on a diverging palette centred on 0 dB.
+func PlotDiff(dc draw.Canvas, d Diff) error {
+	var (
+		pt     = dc.Size()
+		height = pt.Y
+		width  = pt.X
+	)
+
+	top := draw.Canvas{
+		Canvas: dc,
+		Rectangle: vg.Rectangle{
+			Min: vg.Point{X: 0, Y: 0.6 * height},
+			Max: vg.Point{X: width, Y: height},
+		},
+	}
+	bottom := draw.Canvas{
+		Canvas: dc,
+		Rectangle: vg.Rectangle{
+			Min: vg.Point{X: 0, Y: 0},
+			Max: vg.Point{X: width, Y: 0.6 * height},
+		},
+	}
+
+	p := hplot.New()
+	p.Title.Text = d.Title()
+	p.X.Label.Text = "frequency"
+	p.Y.Label.Text = "mean spectra difference (dB)"
+
+	xys := make(plotter.XYs, 0, len(d.Freqs))
+	for i, v := range d.MeanDiff {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		xys = append(xys, plotter.XY{X: d.Freqs[i], Y: v})
+	}
+	if len(xys) > 0 {
+		line, err := hplot.NewLine(xys)
+		if err != nil {
+			return fmt.Errorf("fouracc: could not create mean difference line: %w", err)
+		}
+		line.LineStyle.Color = color.RGBA{B: 255, A: 255}
+		p.Add(line)
+	}
+	zero := hplot.HLine(0, nil, nil)
+	zero.Line.Color = color.Black
+	zero.Line.Dashes = []vg.Length{vg.Points(4), vg.Points(2)}
+	p.Add(zero, hplot.NewGrid())
+	p.Draw(top)
+
+	if d.Pairs == 0 {
+		return nil
+	}
+
+	// symmetric range, so that 0 dB sits at the centre of the palette.
+	lim := 0.0
+	for _, cs := range d.Coeffs {
+		for _, v := range cs {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			lim = math.Max(lim, math.Abs(v))
+		}
+	}
+	if lim == 0 {
+		lim = 1
+	}
+
+	cmap := moreland.SmoothBlueRed()
+	cmap.SetMin(-lim)
+	cmap.SetMax(+lim)
+
+	p = hplot.New()
+	p.Title.Text = fmt.Sprintf("difference (dB), range=[%.3g, %.3g]", -lim, +lim)
+	hmap := plotter.NewHeatMap(d, cmap.Palette(255))
+	hmap.Min = -lim
+	hmap.Max = +lim
+	hmap.NaN = color.Black
+	p.Add(hmap)
+	p.Draw(bottom)
+
+	return nil
+}
+
+// PlotEnsemble plots the ensemble-averaged spectrum, with its confidence
+// band, and the mean spectrogram across recordings on the provided canvas.
+func PlotEnsemble(dc draw.Canvas, ens Ensemble) error {
+	var (
+		pt     = dc.Size()
+		height = pt.Y
+		width  = pt.X
+	)
+
+	top := draw.Canvas{
+		Canvas: dc,
+		Rectangle: vg.Rectangle{
+			Min: vg.Point{X: 0, Y: 0.6 * height},
+			Max: vg.Point{X: width, Y: height},
+		},
+	}
+	bottom := draw.Canvas{
+		Canvas: dc,
+		Rectangle: vg.Rectangle{
+			Min: vg.Point{X: 0, Y: 0},
+			Max: vg.Point{X: width, Y: 0.6 * height},
+		},
+	}
+
+	p := hplot.New()
+	p.Title.Text = ens.Title()
+	p.X.Label.Text = "frequency"
+	p.Y.Label.Text = "mean amplitude"
+
+	var (
+		mean = make(plotter.XYs, 0, len(ens.Freqs))
+		lo   = make(plotter.XYs, 0, len(ens.Freqs))
+		hi   = make(plotter.XYs, 0, len(ens.Freqs))
+	)
+	for i, v := range ens.Mean {
+		if math.IsNaN(v) {
+			continue
+		}
+		mean = append(mean, plotter.XY{X: ens.Freqs[i], Y: v})
+		if math.IsNaN(ens.Lower[i]) || math.IsNaN(ens.Upper[i]) {
+			continue
+		}
+		lo = append(lo, plotter.XY{X: ens.Freqs[i], Y: ens.Lower[i]})
+		hi = append(hi, plotter.XY{X: ens.Freqs[i], Y: ens.Upper[i]})
+	}
+	if len(hi) > 0 {
+		band := hplot.NewBand(color.NRGBA{B: 255, A: 64}, hi, lo)
+		band.LineStyle.Width = 0
+		p.Add(band)
+		p.Y.Label.Text = fmt.Sprintf("mean amplitude (%g%% confidence band)", 100*ens.Level)
+	}
+	if len(mean) > 0 {
+		line, err := hplot.NewLine(mean)
+		if err != nil {
+			return fmt.Errorf("fouracc: could not create ensemble mean line: %w", err)
+		}
+		line.LineStyle.Color = color.RGBA{B: 255, A: 255}
+		p.Add(line)
+		p.Legend.Add(fmt.Sprintf("mean (%d recordings)", ens.Count), line)
+	}
+	p.Add(hplot.NewGrid())
+	p.Draw(top)
+
+	if len(ens.Coeffs) == 0 {
+		return nil
+	}
+
+	p = hplot.New()
+	p.Title.Text = "mean spectrogram across recordings"
+	pal := palette.Rainbow(255, 0, 1, 1, 1, 1)
+	hmap := plotter.NewHeatMap(ens, pal)
+	hmap.NaN = color.Black
+	p.Add(hmap)
+	p.Draw(bottom)
+
+	return nil
+}
+
+// maskPalette is a palette of transparent (valid) and highlighted
+// (anomalous) cells.
+type maskPalette struct{}
+
+func (maskPalette) Colors() []color.Color {
+	return []color.Color{
+		color.Transparent,
+		color.NRGBA{R: 255, G: 255, B: 255, A: 192},
+	}
+}
+
 var (
 	_ plotter.GridXYZ = (*FFT)(nil)
+	_ plotter.GridXYZ = (*CWT)(nil)
+	_ plotter.GridXYZ = (*Multitaper)(nil)
+	_ plotter.GridXYZ = (*Periodogram)(nil)
+	_ plotter.GridXYZ = (*Octave)(nil)
+	_ plotter.GridXYZ = (*Diff)(nil)
+	_ plotter.GridXYZ = (*Ensemble)(nil)
+	_ plotter.GridXYZ = (*maskGrid)(nil)
+
+	_ Spectrogram = (*FFT)(nil)
+	_ Spectrogram = (*CWT)(nil)
+	_ Spectrogram = (*Multitaper)(nil)
+	_ Spectrogram = (*Periodogram)(nil)
+	_ Spectrogram = (*Octave)(nil)
 )
@@ -0,0 +1,76 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"fmt"
+	"math"
+)
+
+// resampleLobes is the number of lobes, on each side, of the windowed-sinc
+// interpolation kernel of Resample.
+const resampleLobes = 16
+
+// Resample resamples the time series (xs, ys), sampled at the from
+// frequency, to the to frequency, with a Lanczos-windowed sinc kernel.
+// When downsampling, the kernel is low-pass filtered at the new Nyquist
+// frequency to prevent aliasing.
+// The returned abscissae are linearly interpolated from xs.
+func Resample(xs, ys []float64, from, to float64) ([]float64, []float64, error) {
+	if !(from > 0) || !(to > 0) {
+		return nil, nil, fmt.Errorf("fouracc: invalid resampling frequencies %v -> %v", from, to)
+	}
+	if len(xs) != len(ys) {
+		return nil, nil, fmt.Errorf("fouracc: mismatched time series lengths %d and %d", len(xs), len(ys))
+	}
+	if len(ys) == 0 {
+		return nil, nil, nil
+	}
+
+	var (
+		step = from / to            // output sampling step, in input samples
+		fc   = math.Min(1, to/from) // cutoff, relative to the input Nyquist frequency
+		hw   = resampleLobes / fc   // half-width of the kernel, in input samples
+		n    = int(math.Floor(float64(len(ys)-1)/step)) + 1
+		oxs  = make([]float64, n)
+		oys  = make([]float64, n)
+	)
+	for j := range oys {
+		var (
+			t      = float64(j) * step
+			beg    = int(math.Max(0, math.Ceil(t-hw)))
+			end    = int(math.Min(float64(len(ys)-1), math.Floor(t+hw)))
+			sum, w float64
+		)
+		for k := beg; k <= end; k++ {
+			x := t - float64(k)
+			h := fc * sinc(fc*x) * sinc(x/hw)
+			sum += h * ys[k]
+			w += h
+		}
+		// normalize by the kernel weight, to preserve the mean close to
+		// the edges of the time series.
+		oys[j] = sum / w
+
+		i := int(t)
+		if i >= len(xs)-1 {
+			oxs[j] = xs[len(xs)-1]
+			continue
+		}
+		f := t - float64(i)
+		oxs[j] = xs[i] + f*(xs[i+1]-xs[i])
+	}
+
+	return oxs, oys, nil
+}
+
+// sinc is the normalized sinc function.
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
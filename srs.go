@@ -0,0 +1,241 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strings"
+)
+
+// SRSKind describes the response quantity of a shock response spectrum.
+type SRSKind int
+
+const (
+	AbsAccSRS    SRSKind = iota // absolute acceleration
+	PseudoVelSRS                // pseudo-velocity (ωn times the relative displacement)
+)
+
+// ParseSRSKind returns the SRS kind named after the provided name.
+func ParseSRSKind(name string) (SRSKind, error) {
+	switch strings.ToLower(name) {
+	case "acc", "abs-acc":
+		return AbsAccSRS, nil
+	case "pv", "pseudo-vel":
+		return PseudoVelSRS, nil
+	}
+	return 0, fmt.Errorf("fouracc: unknown SRS kind %q", name)
+}
+
+func (k SRSKind) String() string {
+	switch k {
+	case AbsAccSRS:
+		return "acc"
+	case PseudoVelSRS:
+		return "pv"
+	}
+	return fmt.Sprintf("SRSKind(%d)", int(k))
+}
+
+// SRS holds a shock response spectrum.
+//
+// Negative spectra are stored as magnitudes.
+type SRS struct {
+	Freqs       []float64 // natural frequencies (Hz)
+	Maximax     []float64 // maximum absolute response, over the whole response
+	PrimaryPos  []float64 // maximum positive response, during the event
+	PrimaryNeg  []float64 // maximum negative response, during the event
+	ResidualPos []float64 // maximum positive response, after the event
+	ResidualNeg []float64 // maximum negative response, after the event
+
+	Name  string
+	Kind  SRSKind
+	Q     float64 // quality factor of the oscillators
+	Scale float64 // sampling frequency
+}
+
+// OctaveFreqs returns natural frequencies from lo to (at most) hi,
+// with n frequencies per octave.
+func OctaveFreqs(lo, hi float64, n int) []float64 {
+	if lo <= 0 || hi < lo || n <= 0 {
+		return nil
+	}
+	var (
+		fs    []float64
+		ratio = math.Pow(2, 1/float64(n))
+	)
+	for f := lo; f <= hi*(1+1e-9); f *= ratio {
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// EventWindow returns the samples of ys within the [t0,t1) time window,
+// expressed in seconds from the first sample.
+// A non-positive t1 selects all samples up to the end of ys.
+// If freq is not positive, t0 and t1 are expressed in samples.
+func EventWindow(ys []float64, freq, t0, t1 float64) []float64 {
+	scale := 1.0
+	if freq > 0 {
+		scale = freq
+	}
+	beg := int(math.Round(t0 * scale))
+	end := len(ys)
+	if t1 > 0 {
+		end = int(math.Round(t1 * scale))
+	}
+	if beg < 0 {
+		beg = 0
+	}
+	if end > len(ys) {
+		end = len(ys)
+	}
+	if beg > end {
+		beg = end
+	}
+	return ys[beg:end]
+}
+
+// ShockResponse computes the shock response spectrum of the ys base
+// acceleration, sampled at freq, for single degree-of-freedom oscillators
+// with the provided quality factor and natural frequencies.
+//
+// Responses are computed with the ramp-invariant recursive filters of
+// D. O. Smallwood, "An Improved Recursive Formula for Calculating Shock
+// Response Spectra", Shock and Vibration Bulletin 51 (1981).
+// The residual response is computed over one and a half period of the
+// free vibration following the event.
+//
+// ShockResponse returns an error if the oscillators are not underdamped
+// (q <= 0.5).
+func ShockResponse(fname string, ys []float64, freq, q float64, fns []float64, kind SRSKind) (SRS, error) {
+	if !(q > 0.5) {
+		return SRS{}, fmt.Errorf("fouracc: invalid SRS quality factor Q=%v (want Q > 0.5)", q)
+	}
+
+	dt := 1.0
+	if freq > 0 {
+		dt = 1 / freq
+	}
+
+	n := len(fns)
+	srs := SRS{
+		Freqs:       fns,
+		Maximax:     make([]float64, n),
+		PrimaryPos:  make([]float64, n),
+		PrimaryNeg:  make([]float64, n),
+		ResidualPos: make([]float64, n),
+		ResidualNeg: make([]float64, n),
+		Name:        fname,
+		Kind:        kind,
+		Q:           q,
+		Scale:       freq,
+	}
+
+	zeta := 1 / (2 * q)
+	for i, fn := range fns {
+		var (
+			wn     = 2 * math.Pi * fn
+			a, b   = smallwood(kind, wn, zeta, dt)
+			x1, x2 float64 // previous inputs
+			y1, y2 float64 // previous outputs
+			nres   = int(math.Ceil(1.5 / (fn * dt)))
+			gain   = 1.0
+		)
+		if kind == PseudoVelSRS {
+			gain = wn
+		}
+
+		step := func(x float64) float64 {
+			y := b[0]*x + b[1]*x1 + b[2]*x2 - a[1]*y1 - a[2]*y2
+			x2, x1 = x1, x
+			y2, y1 = y1, y
+			return gain * y
+		}
+
+		var pmax, pmin, rmax, rmin float64
+		for _, x := range ys {
+			y := step(x)
+			pmax = math.Max(pmax, y)
+			pmin = math.Min(pmin, y)
+		}
+		for j := 0; j < nres; j++ {
+			y := step(0)
+			rmax = math.Max(rmax, y)
+			rmin = math.Min(rmin, y)
+		}
+
+		srs.PrimaryPos[i] = pmax
+		srs.PrimaryNeg[i] = math.Abs(pmin)
+		srs.ResidualPos[i] = rmax
+		srs.ResidualNeg[i] = math.Abs(rmin)
+		srs.Maximax[i] = math.Max(
+			math.Max(srs.PrimaryPos[i], srs.PrimaryNeg[i]),
+			math.Max(srs.ResidualPos[i], srs.ResidualNeg[i]),
+		)
+	}
+
+	return srs, nil
+}
+
+// smallwood returns the coefficients of the ramp-invariant recursive filter
+//
+//	y[n] = b0 x[n] + b1 x[n-1] + b2 x[n-2] - a1 y[n-1] - a2 y[n-2]
+//
+// of a single degree-of-freedom oscillator of natural angular frequency wn
+// and damping ratio zeta, excited by a base acceleration.
+// The output is the absolute acceleration or the relative displacement of
+// the oscillator.
+func smallwood(kind SRSKind, wn, zeta, dt float64) (a, b [3]float64) {
+	var (
+		wd = wn * math.Sqrt(1-zeta*zeta)
+		e  = math.Exp(-zeta * wn * dt)
+		k  = wd * dt
+		c  = e * math.Cos(k)
+		s  = e * math.Sin(k)
+		sp = s / k
+	)
+	a = [3]float64{1, -2 * c, e * e}
+
+	switch kind {
+	case AbsAccSRS:
+		b = [3]float64{1 - sp, 2 * (sp - c), e*e - sp}
+	case PseudoVelSRS:
+		// relative displacement z of z'' + 2ζωn z' + ωn² z = -ÿ, with
+		// impulse response g(t) = 2 Re[r exp(p t)].
+		p := complex(-zeta*wn, wd)
+		r := complex(0, 1/(2*wd))
+		b = rampInvariant(p, r, a, dt)
+	default:
+		panic(fmt.Errorf("fouracc: unknown SRS kind %d", int(kind)))
+	}
+	return a, b
+}
+
+// rampInvariant returns the numerator coefficients of the ramp-invariant
+// discretization of the system with impulse response g(t) = 2 Re[r exp(p t)],
+// for the provided denominator coefficients.
+//
+// The discrete impulse response h[k] is the response sampled at t=k*dt to
+// a unit triangular pulse spanning [-dt, dt].
+func rampInvariant(p, r complex128, a [3]float64, dt float64) [3]float64 {
+	var (
+		z  = cmplx.Exp(p * complex(dt, 0))
+		t  = complex(dt, 0)
+		i0 = (z - 1) / p                   // ∫_0^dt exp(p u) du
+		i1 = (z*(p*t-1) + 1) / (p * p) / t // ∫_0^dt u/dt exp(p u) du
+		h  [3]float64
+	)
+	h[0] = 2 * real(r*(i0-i1))
+	h[1] = 2 * real(r*(i1+z*(i0-i1)))
+	h[2] = 2 * real(r*(z*i1+z*z*(i0-i1)))
+
+	return [3]float64{
+		h[0],
+		h[1] + a[1]*h[0],
+		h[2] + a[1]*h[1] + a[2]*h[0],
+	}
+}
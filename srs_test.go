@@ -0,0 +1,107 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"math"
+	"testing"
+)
+
+func TestShockResponse(t *testing.T) {
+	const (
+		freq = 10000.0 // Hz
+		q    = 10.0
+	)
+	var (
+		zeta      = 1 / (2 * q)
+		overshoot = 1 + math.Exp(-math.Pi*zeta/math.Sqrt(1-zeta*zeta))
+	)
+
+	// step of unit base acceleration.
+	step := make([]float64, int(freq))
+	for i := range step {
+		step[i] = 1
+	}
+
+	// half-sine pulse of unit amplitude and duration T.
+	const T = 0.011 // s
+	hsine := make([]float64, int(T*freq)+1)
+	for i := range hsine {
+		hsine[i] = math.Sin(math.Pi * float64(i) / freq / T)
+	}
+	dv := 2 * T / math.Pi // velocity change of the half-sine pulse
+
+	// steady sine at the natural frequency.
+	const fn = 50.0 // Hz
+	sine := make([]float64, int(4*freq))
+	for i := range sine {
+		sine[i] = math.Sin(2 * math.Pi * fn * float64(i) / freq)
+	}
+
+	for _, tc := range []struct {
+		name string
+		ys   []float64
+		fn   float64
+		kind SRSKind
+		q    float64
+		want float64
+		tol  float64 // relative tolerance
+	}{
+		// the peak relative displacement of a step is overshoot/ωn², i.e.
+		// overshoot/ωn in pseudo-velocity.
+		{"step-pv-10Hz", step, 10, PseudoVelSRS, q, overshoot / (2 * math.Pi * 10), 1e-3},
+		{"step-pv-100Hz", step, 100, PseudoVelSRS, q, overshoot / (2 * math.Pi * 100), 1e-3},
+		// stiff oscillators follow the base acceleration.
+		{"hsine-acc-4.5kHz", hsine, 4500, AbsAccSRS, q, 1, 0.05},
+		// soft, lightly damped, oscillators respond to the velocity
+		// change: ωn*Δv.
+		{"hsine-acc-2Hz", hsine, 2, AbsAccSRS, 1000, 2 * math.Pi * 2 * dv, 0.02},
+		// resonant oscillators amplify the base acceleration by ~Q.
+		{"sine-acc-fn", sine, fn, AbsAccSRS, q, math.Sqrt(1+1/(q*q)) * q, 0.01},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srs, err := ShockResponse(tc.name, tc.ys, freq, tc.q, []float64{tc.fn}, tc.kind)
+			if err != nil {
+				t.Fatalf("could not compute SRS: %+v", err)
+			}
+			if got := srs.Maximax[0]; math.Abs(got-tc.want) > tc.tol*tc.want {
+				t.Fatalf("invalid maximax SRS: got=%v, want=%v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestShockResponseQ(t *testing.T) {
+	ys := []float64{0, 1, 0}
+	for _, q := range []float64{0, -1, 0.5, math.NaN()} {
+		_, err := ShockResponse("q", ys, 100, q, []float64{10}, AbsAccSRS)
+		if err == nil {
+			t.Fatalf("Q=%v: expected an error", q)
+		}
+	}
+}
+
+func TestOctaveFreqs(t *testing.T) {
+	for _, tc := range []struct {
+		lo, hi float64
+		n      int
+		want   []float64
+	}{
+		{1, 8, 1, []float64{1, 2, 4, 8}},
+		{10, 20, 2, []float64{10, 10 * math.Sqrt2, 20}},
+		{10, 5, 1, nil},
+		{0, 5, 1, nil},
+	} {
+		got := OctaveFreqs(tc.lo, tc.hi, tc.n)
+		if len(got) != len(tc.want) {
+			t.Fatalf("lo=%v, hi=%v, n=%d: got=%v, want=%v", tc.lo, tc.hi, tc.n, got, tc.want)
+		}
+		for i := range got {
+			if math.Abs(got[i]-tc.want[i]) > 1e-9*tc.want[i] {
+				t.Fatalf("lo=%v, hi=%v, n=%d: got=%v, want=%v", tc.lo, tc.hi, tc.n, got, tc.want)
+			}
+		}
+	}
+}
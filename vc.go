@@ -0,0 +1,207 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// StdGravity is the standard acceleration of gravity, in m/s².
+const StdGravity = 9.80665
+
+// AccScale returns the conversion factor from the provided acceleration
+// unit to m/s².
+func AccScale(unit string) (float64, error) {
+	switch strings.TrimSpace(unit) {
+	case "g", "G":
+		return StdGravity, nil
+	case "mg":
+		return 1e-3 * StdGravity, nil
+	case "m/s2", "m/s^2", "m/s²", "m s-2":
+		return 1, nil
+	case "mm/s2", "mm/s^2", "mm/s²":
+		return 1e-3, nil
+	}
+	return 0, fmt.Errorf("fouracc: unknown acceleration unit %q", unit)
+}
+
+// VCClass is a generic vibration criterion (VC) curve for vibration
+// sensitive equipment, as defined by the IEST-RP-CC012 recommended practice.
+type VCClass int
+
+const (
+	NoVC VCClass = iota // no criterion met
+	VCA                 // VC-A: 50 µm/s
+	VCB                 // VC-B: 25 µm/s
+	VCC                 // VC-C: 12.5 µm/s
+	VCD                 // VC-D: 6.25 µm/s
+	VCE                 // VC-E: 3.12 µm/s
+)
+
+// VCClasses lists the VC curves, from the least to the most stringent.
+var VCClasses = []VCClass{VCA, VCB, VCC, VCD, VCE}
+
+// ParseVCClass returns the VC curve named after the provided name,
+// e.g. "VC-C" or "C".
+func ParseVCClass(name string) (VCClass, error) {
+	v := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "VC-")
+	switch v {
+	case "A":
+		return VCA, nil
+	case "B":
+		return VCB, nil
+	case "C":
+		return VCC, nil
+	case "D":
+		return VCD, nil
+	case "E":
+		return VCE, nil
+	}
+	return NoVC, fmt.Errorf("fouracc: unknown vibration criterion %q", name)
+}
+
+func (c VCClass) String() string {
+	switch c {
+	case NoVC:
+		return "none"
+	case VCA, VCB, VCC, VCD, VCE:
+		return "VC-" + string(rune('A'+int(c-VCA)))
+	}
+	return fmt.Sprintf("VCClass(%d)", int(c))
+}
+
+// Limit returns the RMS velocity limit (m/s) of the VC curve, for the
+// 1/3 octave band centred on f (Hz).
+// VC-A and VC-B relax to a constant acceleration below 8 Hz.
+func (c VCClass) Limit(f float64) float64 {
+	var v float64
+	switch c {
+	case VCA:
+		v = 50e-6
+	case VCB:
+		v = 25e-6
+	case VCC:
+		v = 12.5e-6
+	case VCD:
+		v = 6.25e-6
+	case VCE:
+		v = 3.12e-6
+	default:
+		return math.Inf(+1)
+	}
+	if (c == VCA || c == VCB) && f < 8 {
+		v *= 8 / f
+	}
+	return v
+}
+
+const (
+	vcLo = 1  // lowest 1/3 octave band centre of the VC curves (Hz)
+	vcHi = 80 // highest 1/3 octave band centre of the VC curves (Hz)
+)
+
+// VC holds the result of a vibration criteria evaluation.
+type VC struct {
+	Bands    Bands       // 1/3 octave bands of the VC curves
+	Ts       []float64   // chunked time series
+	Velocity [][]float64 // band RMS velocity (m/s), per chunk
+	Max      []float64   // maximum band RMS velocity (m/s) over all chunks
+
+	Class     []VCClass // most stringent VC curve met, per chunk
+	Exceeding [][]int   // indices of the bands exceeding the target curve, per chunk
+
+	Name   string
+	Target VCClass // VC curve to comply with
+	Chunks int
+	Scale  float64 // Frequency scale
+}
+
+// Pass returns whether all chunks comply with the target VC curve.
+func (vc VC) Pass() bool {
+	for _, c := range vc.Class {
+		if c < vc.Target {
+			return false
+		}
+	}
+	return true
+}
+
+// VibrationCriteria evaluates the compliance of the ys acceleration,
+// sampled at freq (Hz), with the VC curves, by chunks of chunksz samples.
+// accScale converts ys to m/s², see AccScale.
+//
+// Acceleration spectra are integrated to velocity and summed within the
+// 1/3 octave bands from 1 to 80 Hz.
+// Bands narrower than the frequency resolution of a chunk are ignored.
+func VibrationCriteria(fname string, chunksz int, xs, ys []float64, freq, accScale float64, target VCClass) (VC, error) {
+	if chunksz <= 0 {
+		return VC{}, fmt.Errorf("fouracc: invalid chunk size %d", chunksz)
+	}
+	if freq <= 0 {
+		return VC{}, fmt.Errorf("fouracc: vibration criteria need a sampling frequency")
+	}
+	hi := float64(vcHi)
+	if nyq := freq / 2; nyq < hi {
+		hi = nyq
+	}
+	bands, err := OctaveBands(3, vcLo, hi)
+	if err != nil {
+		return VC{}, err
+	}
+
+	vc := VC{
+		Bands:  bands,
+		Max:    make([]float64, len(bands.Centers)),
+		Name:   fname,
+		Target: target,
+		Chunks: chunksz,
+		Scale:  freq,
+	}
+	for i := range vc.Max {
+		vc.Max[i] = math.NaN()
+	}
+
+	for i := 0; i < len(ys); i += chunksz {
+		end := i + chunksz
+		if end > len(ys) {
+			end = len(ys)
+		}
+		if end-i < 2 {
+			break
+		}
+		fs, ms := MeanSquare(ys[i:end], freq)
+		for j, f := range fs {
+			w := accScale / (2 * math.Pi * f)
+			ms[j] *= w * w
+		}
+		vs := BandRMS(fs, ms, bands)
+
+		class := VCClasses[len(VCClasses)-1]
+		var exceeding []int
+		for j, v := range vs {
+			if math.IsNaN(v) {
+				continue
+			}
+			if math.IsNaN(vc.Max[j]) || v > vc.Max[j] {
+				vc.Max[j] = v
+			}
+			for class > NoVC && v > class.Limit(bands.Centers[j]) {
+				class--
+			}
+			if v > target.Limit(bands.Centers[j]) {
+				exceeding = append(exceeding, j)
+			}
+		}
+
+		vc.Ts = append(vc.Ts, xs[i])
+		vc.Velocity = append(vc.Velocity, vs)
+		vc.Class = append(vc.Class, class)
+		vc.Exceeding = append(vc.Exceeding, exceeding)
+	}
+
+	return vc, nil
+}
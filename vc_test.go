@@ -0,0 +1,32 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import "testing"
+
+func TestVibrationCriteriaErrors(t *testing.T) {
+	var (
+		xs = make([]float64, 512)
+		ys = make([]float64, len(xs))
+	)
+	for i := range xs {
+		xs[i] = float64(i)
+	}
+
+	for _, tc := range []struct {
+		chunksz int
+		freq    float64
+	}{
+		{0, 200},
+		{-1, 200},
+		{256, 0},
+		{256, -1},
+	} {
+		_, err := VibrationCriteria("zero", tc.chunksz, xs, ys, tc.freq, 1, VCA)
+		if err == nil {
+			t.Fatalf("chunksz=%d, freq=%v: expected an error", tc.chunksz, tc.freq)
+		}
+	}
+}
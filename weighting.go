@@ -0,0 +1,315 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/lsst-lpc/fouracc/msr"
+)
+
+// Weighting is a frequency weighting of acceleration, as defined by
+// ISO 2631-1, ISO 2631-2 and ISO 8041.
+type Weighting int
+
+const (
+	Wk Weighting = iota // vertical whole-body vibration (z-axis)
+	Wd                  // horizontal whole-body vibration (x- and y-axis)
+	Wc                  // seat-back vibration (x-axis)
+	Wm                  // vibration in buildings (all axes)
+)
+
+// ParseWeighting returns the frequency weighting named after the provided name.
+func ParseWeighting(name string) (Weighting, error) {
+	switch strings.ToLower(name) {
+	case "wk":
+		return Wk, nil
+	case "wd":
+		return Wd, nil
+	case "wc":
+		return Wc, nil
+	case "wm":
+		return Wm, nil
+	}
+	return 0, fmt.Errorf("fouracc: unknown frequency weighting %q", name)
+}
+
+func (w Weighting) String() string {
+	switch w {
+	case Wk:
+		return "Wk"
+	case Wd:
+		return "Wd"
+	case Wc:
+		return "Wc"
+	case Wm:
+		return "Wm"
+	}
+	return fmt.Sprintf("Weighting(%d)", int(w))
+}
+
+// weightingParams holds the parameters (Hz) of the transfer function of
+// a frequency weighting.
+// A zero f5 disables the upward step.
+type weightingParams struct {
+	f1, f2     float64 // band-limiting high-pass and low-pass corner frequencies
+	f3, f4, q4 float64 // acceleration-velocity transition
+	f5, q5     float64 // upward step
+	f6, q6     float64
+}
+
+func (w Weighting) params() weightingParams {
+	switch w {
+	case Wk:
+		return weightingParams{0.4, 100, 12.5, 12.5, 0.63, 2.37, 0.91, 3.35, 0.91}
+	case Wd:
+		return weightingParams{f1: 0.4, f2: 100, f3: 2, f4: 2, q4: 0.63}
+	case Wc:
+		return weightingParams{f1: 0.4, f2: 100, f3: 8, f4: 8, q4: 0.63}
+	case Wm:
+		return weightingParams{f1: 0.7943, f2: 100, f3: 5.684, f4: 5.684, q4: 0.5}
+	}
+	panic(fmt.Errorf("fouracc: unknown frequency weighting %d", int(w)))
+}
+
+// biquad is an analog second-order section
+//
+//	H(s) = (b[0] s² + b[1] s + b[2]) / (a[0] s² + a[1] s + a[2])
+//
+// whose bilinear transform is prewarped at the angular frequency w0.
+type biquad struct {
+	b, a    [3]float64
+	w0      float64
+	lowpass bool // whether the section is the band-limiting low-pass
+}
+
+// sections returns the analog second-order sections of the weighting.
+func (w Weighting) sections() []biquad {
+	var (
+		p  = w.params()
+		w1 = 2 * math.Pi * p.f1
+		w2 = 2 * math.Pi * p.f2
+		w3 = 2 * math.Pi * p.f3
+		w4 = 2 * math.Pi * p.f4
+	)
+	secs := []biquad{
+		// band-limiting high-pass.
+		{b: [3]float64{1, 0, 0}, a: [3]float64{1, math.Sqrt2 * w1, w1 * w1}, w0: w1},
+		// band-limiting low-pass.
+		{b: [3]float64{0, 0, 1}, a: [3]float64{1 / (w2 * w2), math.Sqrt2 / w2, 1}, w0: w2, lowpass: true},
+		// acceleration-velocity transition.
+		{b: [3]float64{0, 1 / w3, 1}, a: [3]float64{1 / (w4 * w4), 1 / (p.q4 * w4), 1}, w0: w4},
+	}
+	if p.f5 > 0 {
+		var (
+			w5 = 2 * math.Pi * p.f5
+			w6 = 2 * math.Pi * p.f6
+			k  = (w5 / w6) * (w5 / w6)
+		)
+		secs = append(secs, biquad{
+			b:  [3]float64{k / (w5 * w5), k / (p.q5 * w5), k},
+			a:  [3]float64{1 / (w6 * w6), 1 / (p.q6 * w6), 1},
+			w0: math.Sqrt(w5 * w6),
+		})
+	}
+	return secs
+}
+
+// Response returns the transfer function of the weighting at the
+// frequency f (Hz).
+func (w Weighting) Response(f float64) complex128 {
+	var (
+		s = complex(0, 2*math.Pi*f)
+		h = complex(1, 0)
+	)
+	for _, sec := range w.sections() {
+		num := complex(sec.b[0], 0)*s*s + complex(sec.b[1], 0)*s + complex(sec.b[2], 0)
+		den := complex(sec.a[0], 0)*s*s + complex(sec.a[1], 0)*s + complex(sec.a[2], 0)
+		h *= num / den
+	}
+	return h
+}
+
+// Weight returns the spectral weight of the weighting at the frequency f (Hz).
+func (w Weighting) Weight(f float64) float64 {
+	h := w.Response(f)
+	return math.Hypot(real(h), imag(h))
+}
+
+// Filter returns ys, sampled at freq (Hz), filtered by the weighting.
+//
+// The analog sections are discretized with a prewarped bilinear transform.
+// The band-limiting low-pass is omitted when its corner frequency is
+// above 90% of the Nyquist frequency.
+func (w Weighting) Filter(ys []float64, freq float64) []float64 {
+	var (
+		dt  = 1 / freq
+		nyq = math.Pi * freq
+		out = make([]float64, len(ys))
+	)
+	copy(out, ys)
+	for _, sec := range w.sections() {
+		if sec.lowpass && sec.w0 >= 0.9*nyq {
+			// sampling already band-limits the signal.
+			continue
+		}
+		k := 2 / dt
+		if sec.w0 < 0.9*nyq {
+			k = sec.w0 / math.Tan(sec.w0*dt/2)
+		}
+		b, a := bilinear(sec, k)
+		var x1, x2, y1, y2 float64
+		for i, x := range out {
+			y := (b[0]*x + b[1]*x1 + b[2]*x2 - a[1]*y1 - a[2]*y2) / a[0]
+			x2, x1 = x1, x
+			y2, y1 = y1, y
+			out[i] = y
+		}
+	}
+	return out
+}
+
+// bilinear returns the digital coefficients of the analog section,
+// with s = k (1-z⁻¹)/(1+z⁻¹).
+func bilinear(sec biquad, k float64) (b, a [3]float64) {
+	digital := func(c [3]float64) [3]float64 {
+		k2 := k * k
+		return [3]float64{
+			c[0]*k2 + c[1]*k + c[2],
+			2 * (c[2] - c[0]*k2),
+			c[0]*k2 - c[1]*k + c[2],
+		}
+	}
+	return digital(sec.b), digital(sec.a)
+}
+
+// RunningRMS returns the running RMS of ys, sampled at freq (Hz), with
+// a linear integration over the tau (s) preceding each sample.
+// The first samples are integrated over the available samples.
+func RunningRMS(ys []float64, freq, tau float64) []float64 {
+	n := int(math.Round(tau * freq))
+	if n < 1 {
+		n = 1
+	}
+	var (
+		out = make([]float64, len(ys))
+		sum = 0.0
+	)
+	for i, y := range ys {
+		sum += y * y
+		if i >= n {
+			sum -= ys[i-n] * ys[i-n]
+		}
+		m := i + 1
+		if m > n {
+			m = n
+		}
+		out[i] = math.Sqrt(math.Max(sum, 0) / float64(m))
+	}
+	return out
+}
+
+// WeightedRMS holds the frequency-weighted RMS of an acceleration.
+type WeightedRMS struct {
+	Ts       []float64 // time series
+	Weighted []float64 // frequency-weighted acceleration
+	Running  []float64 // running RMS of the weighted acceleration
+
+	RMS         float64 // overall weighted RMS
+	SpectralRMS float64 // overall weighted RMS, from the spectral weights
+	MTVV        float64 // maximum transient vibration value
+
+	Name      string
+	Weighting Weighting
+	Tau       float64 // integration time of the running RMS (s)
+	Scale     float64 // Frequency scale
+}
+
+// FrequencyWeighting computes the weighted RMS, running RMS and MTVV of
+// the ys acceleration, sampled at freq (Hz), for the provided weighting and
+// running RMS integration time tau (s).
+func FrequencyWeighting(fname string, w Weighting, xs, ys []float64, freq, tau float64) (WeightedRMS, error) {
+	if freq <= 0 {
+		return WeightedRMS{}, fmt.Errorf("fouracc: frequency weighting needs a sampling frequency")
+	}
+	wrms := WeightedRMS{
+		Ts:        xs,
+		Name:      fname,
+		Weighting: w,
+		Tau:       tau,
+		Scale:     freq,
+	}
+	if len(ys) == 0 {
+		return wrms, nil
+	}
+
+	// remove the mean, which the weighting rejects anyway, to avoid the
+	// start-up transient of the band-limiting high-pass (e.g. gravity).
+	var (
+		mean = 0.0
+		vs   = make([]float64, len(ys))
+	)
+	for _, v := range ys {
+		mean += v
+	}
+	mean /= float64(len(ys))
+	for i, v := range ys {
+		vs[i] = v - mean
+	}
+
+	wrms.Weighted = w.Filter(vs, freq)
+	wrms.Running = RunningRMS(wrms.Weighted, freq, tau)
+
+	for _, v := range wrms.Weighted {
+		wrms.RMS += v * v
+	}
+	wrms.RMS = math.Sqrt(wrms.RMS / float64(len(ys)))
+
+	for _, v := range wrms.Running {
+		wrms.MTVV = math.Max(wrms.MTVV, v)
+	}
+
+	fs, ms := MeanSquare(ys, freq)
+	for i, f := range fs {
+		v := w.Weight(f)
+		wrms.SpectralRMS += ms[i] * v * v
+	}
+	wrms.SpectralRMS = math.Sqrt(wrms.SpectralRMS)
+
+	return wrms, nil
+}
+
+// AxesRMS computes the weighted RMS, running RMS and MTVV of the
+// acceleration axes of the provided MSR file.
+func AxesRMS(f msr.File, w Weighting, tau float64) ([]WeightedRMS, error) {
+	var (
+		names = []string{"x", "y", "z"}
+		axes  = make([][]float64, len(names))
+	)
+	for i, name := range names {
+		axes[i], _, _ = f.Channel("ACC " + name)
+	}
+	return axesRMS(names, f.Axis(), axes, f.Freq(), w, tau)
+}
+
+// axesRMS computes the weighted RMS, running RMS and MTVV of the named
+// acceleration axes, sampled at freq (Hz) on the common time series xs.
+// Nil axes are skipped.
+func axesRMS(names []string, xs []float64, axes [][]float64, freq float64, w Weighting, tau float64) ([]WeightedRMS, error) {
+	out := make([]WeightedRMS, 0, len(axes))
+	for i, ys := range axes {
+		if ys == nil {
+			continue
+		}
+		wrms, err := FrequencyWeighting("axis="+names[i], w, xs, ys, freq, tau)
+		if err != nil {
+			return nil, fmt.Errorf("fouracc: could not weight axis %s: %w", names[i], err)
+		}
+		out = append(out, wrms)
+	}
+	return out, nil
+}
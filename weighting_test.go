@@ -0,0 +1,158 @@
+// Copyright 2019 The fouracc Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fouracc
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/lsst-lpc/fouracc/msr"
+)
+
+func TestWeight(t *testing.T) {
+	// frequency weighting factors (x1000) of ISO 2631-1:1997, Table 3,
+	// at the one-third octave mid-band frequencies.
+	for _, tc := range []struct {
+		f      float64
+		wk, wd float64
+	}{
+		{0.5, 418, 853},
+		{1, 482, 1011},
+		{2, 531, 890},
+		{4, 967, 512},
+		{8, 1036, 253},
+		{16, 768, 125},
+		{31.5, 405, 63.2},
+		{63, 186, 29.5},
+	} {
+		for _, v := range []struct {
+			w    Weighting
+			want float64
+		}{
+			{Wk, tc.wk / 1000},
+			{Wd, tc.wd / 1000},
+		} {
+			if got := v.w.Weight(tc.f); math.Abs(got-v.want) > 0.01*v.want {
+				t.Fatalf("%v: invalid weight at %v Hz: got=%.4f, want=%.4f", v.w, tc.f, got, v.want)
+			}
+		}
+	}
+}
+
+func TestWeightingFilter(t *testing.T) {
+	const freq = 1000.0 // Hz
+	for _, tc := range []struct {
+		w Weighting
+		f float64
+	}{
+		{Wk, 4},
+		{Wk, 31.5},
+		{Wd, 1},
+		{Wd, 8},
+		{Wc, 8},
+		{Wm, 10},
+	} {
+		// the digital filter applies the analog weight to a steady sine:
+		// compare RMS values past the start-up transient.
+		var (
+			n  = int(20 * freq)
+			ys = make([]float64, n)
+		)
+		for i := range ys {
+			ys[i] = math.Sin(2 * math.Pi * tc.f * float64(i) / freq)
+		}
+		out := tc.w.Filter(ys, freq)
+
+		var got float64
+		tail := out[n/2:]
+		for _, v := range tail {
+			got += v * v
+		}
+		got = math.Sqrt(got / float64(len(tail)))
+		if want := tc.w.Weight(tc.f) / math.Sqrt2; math.Abs(got-want) > 0.01*want {
+			t.Fatalf("%v: invalid RMS of a %v Hz sine: got=%.4f, want=%.4f", tc.w, tc.f, got, want)
+		}
+	}
+}
+
+func TestFrequencyWeighting(t *testing.T) {
+	const (
+		freq = 1000.0 // Hz
+		f0   = 8.0    // Hz
+	)
+	var (
+		xs = make([]float64, int(20*freq))
+		ys = make([]float64, len(xs))
+	)
+	for i := range xs {
+		xs[i] = float64(i) / freq
+		ys[i] = 9.81 + math.Sin(2*math.Pi*f0*xs[i]) // gravity is rejected.
+	}
+
+	wrms, err := FrequencyWeighting("sine", Wk, xs, ys, freq, 1)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	want := Wk.Weight(f0) / math.Sqrt2
+	if got := wrms.RMS; math.Abs(got-want) > 0.02*want {
+		t.Fatalf("invalid weighted RMS: got=%v, want=%v", got, want)
+	}
+	if got := wrms.SpectralRMS; math.Abs(got-want) > 0.02*want {
+		t.Fatalf("invalid spectral weighted RMS: got=%v, want=%v", got, want)
+	}
+	if got := wrms.MTVV; got < want || got > 1.1*want {
+		t.Fatalf("invalid MTVV: got=%v, want~%v", got, want)
+	}
+
+	_, err = FrequencyWeighting("sine", Wk, xs, ys, 0, 1)
+	if err == nil {
+		t.Fatalf("expected an error without sampling frequency")
+	}
+}
+
+func TestAxesRMS(t *testing.T) {
+	const freq = 100.0
+	var (
+		t0 = time.Date(2019, 8, 6, 14, 0, 0, 0, time.UTC)
+		ts = make([]time.Time, 1000)
+		x  = make([]float64, len(ts))
+		z  = make([]float64, len(ts))
+	)
+	for i := range ts {
+		ts[i] = t0.Add(time.Duration(i) * 10 * time.Millisecond)
+		x[i] = math.Sin(2 * math.Pi * 2 * float64(i) / freq)
+		z[i] = 2 * x[i]
+	}
+	f := msr.File{
+		Start: t0,
+		Cols: []msr.Column{
+			{Name: "Time", Data: ts},
+			{Name: "ACC x", Unit: "g", Data: x},
+			{Name: "ACC z", Unit: "g", Data: z},
+		},
+	}
+
+	out, err := AxesRMS(f, Wd, 1)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if got, want := len(out), 2; got != want {
+		t.Fatalf("invalid number of axes: got=%d, want=%d", got, want)
+	}
+	if got, want := out[1].Name, "axis=z"; got != want {
+		t.Fatalf("invalid axis name: got=%q, want=%q", got, want)
+	}
+	if got, want := out[1].RMS, 2*out[0].RMS; math.Abs(got-want) > 1e-9*want {
+		t.Fatalf("invalid z-axis RMS: got=%v, want=%v", got, want)
+	}
+
+	// without timestamps, the sampling frequency is unknown.
+	f.Cols[0].Data = []time.Time{}
+	_, err = AxesRMS(f, Wd, 1)
+	if err == nil {
+		t.Fatalf("expected an error without sampling frequency")
+	}
+}